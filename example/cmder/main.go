@@ -175,15 +175,10 @@ func main() {
 	md := &method{cli: cli}
 	cmder.Register(md.send, "", "send message or post to user, group or teams")
 
-	props, err := cli.GetRobotProps()
-	if err != nil {
-		panic(err)
-	}
 	// 将收到的消息做为question，cmder执行结果作为answer。
 	cb := qa.New(cmder.Exec, cli, &qa.Options{
-		TrimAtMe:  true,
-		RobotName: props.Name,
-		Errorf:    log.Printf,
+		TrimAtMe: true,
+		Errorf:   log.Printf,
 	}).Webhook()
 
 	panic(http.ListenAndServe(*listen, webhook.WithAuthSign(&webhook.AuthOptions{
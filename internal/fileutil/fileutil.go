@@ -0,0 +1,30 @@
+// Package fileutil提供本地持久化文件的读写辅助函数。
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile将data写入path：先写同目录下的临时文件，同步到磁盘后再重命名，
+// 避免写一半时进程退出损坏原文件。
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+// Package htmltoken是一个简易html词法分析器，用于解析推推团队帖子、页面消息等html片段。
+// 输入可以是任意不可信的内容：不完整的标签按文本处理，不会panic，但不保证与浏览器的解析结果完全一致。
+package htmltoken
+
+import (
+	"html"
+	"strings"
+)
+
+type Type int
+
+const (
+	Text        Type = iota // 文本，已反转义
+	StartTag                // <a href="...">
+	EndTag                  // </a>
+	SelfClosing             // <br/>
+	Comment                 // <!-- ... -->，Data为注释内容
+)
+
+type Attr struct {
+	Key string // 小写
+	Val string // 已反转义
+}
+
+type Token struct {
+	Type  Type
+	Data  string // 标签名（小写）或文本内容
+	Attrs []Attr
+}
+
+// Attr返回属性key的值，不存在时返回空字符串。
+func (t Token) Attr(key string) string {
+	for _, a := range t.Attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// rawTextTags中的标签内容不解析为标签。
+var rawTextTags = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// Tokenize将s切分为token。不完整的标签按文本处理。
+func Tokenize(s string) []Token {
+	var tokens []Token
+	text := func(t string) {
+		if t == "" {
+			return
+		}
+		t = html.UnescapeString(t)
+		if n := len(tokens); n > 0 && tokens[n-1].Type == Text {
+			tokens[n-1].Data += t
+			return
+		}
+		tokens = append(tokens, Token{Type: Text, Data: t})
+	}
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			text(s)
+			break
+		}
+		text(s[:i])
+		s = s[i:]
+
+		if strings.HasPrefix(s, "<!--") {
+			end := strings.Index(s[4:], "-->")
+			if end < 0 {
+				tokens = append(tokens, Token{Type: Comment, Data: s[4:]})
+				break
+			}
+			tokens = append(tokens, Token{Type: Comment, Data: s[4 : 4+end]})
+			s = s[4+end+3:]
+			continue
+		}
+		if strings.HasPrefix(s, "<!") || strings.HasPrefix(s, "<?") {
+			// <!DOCTYPE html>等声明直接忽略
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				break
+			}
+			s = s[end+1:]
+			continue
+		}
+
+		tok, n, ok := parseTag(s)
+		if !ok {
+			text("<")
+			s = s[1:]
+			continue
+		}
+		tokens = append(tokens, tok)
+		s = s[n:]
+
+		if tok.Type == StartTag && rawTextTags[tok.Data] {
+			end := indexFold(s, "</"+tok.Data)
+			if end < 0 {
+				end = len(s)
+			}
+			text(s[:end])
+			s = s[end:]
+		}
+	}
+	return tokens
+}
+
+// indexFold返回substr在s中第一次出现的位置，忽略ASCII大小写；substr须为小写。
+// 不能用strings.ToLower(s)查找：部分字符小写后字节数改变，位置与s不再对应。
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTag解析s开头的标签，返回token及标签长度。
+func parseTag(s string) (tok Token, n int, ok bool) {
+	i := 1
+	end := false
+	if i < len(s) && s[i] == '/' {
+		end = true
+		i++
+	}
+	start := i
+	for i < len(s) && isNameByte(s[i]) {
+		i++
+	}
+	if i == start {
+		return tok, 0, false
+	}
+	tok.Data = strings.ToLower(s[start:i])
+	tok.Type = StartTag
+	if end {
+		tok.Type = EndTag
+	}
+
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return tok, 0, false
+		}
+		switch {
+		case s[i] == '>':
+			return tok, i + 1, true
+		case s[i] == '/' && i+1 < len(s) && s[i+1] == '>':
+			if tok.Type == StartTag {
+				tok.Type = SelfClosing
+			}
+			return tok, i + 2, true
+		case s[i] == '/':
+			i++
+			continue
+		}
+
+		// 属性名
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		key := strings.ToLower(s[start:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		var val string
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				q := s[i]
+				j := strings.IndexByte(s[i+1:], q)
+				if j < 0 {
+					return tok, 0, false
+				}
+				val = s[i+1 : i+1+j]
+				i += j + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				val = s[start:i]
+			}
+		}
+		if key != "" && tok.Type != EndTag {
+			tok.Attrs = append(tok.Attrs, Attr{Key: key, Val: html.UnescapeString(val)})
+		}
+	}
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
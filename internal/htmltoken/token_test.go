@@ -0,0 +1,48 @@
+package htmltoken
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize(`<!DOCTYPE html><P class='a &amp; b'>x &lt; y<br/><!-- c --><script>if (a<b) {}</script></p> 1 < 2`)
+	expected := []Token{
+		{Type: StartTag, Data: "p", Attrs: []Attr{{Key: "class", Val: "a & b"}}},
+		{Type: Text, Data: "x < y"},
+		{Type: SelfClosing, Data: "br"},
+		{Type: Comment, Data: " c "},
+		{Type: StartTag, Data: "script"},
+		{Type: Text, Data: "if (a<b) {}"},
+		{Type: EndTag, Data: "script"},
+		{Type: EndTag, Data: "p"},
+		{Type: Text, Data: " 1 < 2"},
+	}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("tokens:\n%+v\nexpected:\n%+v", tokens, expected)
+	}
+}
+
+func TestTokenizeHostile(t *testing.T) {
+	// "Ⱥ"小写后字节数增加，曾导致raw text结束位置错位而panic
+	long := strings.Repeat("Ⱥ", 20)
+	for _, s := range []string{
+		"<title>" + long + "</title>",
+		"<SCRIPT>" + long + "</ScRiPt>x",
+		"<style>" + long,
+		"<!-- " + long,
+		"<a href='" + long,
+		"<" + long + ">",
+		"</",
+		"<!",
+	} {
+		tokens := Tokenize(s)
+		if strings.HasPrefix(s, "<SCRIPT>") {
+			last := tokens[len(tokens)-1]
+			if last.Type != Text || last.Data != "x" || tokens[1].Data != long {
+				t.Fatalf("script tokens: %+v", tokens)
+			}
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package strutil提供各util包共用的字符串辅助函数。
+package strutil
+
+// AppendUnique将s中没有的elems追加到s，保持原有顺序。
+func AppendUnique(s []string, elems ...string) []string {
+next:
+	for _, e := range elems {
+		for _, x := range s {
+			if x == e {
+				continue next
+			}
+		}
+		s = append(s, e)
+	}
+	return s
+}
@@ -0,0 +1,13 @@
+package strutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppendUnique(t *testing.T) {
+	s := AppendUnique([]string{"a", "b"}, "b", "c", "a", "c")
+	if got := strings.Join(s, ","); got != "a,b,c" {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,19 @@
+// Package timeutil提供各util包共用的时间辅助函数。
+package timeutil
+
+import (
+	"strings"
+	"time"
+)
+
+// ShortDuration格式化d并去掉末尾的0值单位，如1h0m0s格式化为1h，1m30s不变。
+func ShortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,22 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShortDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		time.Hour:                  "1h",
+		90 * time.Minute:           "1h30m",
+		time.Minute:                "1m",
+		90 * time.Second:           "1m30s",
+		20 * time.Millisecond:      "20ms",
+		time.Hour + 30*time.Second: "1h0m30s",
+	}
+	for d, want := range cases {
+		if got := ShortDuration(d); got != want {
+			t.Errorf("%v: got %v, want %v", d, got, want)
+		}
+	}
+}
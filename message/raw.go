@@ -0,0 +1,49 @@
+package message
+
+import "encoding/json"
+
+// 原始消息，由消息类型、消息键和json格式的消息内容组成。
+//
+// 常用于将消息持久化后重新发送，或发送本库尚未封装的消息类型。
+type Raw struct {
+	MsgType  string          // 消息类型，即Type()
+	MsgIndex string          // 消息内容所用的键，即Index()，为空时同MsgType
+	Content  json.RawMessage // json格式消息内容
+}
+
+func (r Raw) Type() string {
+	return r.MsgType
+}
+
+func (r Raw) Index() string {
+	if r.MsgIndex == "" {
+		return r.MsgType
+	}
+	return r.MsgIndex
+}
+
+func (r Raw) MarshalJSON() ([]byte, error) {
+	if len(r.Content) == 0 {
+		return []byte("{}"), nil
+	}
+	return r.Content, nil
+}
+
+func NewRaw(msgType, index string, content json.RawMessage) Raw {
+	return Raw{MsgType: msgType, MsgIndex: index, Content: content}
+}
+
+// RawOf将任意消息转为Raw，msg一般为本包定义的消息类型。
+func RawOf(msg interface {
+	Type() string
+	Index() string
+}) (Raw, error) {
+	if raw, ok := msg.(Raw); ok {
+		return raw, nil
+	}
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return Raw{}, err
+	}
+	return Raw{MsgType: msg.Type(), MsgIndex: msg.Index(), Content: content}, nil
+}
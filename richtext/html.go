@@ -0,0 +1,186 @@
+package richtext
+
+import (
+	"strings"
+
+	"github.com/eachain/360-tuitui-robot/internal/htmltoken"
+)
+
+// 无结束标签的元素。
+var voidTags = map[string]bool{
+	"br": true, "hr": true, "img": true, "input": true, "meta": true, "link": true, "wbr": true,
+}
+
+// 内容被忽略的元素。
+var skipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "title": true, "template": true,
+}
+
+type frame struct {
+	tag  string
+	node *Node // 子节点追加到node，透明标签为父节点的node
+
+	text *Node // 不为空时，文本追加到text.Text（代码）或text.Mention.Name（@）
+	pre  bool
+	skip bool
+}
+
+// ParseHTML解析html/v1格式富文本。不认识的标签保留其内容。
+func ParseHTML(content string) *Node {
+	doc := &Node{Type: Document}
+	stack := []*frame{{node: doc}}
+
+	for _, tok := range htmltoken.Tokenize(content) {
+		top := stack[len(stack)-1]
+		switch tok.Type {
+		case htmltoken.Text:
+			switch {
+			case top.skip:
+			case top.text != nil && top.text.Type == Mention:
+				top.text.Mention.Name += tok.Data
+			case top.text != nil:
+				if !top.pre {
+					tok.Data = collapseSpace(tok.Data)
+				}
+				top.text.Text += tok.Data
+			default:
+				top.node.Children = append(top.node.Children, &Node{Type: Text, Text: collapseSpace(tok.Data)})
+			}
+
+		case htmltoken.StartTag, htmltoken.SelfClosing:
+			if top.skip {
+				if tok.Type == htmltoken.StartTag && !voidTags[tok.Data] {
+					stack = append(stack, &frame{tag: tok.Data, node: top.node, skip: true})
+				}
+				continue
+			}
+			if top.text != nil {
+				// 代码及@内部的标签：只保留文本
+				if tok.Data == "br" && top.pre {
+					top.text.Text += "\n"
+				}
+				if tok.Data == "code" && top.pre && top.text.Lang == "" {
+					top.text.Lang = langOf(tok.Attr("class"))
+				}
+				if tok.Type == htmltoken.StartTag && !voidTags[tok.Data] {
+					f := *top
+					f.tag = tok.Data
+					stack = append(stack, &f)
+				}
+				continue
+			}
+
+			f := &frame{tag: tok.Data, node: top.node}
+			n := htmlNode(tok)
+			switch {
+			case skipTags[tok.Data]:
+				f.skip = true
+			case n == nil:
+			case n.Type == CodeBlock:
+				f.text, f.pre = n, true
+			case n.Type == Mention && n.Mention.Name != "":
+				f.skip = true // 已有data-label，忽略"@xxx"文本
+			case n.Type == Code || n.Type == Mention:
+				f.text = n
+			default:
+				f.node = n
+			}
+			if n != nil {
+				top.node.Children = append(top.node.Children, n)
+			}
+			if tok.Type == htmltoken.StartTag && !voidTags[tok.Data] {
+				stack = append(stack, f)
+			}
+
+		case htmltoken.EndTag:
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tag == tok.Data {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+
+	for _, m := range doc.Find(Mention) {
+		m.Mention.Name = strings.TrimPrefix(strings.TrimSpace(m.Mention.Name), "@")
+	}
+	return normalize(doc)
+}
+
+// htmlNode返回标签对应的节点，透明标签返回nil。
+func htmlNode(tok htmltoken.Token) *Node {
+	switch tok.Data {
+	case "p", "div", "section", "article", "header", "footer", "center":
+		return &Node{Type: Paragraph}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return &Node{Type: Heading, Level: int(tok.Data[1] - '0')}
+	case "pre":
+		return &Node{Type: CodeBlock, Lang: langOf(tok.Attr("class"))}
+	case "ul":
+		return &Node{Type: List}
+	case "ol":
+		return &Node{Type: List, Ordered: true}
+	case "li":
+		return &Node{Type: ListItem}
+	case "blockquote":
+		return &Node{Type: Blockquote}
+	case "hr":
+		return &Node{Type: Rule}
+	case "br":
+		return &Node{Type: HardBreak}
+	case "img":
+		return &Node{Type: Image, Src: tok.Attr("src"), Alt: tok.Attr("alt")}
+	case "a":
+		return &Node{Type: Link, Href: tok.Attr("href")}
+	case "strong", "b":
+		return &Node{Type: Bold}
+	case "em", "i":
+		return &Node{Type: Italic}
+	case "s", "del", "strike":
+		return &Node{Type: Strike}
+	case "code":
+		return &Node{Type: Code}
+	}
+	if typ := tok.Attr("data-type"); typ == "mention" || tok.Data == "at" {
+		m := &MentionInfo{Type: MentionUser, Id: tok.Attr("data-id"), Name: tok.Attr("data-label")}
+		if t := tok.Attr("data-mention-type"); t != "" {
+			m.Type = t
+		}
+		if m.Id == "all" {
+			m.Type = MentionAll
+		}
+		return &Node{Type: Mention, Mention: m}
+	}
+	return nil
+}
+
+// langOf从class="language-go"中取出语言。
+func langOf(class string) string {
+	for _, c := range strings.Fields(class) {
+		if lang, ok := strings.CutPrefix(c, "language-"); ok {
+			return lang
+		}
+		if lang, ok := strings.CutPrefix(c, "lang-"); ok {
+			return lang
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
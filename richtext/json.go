@@ -0,0 +1,167 @@
+package richtext
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// json/v1为ProseMirror风格的文档：
+//
+//	{"type": "doc", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "hi", "marks": [{"type": "bold"}]}]}]}
+type jsonNode struct {
+	Type    string         `json:"type"`
+	Text    string         `json:"text,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Marks   []jsonMark     `json:"marks,omitempty"`
+	Content []*jsonNode    `json:"content,omitempty"`
+}
+
+type jsonMark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// ParseJSON解析json/v1格式富文本。不认识的节点保留其子节点或文本。
+func ParseJSON(content string) (*Node, error) {
+	var root jsonNode
+	if err := json.Unmarshal([]byte(content), &root); err != nil {
+		return nil, fmt.Errorf("richtext: parse json/v1: %w", err)
+	}
+	doc := &Node{Type: Document}
+	if typeKey(root.Type) == "doc" {
+		doc.Children = convertJSON(root.Content)
+	} else {
+		doc.Children = convertJSON([]*jsonNode{&root})
+	}
+	return normalize(doc), nil
+}
+
+// typeKey将"code_block"、"codeBlock"统一为"codeblock"。
+func typeKey(typ string) string {
+	return strings.ToLower(strings.ReplaceAll(typ, "_", ""))
+}
+
+func convertJSON(nodes []*jsonNode) []*Node {
+	var out []*Node
+	for _, jn := range nodes {
+		if jn == nil {
+			continue
+		}
+		switch typeKey(jn.Type) {
+		case "paragraph":
+			out = append(out, &Node{Type: Paragraph, Children: convertJSON(jn.Content)})
+		case "heading":
+			level := attrInt(jn.Attrs, "level")
+			if level < 1 || level > 6 {
+				level = 1
+			}
+			out = append(out, &Node{Type: Heading, Level: level, Children: convertJSON(jn.Content)})
+		case "codeblock":
+			lang := attrString(jn.Attrs, "language")
+			if lang == "" {
+				lang = attrString(jn.Attrs, "lang")
+			}
+			code := (&Node{Children: convertJSON(jn.Content)}).TextContent()
+			out = append(out, &Node{Type: CodeBlock, Lang: lang, Text: code})
+		case "bulletlist", "orderedlist", "list":
+			ordered := typeKey(jn.Type) == "orderedlist" || attrBool(jn.Attrs, "ordered")
+			out = append(out, &Node{Type: List, Ordered: ordered, Children: convertJSON(jn.Content)})
+		case "listitem", "taskitem":
+			out = append(out, &Node{Type: ListItem, Children: convertJSON(jn.Content)})
+		case "blockquote":
+			out = append(out, &Node{Type: Blockquote, Children: convertJSON(jn.Content)})
+		case "horizontalrule", "hr":
+			out = append(out, &Node{Type: Rule})
+		case "hardbreak", "br":
+			out = append(out, &Node{Type: HardBreak})
+		case "image", "img":
+			src := attrString(jn.Attrs, "src")
+			if src == "" {
+				src = attrString(jn.Attrs, "url")
+			}
+			out = append(out, &Node{Type: Image, Src: src, Alt: attrString(jn.Attrs, "alt")})
+		case "mention", "at":
+			out = append(out, &Node{Type: Mention, Mention: jsonMention(jn.Attrs)})
+		case "text":
+			if jn.Text != "" {
+				out = append(out, withMarks(&Node{Type: Text, Text: jn.Text}, jn.Marks))
+			}
+		default:
+			if len(jn.Content) > 0 {
+				out = append(out, convertJSON(jn.Content)...)
+			} else if jn.Text != "" {
+				out = append(out, &Node{Type: Text, Text: jn.Text})
+			}
+		}
+	}
+	return out
+}
+
+func jsonMention(attrs map[string]any) *MentionInfo {
+	m := &MentionInfo{
+		Type: attrString(attrs, "type"),
+		Id:   attrString(attrs, "id"),
+		Name: attrString(attrs, "label"),
+	}
+	if m.Name == "" {
+		m.Name = attrString(attrs, "name")
+	}
+	if m.Id == "all" {
+		m.Type = MentionAll
+	}
+	if m.Type == "" {
+		m.Type = MentionUser
+	}
+	return m
+}
+
+// withMarks将marks转为嵌套的行内节点，链接在最外层。
+func withMarks(n *Node, marks []jsonMark) *Node {
+	var link *jsonMark
+	for i := len(marks) - 1; i >= 0; i-- {
+		switch typeKey(marks[i].Type) {
+		case "code":
+			n = &Node{Type: Code, Text: n.TextContent()}
+		case "bold", "strong":
+			n = &Node{Type: Bold, Children: []*Node{n}}
+		case "italic", "em":
+			n = &Node{Type: Italic, Children: []*Node{n}}
+		case "strike", "strikethrough", "s":
+			n = &Node{Type: Strike, Children: []*Node{n}}
+		case "link":
+			link = &marks[i]
+		}
+	}
+	if link != nil {
+		n = &Node{Type: Link, Href: attrString(link.Attrs, "href"), Children: []*Node{n}}
+	}
+	return n
+}
+
+func attrString(attrs map[string]any, key string) string {
+	switch v := attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+func attrInt(attrs map[string]any, key string) int {
+	switch v := attrs[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		var n int
+		fmt.Sscan(v, &n)
+		return n
+	}
+	return 0
+}
+
+func attrBool(attrs map[string]any, key string) bool {
+	v, _ := attrs[key].(bool)
+	return v
+}
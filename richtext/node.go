@@ -0,0 +1,284 @@
+// Package richtext解析团队帖子富文本（webhook.TeamsPostEvent.RichText），
+// 支持json/v1及html/v1两种格式，解析结果为统一的文档树，可渲染为Markdown或纯文本。
+package richtext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 富文本类型，即webhook.TeamsPostEvent.RichTextType。
+const (
+	TypeJSON = "json/v1"
+	TypeHTML = "html/v1"
+)
+
+type NodeType string
+
+// 块级节点。
+const (
+	Document   NodeType = "doc"
+	Paragraph  NodeType = "paragraph"
+	Heading    NodeType = "heading"    // Level为标题级别1-6
+	CodeBlock  NodeType = "codeBlock"  // Text为代码，Lang为语言
+	List       NodeType = "list"       // Ordered为有序列表，Children均为ListItem
+	ListItem   NodeType = "listItem"   // Children为块级节点
+	Blockquote NodeType = "blockquote" // Children为块级节点
+	Rule       NodeType = "horizontalRule"
+)
+
+// 行内节点。
+const (
+	Text      NodeType = "text"   // Text为文本
+	Bold      NodeType = "bold"   // Children为行内节点，下同
+	Italic    NodeType = "italic" //
+	Strike    NodeType = "strike" //
+	Link      NodeType = "link"   // Href为链接地址
+	Code      NodeType = "code"   // 行内代码，Text为代码
+	Mention   NodeType = "mention"
+	Image     NodeType = "image" // Src为图片地址，Alt为描述
+	HardBreak NodeType = "hardBreak"
+)
+
+// 被@对象类型，与webhook.PostAtType取值一致。
+const (
+	MentionUser = "user"
+	MentionTag  = "tag"
+	MentionAll  = "all"
+)
+
+// MentionInfo为@对象。
+type MentionInfo struct {
+	Type string // MentionUser, MentionTag, MentionAll
+	Id   string // 用户域账号或标签id
+	Name string // 展示名称
+}
+
+// Node为文档树节点。
+type Node struct {
+	Type     NodeType
+	Text     string       // Text、Code、CodeBlock
+	Href     string       // Link
+	Src      string       // Image
+	Alt      string       // Image
+	Lang     string       // CodeBlock
+	Level    int          // Heading
+	Ordered  bool         // List
+	Mention  *MentionInfo // Mention
+	Children []*Node
+}
+
+// Parse按富文本类型解析content。
+func Parse(typ, content string) (*Node, error) {
+	switch typ {
+	case TypeJSON:
+		return ParseJSON(content)
+	case TypeHTML:
+		return ParseHTML(content), nil
+	default:
+		return nil, fmt.Errorf("richtext: unsupported type %q", typ)
+	}
+}
+
+// Walk深度优先遍历文档树，f返回false时不再遍历该节点的子节点。
+func (n *Node) Walk(f func(*Node) bool) {
+	if n == nil || !f(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(f)
+	}
+}
+
+// Find返回所有typ类型的节点，按文档顺序排列。
+func (n *Node) Find(typ NodeType) []*Node {
+	var nodes []*Node
+	n.Walk(func(c *Node) bool {
+		if c.Type == typ {
+			nodes = append(nodes, c)
+		}
+		return true
+	})
+	return nodes
+}
+
+// CodeBlocks返回所有代码块。
+func (n *Node) CodeBlocks() []*Node {
+	return n.Find(CodeBlock)
+}
+
+// Links返回所有链接地址，按文档顺序排列。
+func (n *Node) Links() []string {
+	var links []string
+	for _, l := range n.Find(Link) {
+		links = append(links, l.Href)
+	}
+	return links
+}
+
+// Mentions返回所有@对象，按文档顺序排列。
+func (n *Node) Mentions() []*MentionInfo {
+	var mentions []*MentionInfo
+	for _, m := range n.Find(Mention) {
+		mentions = append(mentions, m.Mention)
+	}
+	return mentions
+}
+
+// TextContent返回节点内所有文本，不含任何格式。
+func (n *Node) TextContent() string {
+	var b strings.Builder
+	n.Walk(func(c *Node) bool {
+		switch c.Type {
+		case Text, Code, CodeBlock:
+			b.WriteString(c.Text)
+		case Mention:
+			b.WriteString(mentionText(c.Mention))
+		case HardBreak:
+			b.WriteByte('\n')
+		}
+		return true
+	})
+	return b.String()
+}
+
+func mentionText(m *MentionInfo) string {
+	if m == nil {
+		return "@"
+	}
+	switch {
+	case m.Type == MentionAll:
+		return "@所有人"
+	case m.Name != "":
+		return "@" + m.Name
+	default:
+		return "@" + m.Id
+	}
+}
+
+func isBlock(t NodeType) bool {
+	switch t {
+	case Document, Paragraph, Heading, CodeBlock, List, ListItem, Blockquote, Rule:
+		return true
+	}
+	return false
+}
+
+// normalize整理文档树：块级节点中的行内节点合并为段落，段落中的块级节点提升为同级节点，
+// 合并相邻文本，去掉空段落及段落首尾空白。
+func normalize(n *Node) *Node {
+	for _, c := range n.Children {
+		normalize(c)
+	}
+
+	switch n.Type {
+	case Document, ListItem, Blockquote:
+		n.Children = wrapInline(n.Children)
+	case List:
+		var items []*Node
+		for _, c := range n.Children {
+			if c.Type == ListItem {
+				items = append(items, c)
+			} else if !isSpaceText(c) {
+				items = append(items, &Node{Type: ListItem, Children: wrapInline([]*Node{c})})
+			}
+		}
+		n.Children = items
+	}
+
+	if isBlock(n.Type) {
+		n.Children = liftBlocks(n.Children)
+	}
+	n.Children = mergeText(n.Children)
+	return n
+}
+
+// wrapInline将连续的行内节点包装为段落。
+func wrapInline(children []*Node) []*Node {
+	var out, run []*Node
+	flush := func() {
+		if p := trimParagraph(&Node{Type: Paragraph, Children: run}); p != nil {
+			out = append(out, p)
+		}
+		run = nil
+	}
+	for _, c := range children {
+		if isBlock(c.Type) {
+			flush()
+			out = append(out, c)
+		} else {
+			run = append(run, c)
+		}
+	}
+	flush()
+	return out
+}
+
+// liftBlocks将段落、标题中的块级节点（如<p>中的<div>）提升为同级节点。
+func liftBlocks(children []*Node) []*Node {
+	var out []*Node
+	for _, c := range children {
+		if c.Type != Paragraph && c.Type != Heading {
+			out = append(out, c)
+			continue
+		}
+		var run []*Node
+		flush := func() {
+			p := *c
+			p.Children = run
+			if t := trimParagraph(&p); t != nil {
+				out = append(out, t)
+			}
+			run = nil
+		}
+		for _, cc := range c.Children {
+			if isBlock(cc.Type) {
+				flush()
+				out = append(out, cc)
+			} else {
+				run = append(run, cc)
+			}
+		}
+		flush()
+	}
+	return out
+}
+
+// trimParagraph去掉段落首尾空白，空段落返回nil。
+func trimParagraph(p *Node) *Node {
+	p.Children = mergeText(p.Children)
+	for len(p.Children) > 0 && isSpaceText(p.Children[0]) {
+		p.Children = p.Children[1:]
+	}
+	for len(p.Children) > 0 && isSpaceText(p.Children[len(p.Children)-1]) {
+		p.Children = p.Children[:len(p.Children)-1]
+	}
+	if len(p.Children) == 0 {
+		return nil
+	}
+	if first := p.Children[0]; first.Type == Text {
+		first.Text = strings.TrimLeft(first.Text, " ")
+	}
+	if last := p.Children[len(p.Children)-1]; last.Type == Text {
+		last.Text = strings.TrimRight(last.Text, " ")
+	}
+	return p
+}
+
+func mergeText(children []*Node) []*Node {
+	var out []*Node
+	for _, c := range children {
+		if c.Type == Text && len(out) > 0 && out[len(out)-1].Type == Text {
+			prev := *out[len(out)-1]
+			prev.Text += c.Text
+			out[len(out)-1] = &prev
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func isSpaceText(n *Node) bool {
+	return n.Type == Text && strings.TrimSpace(n.Text) == ""
+}
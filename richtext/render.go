@@ -0,0 +1,260 @@
+package richtext
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Markdown将文档树渲染为Markdown。
+func Markdown(n *Node) string {
+	var b strings.Builder
+	writeBlocks(&b, blockChildren(n), "", true)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Plain将文档树渲染为纯文本：段落间空行分隔，链接文本与地址不同时附加地址，列表保留序号。
+func Plain(n *Node) string {
+	var b strings.Builder
+	writeBlocks(&b, blockChildren(n), "", false)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// blockChildren返回n的块级子节点，n本身为段落等节点时返回n。
+func blockChildren(n *Node) []*Node {
+	if n == nil {
+		return nil
+	}
+	switch n.Type {
+	case Document, ListItem, Blockquote:
+		return n.Children
+	}
+	if isBlock(n.Type) {
+		return []*Node{n}
+	}
+	return []*Node{{Type: Paragraph, Children: []*Node{n}}}
+}
+
+// writeBlocks输出块级节点，每行以prefix开头（用于列表缩进及引用）。
+func writeBlocks(b *strings.Builder, blocks []*Node, prefix string, md bool) {
+	for i, n := range blocks {
+		if i > 0 {
+			b.WriteString(strings.TrimRight(prefix, " "))
+			b.WriteByte('\n')
+		}
+		writeBlock(b, n, prefix, md)
+	}
+}
+
+func writeBlock(b *strings.Builder, n *Node, prefix string, md bool) {
+	switch n.Type {
+	case Paragraph:
+		writeLines(b, prefix, inline(n.Children, md))
+	case Heading:
+		text := inline(n.Children, md)
+		if md {
+			text = strings.Repeat("#", n.Level) + " " + strings.ReplaceAll(text, "  \n", " ")
+		}
+		writeLines(b, prefix, text)
+	case CodeBlock:
+		code := strings.TrimSuffix(n.Text, "\n")
+		if md {
+			fence := fenceFor(code, '`', 3)
+			code = fence + n.Lang + "\n" + code + "\n" + fence
+		}
+		writeLines(b, prefix, code)
+	case List:
+		for i, item := range n.Children {
+			marker := "- "
+			if n.Ordered {
+				marker = strconv.Itoa(i+1) + ". "
+			}
+			writeItem(b, item, prefix, marker, md)
+		}
+	case ListItem:
+		writeItem(b, n, prefix, "- ", md)
+	case Blockquote:
+		if md {
+			writeBlocks(b, n.Children, prefix+"> ", md)
+		} else {
+			writeBlocks(b, n.Children, prefix, md)
+		}
+	case Rule:
+		if md {
+			writeLines(b, prefix, "---")
+		} else {
+			writeLines(b, prefix, "----------")
+		}
+	}
+}
+
+// writeItem输出列表项，首行以marker开头，后续行与marker对齐。
+func writeItem(b *strings.Builder, item *Node, prefix, marker string, md bool) {
+	var sub strings.Builder
+	indent := strings.Repeat(" ", len(marker))
+	for i, c := range item.Children {
+		if i > 0 && c.Type != List {
+			sub.WriteByte('\n')
+		}
+		writeBlock(&sub, c, "", md)
+	}
+	lines := strings.Split(strings.TrimRight(sub.String(), "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			line = marker + line
+		case line != "":
+			line = indent + line
+		}
+		writeLines(b, prefix, line)
+	}
+}
+
+func writeLines(b *strings.Builder, prefix, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			b.WriteString(strings.TrimRight(prefix, " "))
+		} else {
+			b.WriteString(prefix)
+			b.WriteString(line)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+func inline(nodes []*Node, md bool) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		writeInline(&b, n, md)
+	}
+	return b.String()
+}
+
+func writeInline(b *strings.Builder, n *Node, md bool) {
+	switch n.Type {
+	case Text:
+		if md {
+			b.WriteString(EscapeMarkdown(n.Text))
+		} else {
+			b.WriteString(n.Text)
+		}
+	case Bold:
+		writeMarked(b, n, "**", md)
+	case Italic:
+		writeMarked(b, n, "*", md)
+	case Strike:
+		writeMarked(b, n, "~~", md)
+	case Code:
+		if !md {
+			b.WriteString(n.Text)
+			break
+		}
+		fence := fenceFor(n.Text, '`', 1)
+		if strings.HasPrefix(n.Text, "`") || strings.HasSuffix(n.Text, "`") {
+			b.WriteString(fence + " " + n.Text + " " + fence)
+		} else {
+			b.WriteString(fence + n.Text + fence)
+		}
+	case Link:
+		text := inline(n.Children, md)
+		switch {
+		case md:
+			if text == "" {
+				text = EscapeMarkdown(n.Href)
+			}
+			b.WriteString("[" + text + "](" + escapeURL(n.Href) + ")")
+		case text == "" || text == n.Href:
+			b.WriteString(n.Href)
+		default:
+			b.WriteString(text + " (" + n.Href + ")")
+		}
+	case Image:
+		if md {
+			b.WriteString("![" + EscapeMarkdown(n.Alt) + "](" + escapeURL(n.Src) + ")")
+		} else if n.Alt != "" {
+			b.WriteString("[" + n.Alt + "]")
+		} else {
+			b.WriteString("[图片]")
+		}
+	case Mention:
+		if md {
+			b.WriteString(EscapeMarkdown(mentionText(n.Mention)))
+		} else {
+			b.WriteString(mentionText(n.Mention))
+		}
+	case HardBreak:
+		if md {
+			b.WriteString("  \n")
+		} else {
+			b.WriteByte('\n')
+		}
+	default:
+		b.WriteString(inline(n.Children, md))
+	}
+}
+
+func writeMarked(b *strings.Builder, n *Node, mark string, md bool) {
+	text := inline(n.Children, md)
+	if !md || strings.TrimSpace(text) == "" {
+		b.WriteString(text)
+		return
+	}
+	// 标记不能紧邻空白，否则不被识别
+	trimmed := strings.TrimSpace(text)
+	i := strings.Index(text, trimmed)
+	b.WriteString(text[:i] + mark + trimmed + mark + text[i+len(trimmed):])
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `&lt;`, `>`, `&gt;`, `&`, `&amp;`, `~`, `\~`, `#`, `\#`, `|`, `\|`,
+)
+
+// EscapeMarkdown转义s中的Markdown及html特殊字符，使其按原文展示。
+// 行首的"-"、"+"、"1."等列表标记同样被转义。
+func EscapeMarkdown(s string) string {
+	s = markdownEscaper.Replace(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = escapeLineStart(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func escapeLineStart(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	indent := line[:len(line)-len(trimmed)]
+	if strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "+") {
+		return indent + `\` + trimmed
+	}
+	i := 0
+	for i < len(trimmed) && '0' <= trimmed[i] && trimmed[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(trimmed) && (trimmed[i] == '.' || trimmed[i] == ')') {
+		return indent + trimmed[:i] + `\` + trimmed[i:]
+	}
+	return line
+}
+
+func escapeURL(s string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(s)
+}
+
+// fenceFor返回比s中最长的连续c更长、且不短于min的围栏。
+func fenceFor(s string, c byte, min int) string {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	if longest+1 > min {
+		min = longest + 1
+	}
+	return strings.Repeat(string(c), min)
+}
@@ -0,0 +1,87 @@
+package richtext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	doc, err := ParseJSON(`{"type":"doc","content":[
+		{"type":"paragraph","content":[
+			{"type":"mention","attrs":{"id":"zhangsan","label":"张三"}},
+			{"type":"text","text":" 看下"},
+			{"type":"text","text":"文档","marks":[{"type":"bold"},{"type":"link","attrs":{"href":"https://a.com/x"}}]}
+		]},
+		{"type":"codeBlock","attrs":{"language":"go"},"content":[{"type":"text","text":"fmt.Println(\"` + "```" + `\")"}]},
+		{"type":"bulletList","content":[
+			{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"a_b"}]}]},
+			{"type":"listItem","content":[{"type":"paragraph","content":[{"type":"text","text":"c"}]}]}
+		]}
+	]}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if ms := doc.Mentions(); len(ms) != 1 || ms[0].Id != "zhangsan" || ms[0].Name != "张三" {
+		t.Fatalf("mentions: %+v", ms)
+	}
+	if links := doc.Links(); len(links) != 1 || links[0] != "https://a.com/x" {
+		t.Fatalf("links: %v", links)
+	}
+
+	md := "@张三 看下[**文档**](https://a.com/x)\n\n" +
+		"````go\nfmt.Println(\"```\")\n````\n\n" +
+		"- a\\_b\n- c"
+	if got := Markdown(doc); got != md {
+		t.Fatalf("markdown:\n%s\nexpected:\n%s", got, md)
+	}
+
+	plain := "@张三 看下文档 (https://a.com/x)\n\nfmt.Println(\"```\")\n\n- a_b\n- c"
+	if got := Plain(doc); got != plain {
+		t.Fatalf("plain:\n%s\nexpected:\n%s", got, plain)
+	}
+}
+
+func TestParseHTML(t *testing.T) {
+	doc := ParseHTML(`<p><span data-type="mention" data-id="lisi" data-label="李四">@李四</span> 你好<br/>` +
+		`见 <a href="https://b.com">这里</a> &amp; <code>x*y</code></p>` +
+		`<pre><code class="language-sh">ls -l
+pwd</code></pre>` +
+		`<ol><li>one<ul><li>nested</li></ul></li><li>two</li></ol>` +
+		`<blockquote>quoted</blockquote><script>alert(1)</script>`)
+
+	if ms := doc.Mentions(); len(ms) != 1 || ms[0].Id != "lisi" || ms[0].Name != "李四" {
+		t.Fatalf("mentions: %+v", ms)
+	}
+	if cbs := doc.CodeBlocks(); len(cbs) != 1 || cbs[0].Lang != "sh" || cbs[0].Text != "ls -l\npwd" {
+		t.Fatalf("code blocks: %+v", cbs)
+	}
+
+	md := "@李四 你好  \n见 [这里](https://b.com) &amp; `x*y`\n\n" +
+		"```sh\nls -l\npwd\n```\n\n" +
+		"1. one\n   - nested\n2. two\n\n" +
+		"> quoted"
+	if got := Markdown(doc); got != md {
+		t.Fatalf("markdown:\n%s\nexpected:\n%s", got, md)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := Parse("unknown", ""); err == nil {
+		t.Fatalf("expected error for unknown type")
+	}
+	doc, err := Parse(TypeHTML, "<div>  a  <b>b</b> </div>")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := Plain(doc); got != "a b" {
+		t.Fatalf("plain: %q", got)
+	}
+}
+
+func TestParseHTMLHostile(t *testing.T) {
+	doc := ParseHTML("<p><title>" + strings.Repeat("Ⱥ", 20) + "</title>ok</p>")
+	if got := Plain(doc); got != "ok" {
+		t.Fatalf("plain: %q", got)
+	}
+}
@@ -0,0 +1,126 @@
+package alertack
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/interactive"
+)
+
+type fakeClient struct {
+	group client.GroupMsgIdPair
+	card  interactive.Interactive
+}
+
+func (c *fakeClient) ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	return nil
+}
+
+func (c *fakeClient) ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	c.group = msgid
+	c.card = msg.(interactive.Interactive)
+	return nil
+}
+
+func TestSilence(t *testing.T) {
+	var silence struct {
+		Matchers  []matcher `json:"matchers"`
+		StartsAt  time.Time `json:"startsAt"`
+		EndsAt    time.Time `json:"endsAt"`
+		CreatedBy string    `json:"createdBy"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/silences" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&silence)
+		w.Write([]byte(`{"silenceID":"s1"}`))
+	}))
+	defer srv.Close()
+
+	cli := new(fakeClient)
+	handler := NewHandler(NewAlertmanager(srv.URL), cli, nil)
+
+	card := Card(Alert{Fingerprint: "abc", Labels: map[string]string{"alertname": "HighCPU"}}, "HighCPU", "cpu > 90%")
+	value, _ := json.Marshal(card.Value)
+	silenceValue, _ := json.Marshal(card.Action[1].Value)
+
+	cm := &interactive.ConfirmMessage{
+		MsgId: "m1",
+		User:  interactive.User{Account: "zhangsan", Name: "张三"},
+		Value: value,
+		Action: []*interactive.CbAction{
+			{Text: card.Action[1].Text, Name: ActionSilence, Value: silenceValue},
+		},
+	}
+	cm.Conv.Type = "group"
+	cm.Conv.Target = "g1"
+	handler(cm)
+
+	if len(silence.Matchers) != 1 || silence.Matchers[0].Value != "HighCPU" || silence.CreatedBy != "zhangsan" {
+		t.Fatalf("silence: %+v", silence)
+	}
+	if d := silence.EndsAt.Sub(silence.StartsAt); d != time.Hour {
+		t.Fatalf("silence duration: %v", d)
+	}
+	if cli.group.Group != "g1" || cli.group.MsgId != "m1" {
+		t.Fatalf("modified message: %+v", cli.group)
+	}
+	if !strings.Contains(cli.card.Footer.Text, "Silenced 1h by 张三") {
+		t.Fatalf("card footer: %v", cli.card.Footer.Text)
+	}
+}
+
+func TestAck(t *testing.T) {
+	cli := new(fakeClient)
+	handler := NewHandler(NewGrafana("http://127.0.0.1:0", "token"), cli, nil)
+
+	card := WithButtons(interactive.Interactive{
+		Head:   &interactive.IAHead{Text: "HighCPU"},
+		Body:   &interactive.IABody{Content: "cpu > 90%", Image: "img1"},
+		Action: []*interactive.IAAction{{Text: "Dashboard"}},
+	}, Alert{Fingerprint: "abc"})
+	value, _ := json.Marshal(card.Value)
+	cm := &interactive.ConfirmMessage{
+		MsgId:  "m1",
+		User:   interactive.User{Account: "zhangsan", Name: "张三"},
+		Value:  value,
+		Action: []*interactive.CbAction{{Text: "Ack", Name: ActionAck}},
+	}
+	cm.Conv.Type = "group"
+	cm.Conv.Target = "g1"
+	handler(cm)
+
+	if cli.card.Footer.Text != "Acked by 张三" {
+		t.Fatalf("card footer: %v", cli.card.Footer.Text)
+	}
+	var texts []string
+	for _, btn := range cli.card.Action {
+		texts = append(texts, btn.Text)
+	}
+	// 原有链接按钮保留；Grafana不支持Resolve，不展示该按钮
+	if strings.Join(texts, ",") != "Dashboard,Silence 1h" {
+		t.Fatalf("card buttons: %v", texts)
+	}
+	if cli.card.Body.Image != "img1" {
+		t.Fatalf("card image: %+v", cli.card.Body)
+	}
+}
+
+func TestWithoutResolve(t *testing.T) {
+	card := WithoutResolve(Card(Alert{Fingerprint: "abc"}, "HighCPU", "cpu > 90%"))
+	for _, btn := range card.Action {
+		if btn.Name == ActionResolve {
+			t.Fatal("resolve button should be removed")
+		}
+	}
+	if v := card.Value.(cardValue); !v.NoResolve {
+		t.Fatalf("card value: %+v", v)
+	}
+}
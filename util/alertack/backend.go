@@ -0,0 +1,154 @@
+package alertack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backend为报警系统操作接口，用户点击卡片按钮时调用。
+type Backend interface {
+	// Ack确认报警，who为点击人域账号。
+	Ack(alert Alert, who string) error
+	// Silence静默报警d时长。
+	Silence(alert Alert, who string, d time.Duration) error
+	// Resolve手动恢复报警。
+	Resolve(alert Alert, who string) error
+}
+
+// Silencer通过Alertmanager v2 API（Grafana内置Alertmanager同样适用）实现Backend：
+//   - Ack：报警系统无对应操作，仅更新卡片；
+//   - Silence：按报警标签创建静默；
+//   - Resolve：向Alertmanager推送endsAt为当前时间的报警。Grafana不支持，返回errors.ErrUnsupported。
+type Silencer struct {
+	// 静默接口地址，如"http://alertmanager:9093/api/v2/silences"。
+	SilencesURL string
+
+	// 报警接口地址，如"http://alertmanager:9093/api/v2/alerts"，为空时不支持Resolve。
+	AlertsURL string
+
+	// 请求附带的头部，如Grafana的"Authorization: Bearer <token>"。
+	Header http.Header
+
+	// 默认使用http.DefaultClient。
+	Client *http.Client
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+}
+
+// NewAlertmanager以Alertmanager地址（如"http://alertmanager:9093"）新建Silencer。
+func NewAlertmanager(baseURL string) *Silencer {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &Silencer{
+		SilencesURL: baseURL + "/api/v2/silences",
+		AlertsURL:   baseURL + "/api/v2/alerts",
+	}
+}
+
+// NewGrafana以Grafana地址（如"http://grafana:3000"）及service account token新建Silencer，
+// 操作Grafana内置Alertmanager。Grafana不支持Resolve，卡片应使用WithoutResolve去掉"Resolve"按钮。
+func NewGrafana(baseURL, token string) *Silencer {
+	baseURL = strings.TrimRight(baseURL, "/")
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer "+token)
+	return &Silencer{
+		SilencesURL: baseURL + "/api/alertmanager/grafana/api/v2/silences",
+		Header:      header,
+	}
+}
+
+type matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// CanResolve返回是否支持Resolve，即AlertsURL不为空。
+// 不支持时，点击按钮后重建的卡片不再展示"Resolve"按钮。
+func (s *Silencer) CanResolve() bool {
+	return s.AlertsURL != ""
+}
+
+func (s *Silencer) Ack(Alert, string) error {
+	return nil
+}
+
+func (s *Silencer) Silence(alert Alert, who string, d time.Duration) error {
+	if len(alert.Labels) == 0 {
+		return errors.New("alertack: silence: alert has no labels")
+	}
+	matchers := make([]matcher, 0, len(alert.Labels))
+	for name, value := range alert.Labels {
+		matchers = append(matchers, matcher{Name: name, Value: value, IsEqual: true})
+	}
+	now := s.now()
+	args := map[string]any{
+		"matchers":  matchers,
+		"startsAt":  now,
+		"endsAt":    now.Add(d),
+		"createdBy": who,
+		"comment":   "silenced from tuitui by " + who,
+	}
+	return s.post(s.SilencesURL, args)
+}
+
+func (s *Silencer) Resolve(alert Alert, who string) error {
+	if s.AlertsURL == "" {
+		return fmt.Errorf("alertack: resolve: %w", errors.ErrUnsupported)
+	}
+	if len(alert.Labels) == 0 {
+		return errors.New("alertack: resolve: alert has no labels")
+	}
+	now := s.now()
+	args := []map[string]any{{
+		"labels":      alert.Labels,
+		"annotations": map[string]string{"resolved_by": who},
+		"startsAt":    now.Add(-time.Minute),
+		"endsAt":      now,
+	}}
+	return s.post(s.AlertsURL, args)
+}
+
+func (s *Silencer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Silencer) post(url string, args any) error {
+	p, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("alertack: post %v: json encode args: %w", url, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(p))
+	if err != nil {
+		return fmt.Errorf("alertack: post %v: new request: %w", url, err)
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := s.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	rsp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("alertack: post %v: do request: %w", url, err)
+	}
+	body, _ := io.ReadAll(io.LimitReader(rsp.Body, 4096))
+	rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("alertack: post %v: response http status: %v: %s", url, rsp.Status, body)
+	}
+	return nil
+}
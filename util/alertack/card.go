@@ -0,0 +1,100 @@
+package alertack
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/interactive"
+	"github.com/eachain/360-tuitui-robot/internal/timeutil"
+)
+
+// 按钮名称，即interactive.IAAction.Name。
+const (
+	ActionAck     = "ack"
+	ActionSilence = "silence"
+	ActionResolve = "resolve"
+)
+
+// 报警卡片对应的报警信息，随卡片发出，用户点击按钮时原样带回。
+type Alert struct {
+	Fingerprint string            `json:"fingerprint"`      // 报警指纹或分组键
+	Labels      map[string]string `json:"labels,omitempty"` // 报警标签，静默时作为匹配条件
+}
+
+// cardValue为interactive.Interactive.Value，用于点击按钮后重建卡片。
+type cardValue struct {
+	Alert     Alert                   `json:"alert"`
+	Title     string                  `json:"title,omitempty"`
+	Content   string                  `json:"content,omitempty"`
+	Image     string                  `json:"image,omitempty"`
+	Actions   []*interactive.IAAction `json:"actions,omitempty"`    // 卡片原有按钮，如链接按钮
+	NoResolve bool                    `json:"no_resolve,omitempty"` // 不展示"Resolve"按钮
+	Ack       string                  `json:"ack,omitempty"`        // 确认人
+}
+
+// Card生成带"Ack"、"Silence 1h"、"Resolve"按钮的报警卡片。
+func Card(alert Alert, title, content string) interactive.Interactive {
+	return WithButtons(interactive.Interactive{
+		Summary: title,
+		Head:    &interactive.IAHead{Text: title, BgColor: "FA5151", TColor: "FFFFFF"},
+		Body:    &interactive.IABody{Content: content},
+	}, alert)
+}
+
+// WithButtons为已有卡片加上"Ack"、"Silence 1h"、"Resolve"按钮。卡片原有按钮保留在前面。
+//
+// 注意：卡片的Value将被覆盖为报警信息。
+func WithButtons(card interactive.Interactive, alert Alert) interactive.Interactive {
+	v := cardValue{Alert: alert}
+	if card.Head != nil {
+		v.Title = card.Head.Text
+	}
+	if card.Body != nil {
+		v.Content = card.Body.Content
+		v.Image = card.Body.Image
+	}
+	v.Actions = card.Action
+	card.Value = v
+	card.Action = append(card.Action[:len(card.Action):len(card.Action)], buttons(time.Hour)...)
+	return card
+}
+
+// WithoutResolve去掉WithButtons添加的"Resolve"按钮，点击其它按钮后重建的卡片同样不含该按钮。
+// 用于不支持手动恢复的报警系统，如Grafana。
+func WithoutResolve(card interactive.Interactive) interactive.Interactive {
+	v, ok := card.Value.(cardValue)
+	if !ok {
+		return card
+	}
+	v.NoResolve = true
+	card.Value = v
+	actions := make([]*interactive.IAAction, 0, len(card.Action))
+	for _, btn := range card.Action {
+		if btn.Name != ActionResolve {
+			actions = append(actions, btn)
+		}
+	}
+	card.Action = actions
+	return card
+}
+
+func buttons(silence time.Duration) []*interactive.IAAction {
+	return []*interactive.IAAction{
+		{Text: "Ack", Name: ActionAck, BgColor: "#3873FA", Color: "FFFFFF"},
+		{Text: "Silence " + timeutil.ShortDuration(silence), Name: ActionSilence, Value: silence.String(), BorderColor: "#3873FA", Color: "3873FA"},
+		{Text: "Resolve", Name: ActionResolve, BorderColor: "#FA5151", Color: "FA5151"},
+	}
+}
+
+func decodeValue(raw json.RawMessage) (cardValue, error) {
+	var v cardValue
+	err := json.Unmarshal(raw, &v)
+	if err != nil {
+		// PC端可能将Value按字符串返回。
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			err = json.Unmarshal([]byte(s), &v)
+		}
+	}
+	return v, err
+}
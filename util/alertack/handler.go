@@ -0,0 +1,152 @@
+package alertack
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/interactive"
+	"github.com/eachain/360-tuitui-robot/internal/timeutil"
+)
+
+// Client为更新卡片所用的接口，*client.Client实现了该接口。
+type Client interface {
+	ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+}
+
+type Options struct {
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// NewHandler处理报警卡片按钮点击：调用backend执行确认/静默/恢复，并更新卡片展示操作人及结果。
+//
+// 用法：interactive.NewCallbackHandler(alertack.NewHandler(backend, cli, nil))。
+func NewHandler(backend Backend, cli Client, opts *Options) interactive.OnConfirmed {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+	errorf := func(format string, args ...any) {
+		if o.Errorf != nil {
+			o.Errorf(format, args...)
+		}
+	}
+
+	return func(cm *interactive.ConfirmMessage) {
+		var action *interactive.CbAction
+		for _, a := range cm.Action {
+			if a.Name == ActionAck || a.Name == ActionSilence || a.Name == ActionResolve {
+				action = a
+				break
+			}
+		}
+		if action == nil {
+			return
+		}
+
+		v, err := decodeValue(cm.Value)
+		if err != nil {
+			errorf("alertack: message %v: decode card value: %v", cm.MsgId, err)
+			return
+		}
+
+		who := cm.User.Account
+		name := cm.User.Name
+		if name == "" {
+			name = who
+		}
+
+		var footer string
+		resolved := false
+		switch action.Name {
+		case ActionAck:
+			err = backend.Ack(v.Alert, who)
+			if err == nil {
+				v.Ack = name
+				footer = "Acked by " + name
+			}
+		case ActionSilence:
+			d := decodeDuration(action.Value)
+			err = backend.Silence(v.Alert, who, d)
+			if err == nil {
+				footer = fmt.Sprintf("Silenced %v by %v", timeutil.ShortDuration(d), name)
+			}
+		case ActionResolve:
+			err = backend.Resolve(v.Alert, who)
+			if err == nil {
+				resolved = true
+				footer = "Resolved by " + name
+			}
+		}
+		if err != nil {
+			errorf("alertack: alert %v: %v by %v: %v", v.Alert.Fingerprint, action.Name, who, err)
+			footer = fmt.Sprintf("%v failed: %v", action.Text, err)
+		}
+
+		card := rebuild(v, footer, resolved, canResolve(backend), o.Now())
+		switch cm.Conv.Type {
+		case "single":
+			err = cli.ModifyUserMessage(client.UserMsgIdPair{User: who, MsgId: cm.MsgId}, card, nil)
+		case "group":
+			err = cli.ModifyGroupMessage(client.GroupMsgIdPair{Group: cm.Conv.Target, MsgId: cm.MsgId}, card, nil)
+		default:
+			return
+		}
+		if err != nil {
+			errorf("alertack: alert %v: update card %v: %v", v.Alert.Fingerprint, cm.MsgId, err)
+		}
+	}
+}
+
+func rebuild(v cardValue, footer string, resolved, resolvable bool, now time.Time) interactive.Interactive {
+	card := interactive.Interactive{
+		Summary: v.Title,
+		Value:   v,
+		Head:    &interactive.IAHead{Text: v.Title, BgColor: "FA5151", TColor: "FFFFFF"},
+		Body:    &interactive.IABody{Content: v.Content, Image: v.Image},
+		Footer:  &interactive.IAFooter{Text: footer, Ts: now.Unix()},
+		Action:  v.Actions[:len(v.Actions):len(v.Actions)],
+	}
+	if resolved {
+		card.Head.BgColor = "14CC89"
+		return card
+	}
+	for _, btn := range buttons(time.Hour) {
+		if btn.Name == ActionAck && v.Ack != "" {
+			continue
+		}
+		if btn.Name == ActionResolve && (v.NoResolve || !resolvable) {
+			continue
+		}
+		card.Action = append(card.Action, btn)
+	}
+	return card
+}
+
+// canResolve判断backend是否支持Resolve，backend可实现CanResolve() bool，默认支持。
+func canResolve(backend Backend) bool {
+	if r, ok := backend.(interface{ CanResolve() bool }); ok {
+		return r.CanResolve()
+	}
+	return true
+}
+
+func decodeDuration(raw json.RawMessage) time.Duration {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			return d
+		}
+	}
+	return time.Hour
+}
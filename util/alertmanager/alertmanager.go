@@ -0,0 +1,244 @@
+package alertmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/tracker"
+)
+
+// Client为Receiver发消息所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+}
+
+// 消息格式。
+const (
+	FormatText  = "text"  // message.Text，默认值
+	FormatMixed = "mixed" // message.Mixed
+	FormatPage  = "page"  // message.Page，Template按html模板渲染
+)
+
+// 默认文本模板，模板参数为*Payload。
+const DefaultTemplate = `[{{.Status | upper}}{{if eq .Status "firing"}}:{{len .Alerts.Firing}}{{end}}] {{.CommonLabels.alertname}}
+{{range .Alerts}}
+[{{.Status}}] {{.Labels.alertname}}
+{{range .Labels.SortedPairs}}  {{.Name}}: {{.Value}}
+{{end}}{{range .Annotations.SortedPairs}}  {{.Name}}: {{.Value}}
+{{end}}  startsAt: {{.StartsAt.Format "2006-01-02 15:04:05"}}
+{{end}}{{if .TruncatedAlerts}}
+...and {{.TruncatedAlerts}} more alerts
+{{end}}`
+
+// 默认页面模板，模板参数为*Payload。
+const DefaultPageTemplate = `{{range .Alerts}}<h3>[{{.Status}}] {{.Labels.alertname}}</h3>
+<p>{{range .Labels.SortedPairs}}{{.Name}}: {{.Value}}<br/>{{end}}{{range .Annotations.SortedPairs}}{{.Name}}: {{.Value}}<br/>{{end}}startsAt: {{.StartsAt.Format "2006-01-02 15:04:05"}}</p>
+{{if .GeneratorURL}}<p><a href="{{.GeneratorURL}}">source</a></p>{{end}}
+{{end}}{{if .TruncatedAlerts}}<p>...and {{.TruncatedAlerts}} more alerts</p>{{end}}`
+
+// 默认页面标题模板，模板参数为*Payload。
+const DefaultTitleTemplate = `[{{.Status | upper}}{{if eq .Status "firing"}}:{{len .Alerts.Firing}}{{end}}] {{.CommonLabels.alertname}}`
+
+type Options struct {
+	// 按顺序匹配的路由，匹配标签为CommonLabels。
+	Routes Routes
+
+	// 没有路由匹配时的默认接收方。
+	Default Recipients
+
+	// 消息格式，可选值有FormatText(默认)、FormatMixed、FormatPage。
+	Format string
+
+	// 消息内容模板，模板参数为*Payload，可用函数见Funcs。
+	// 默认为DefaultTemplate，FormatPage默认为DefaultPageTemplate。
+	Template string
+
+	// 页面标题模板，仅FormatPage有效，默认为DefaultTitleTemplate。
+	TitleTemplate string
+
+	// 如果不为nil，用Payload.GroupKey跟踪报警消息：同一分组的后续通知修改原消息，而不是发送新消息。
+	// FormatPage时由Tracker按页面消息发送并记录页面id，后续修改带上原页面id；群页面消息不支持AtUsers。
+	Tracker *tracker.Tracker
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Funcs为模板可用函数。
+var Funcs = map[string]any{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, s []string) string {
+		return strings.Join(s, sep)
+	},
+	"since": func(t time.Time) string {
+		return time.Since(t).Truncate(time.Second).String()
+	},
+}
+
+type executor interface {
+	Execute(io.Writer, any) error
+}
+
+// Receiver接收Alertmanager webhook通知，按路由渲染并发送推推消息。
+type Receiver struct {
+	cli   Client
+	opts  Options
+	tmpl  executor
+	title executor
+}
+
+// New新建Receiver，*Options可以为空（详见Options定义/默认值）。
+func New(cli Client, opts *Options) (*Receiver, error) {
+	r := &Receiver{cli: cli}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Format == "" {
+		r.opts.Format = FormatText
+	}
+	if err := r.opts.Routes.Compile(); err != nil {
+		return nil, fmt.Errorf("alertmanager: %w", err)
+	}
+
+	var err error
+	switch r.opts.Format {
+	case FormatText, FormatMixed:
+		text := r.opts.Template
+		if text == "" {
+			text = DefaultTemplate
+		}
+		r.tmpl, err = template.New("alertmanager").Funcs(Funcs).Parse(text)
+	case FormatPage:
+		text := r.opts.Template
+		if text == "" {
+			text = DefaultPageTemplate
+		}
+		r.tmpl, err = htmltemplate.New("alertmanager").Funcs(Funcs).Parse(text)
+		if err != nil {
+			break
+		}
+		text = r.opts.TitleTemplate
+		if text == "" {
+			text = DefaultTitleTemplate
+		}
+		r.title, err = template.New("title").Funcs(Funcs).Parse(text)
+	default:
+		return nil, fmt.Errorf("alertmanager: unknown format %q", r.opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("alertmanager: parse template: %w", err)
+	}
+	return r, nil
+}
+
+// ServeHTTP实现http.Handler。发送失败时返回500，Alertmanager会稍后重试。
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	payload := new(Payload)
+	err := json.NewDecoder(req.Body).Decode(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		r.errorf("alertmanager: json decode request body: %v", err)
+		return
+	}
+
+	err = r.Notify(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		r.errorf("%v", err)
+	}
+}
+
+// Notify路由、渲染并发送一次通知。
+func (r *Receiver) Notify(payload *Payload) error {
+	rc := r.opts.Routes.Match(payload.CommonLabels)
+	if rc.Empty() {
+		rc = r.opts.Default
+	}
+	if rc.Empty() {
+		return nil
+	}
+
+	msg, err := r.Render(payload)
+	if err != nil {
+		return err
+	}
+
+	if r.opts.Tracker != nil && payload.GroupKey != "" {
+		target := tracker.Target{Users: rc.Users, Groups: rc.Groups, AtUsers: rc.AtUsers}
+		if payload.Status == StatusResolved {
+			err = r.opts.Tracker.Resolve(payload.GroupKey, target, msg)
+		} else {
+			err = r.opts.Tracker.Fire(payload.GroupKey, target, msg)
+		}
+		if err != nil {
+			return fmt.Errorf("alertmanager: group %v: %w", payload.GroupKey, err)
+		}
+		return nil
+	}
+
+	var errs []error
+	if len(rc.Users) > 0 {
+		_, warn, err := r.cli.SendMessageToUsers(rc.Users, msg)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			r.errorf("alertmanager: group %v: send to users: %v", payload.GroupKey, warn.Explains)
+		}
+	}
+	if len(rc.Groups) > 0 {
+		_, warn, err := r.cli.SendMessageToGroups(rc.Groups, rc.AtUsers, msg)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			r.errorf("alertmanager: group %v: send to groups: %v", payload.GroupKey, warn.Explains)
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("alertmanager: group %v: %w", payload.GroupKey, err)
+	}
+	return nil
+}
+
+// Render按Options.Format及模板渲染消息。
+func (r *Receiver) Render(payload *Payload) (client.Message, error) {
+	buf := new(bytes.Buffer)
+	err := r.tmpl.Execute(buf, payload)
+	if err != nil {
+		return nil, fmt.Errorf("alertmanager: execute template: %w", err)
+	}
+	content := strings.TrimSpace(buf.String())
+
+	switch r.opts.Format {
+	case FormatMixed:
+		return message.NewMixed().WithText(content), nil
+	case FormatPage:
+		buf.Reset()
+		err = r.title.Execute(buf, payload)
+		if err != nil {
+			return nil, fmt.Errorf("alertmanager: execute title template: %w", err)
+		}
+		title := strings.TrimSpace(buf.String())
+		return message.NewPage().WithTitle(title).WithSummary(title).WithContent(content), nil
+	default:
+		return message.NewText(content), nil
+	}
+}
+
+func (r *Receiver) errorf(format string, args ...any) {
+	if r.opts.Errorf != nil {
+		r.opts.Errorf(format, args...)
+	}
+}
@@ -0,0 +1,136 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/tracker"
+)
+
+const payloadV4 = `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"HighLatency\"}",
+  "truncatedAlerts": 2,
+  "status": "firing",
+  "receiver": "tuitui",
+  "groupLabels": {"alertname": "HighLatency"},
+  "commonLabels": {"alertname": "HighLatency", "severity": "critical", "service": "api"},
+  "commonAnnotations": {"summary": "latency too high"},
+  "externalURL": "http://alertmanager:9093",
+  "alerts": [{
+    "status": "firing",
+    "labels": {"alertname": "HighLatency", "instance": "10.0.0.1:9100"},
+    "annotations": {"summary": "p99 > 1s"},
+    "startsAt": "2024-06-01T10:00:00Z",
+    "endsAt": "0001-01-01T00:00:00Z",
+    "generatorURL": "http://prometheus/graph",
+    "fingerprint": "c6a1a0e1"
+  }]
+}`
+
+func TestRoutesMatch(t *testing.T) {
+	routes := Routes{
+		{Match: map[string]string{"severity": "critical"}, Users: []string{"oncall"}, Continue: true},
+		{MatchRE: map[string]string{"service": "api|web"}, Groups: []string{"g1"}},
+		{Groups: []string{"g2"}},
+	}
+	if err := routes.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	rc := routes.Match(KV{"severity": "critical", "service": "api"})
+	if len(rc.Users) != 1 || len(rc.Groups) != 1 || rc.Groups[0] != "g1" {
+		t.Fatalf("critical api: %+v", rc)
+	}
+
+	rc = routes.Match(KV{"severity": "warning", "service": "apiserver"})
+	if len(rc.Users) != 0 || len(rc.Groups) != 1 || rc.Groups[0] != "g2" {
+		t.Fatalf("warning apiserver: %+v", rc)
+	}
+}
+
+func TestRender(t *testing.T) {
+	var payload Payload
+	if err := json.Unmarshal([]byte(payloadV4), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := r.Render(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := msg.(message.Text).Content
+	for _, s := range []string{"[FIRING:1] HighLatency", "instance: 10.0.0.1:9100", "...and 2 more alerts"} {
+		if !strings.Contains(text, s) {
+			t.Fatalf("rendered text missing %q:\n%v", s, text)
+		}
+	}
+
+	r, err = New(nil, &Options{Format: FormatPage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err = r.Render(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := msg.(message.Page)
+	if page.Title != "[FIRING:1] HighLatency" || !strings.Contains(page.Content, "<h3>") {
+		t.Fatalf("rendered page: %+v", page)
+	}
+}
+
+type pageClient struct {
+	Client
+	modified []client.Message
+}
+
+func (c *pageClient) SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error) {
+	return "p1", []client.UserMsgIdPair{{User: users[0], MsgId: "m1"}}, nil, nil
+}
+
+func (c *pageClient) SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error) {
+	return "p2", []client.GroupMsgIdPair{{Group: groupIds[0], MsgId: "m2"}}, nil, nil
+}
+
+func (c *pageClient) ModifyUserMessages(msgids []client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) ([]client.UserMsgIdPair, *client.Warning[client.UserMsgIdPair], error) {
+	c.modified = append(c.modified, msg)
+	return msgids, nil, nil
+}
+
+func (c *pageClient) ModifyGroupMessages(msgids []client.GroupMsgIdPair, atUsers []string, msg client.Message, opt *client.ModifyOptions) ([]client.GroupMsgIdPair, *client.Warning[client.GroupMsgIdPair], error) {
+	c.modified = append(c.modified, msg)
+	return msgids, nil, nil
+}
+
+func TestNotifyPageTracker(t *testing.T) {
+	var payload Payload
+	if err := json.Unmarshal([]byte(payloadV4), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	cli := new(pageClient)
+	r, err := New(cli, &Options{
+		Format:  FormatPage,
+		Default: Recipients{Users: []string{"zhangsan"}},
+		Tracker: tracker.New(cli, tracker.NewMemStore(), nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err = r.Notify(&payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(cli.modified) != 1 || cli.modified[0].(message.Page).PageId != "p1" {
+		t.Fatalf("modified: %#v", cli.modified)
+	}
+}
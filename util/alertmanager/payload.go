@@ -0,0 +1,93 @@
+package alertmanager
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// KV为标签/注解集合。
+type KV map[string]string
+
+type Pair struct {
+	Name  string
+	Value string
+}
+
+// SortedPairs按名称排序返回所有键值对，便于在模板中稳定输出。
+func (kv KV) SortedPairs() []Pair {
+	pairs := make([]Pair, 0, len(kv))
+	for name, value := range kv {
+		pairs = append(pairs, Pair{Name: name, Value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Name < pairs[j].Name
+	})
+	return pairs
+}
+
+// Remove返回去掉names后的副本。
+func (kv KV) Remove(names ...string) KV {
+	cp := make(KV, len(kv))
+	for name, value := range kv {
+		cp[name] = value
+	}
+	for _, name := range names {
+		delete(cp, name)
+	}
+	return cp
+}
+
+type Alert struct {
+	Status       string    `json:"status"` // firing, resolved
+	Labels       KV        `json:"labels"`
+	Annotations  KV        `json:"annotations"`
+	StartsAt     time.Time `json:"startsAt"`
+	EndsAt       time.Time `json:"endsAt"`
+	GeneratorURL string    `json:"generatorURL"`
+	Fingerprint  string    `json:"fingerprint"`
+}
+
+type Alerts []Alert
+
+// Firing返回仍在触发的报警。
+func (as Alerts) Firing() Alerts {
+	var firing Alerts
+	for _, a := range as {
+		if a.Status == StatusFiring {
+			firing = append(firing, a)
+		}
+	}
+	return firing
+}
+
+// Resolved返回已恢复的报警。
+func (as Alerts) Resolved() Alerts {
+	var resolved Alerts
+	for _, a := range as {
+		if a.Status == StatusResolved {
+			resolved = append(resolved, a)
+		}
+	}
+	return resolved
+}
+
+// Alertmanager webhook请求内容，version 4。
+//
+// 文档：https://prometheus.io/docs/alerting/latest/configuration/#webhook_config。
+type Payload struct {
+	Version           string `json:"version"`
+	GroupKey          string `json:"groupKey"`        // 报警分组键，同一分组的后续通知相同
+	TruncatedAlerts   int    `json:"truncatedAlerts"` // 因max_alerts限制被截断的报警数量
+	Status            string `json:"status"`          // firing, resolved，分组内有任一报警触发即为firing
+	Receiver          string `json:"receiver"`
+	GroupLabels       KV     `json:"groupLabels"`
+	CommonLabels      KV     `json:"commonLabels"`
+	CommonAnnotations KV     `json:"commonAnnotations"`
+	ExternalURL       string `json:"externalURL"`
+	Alerts            Alerts `json:"alerts"`
+}
@@ -0,0 +1,97 @@
+package alertmanager
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/eachain/360-tuitui-robot/internal/strutil"
+)
+
+// 按标签选择报警接收方。Match和MatchRE均为空时匹配所有报警。
+type Route struct {
+	Match   map[string]string `json:"match,omitempty"`    // 标签值完全相等
+	MatchRE map[string]string `json:"match_re,omitempty"` // 标签值完全匹配正则表达式
+
+	Users   []string `json:"users,omitempty"`    // 单聊域账号列表
+	Groups  []string `json:"groups,omitempty"`   // 群id列表
+	AtUsers []string `json:"at_users,omitempty"` // 群消息@列表，如果需要@所有人，传["@all"]
+
+	// 匹配成功后是否继续匹配后续Route。默认为false，即匹配到第一个Route后停止。
+	Continue bool `json:"continue,omitempty"`
+
+	re map[string]*regexp.Regexp
+}
+
+// Compile预编译MatchRE，Matches前必须调用。
+func (r *Route) Compile() error {
+	r.re = make(map[string]*regexp.Regexp, len(r.MatchRE))
+	for name, expr := range r.MatchRE {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return fmt.Errorf("route match_re %v: %w", name, err)
+		}
+		r.re[name] = re
+	}
+	return nil
+}
+
+// Matches判断labels是否匹配该路由。
+func (r *Route) Matches(labels KV) bool {
+	for name, value := range r.Match {
+		if labels[name] != value {
+			return false
+		}
+	}
+	for name, re := range r.re {
+		if !re.MatchString(labels[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Recipients为路由结果，即最终接收方（已去重）。
+type Recipients struct {
+	Users   []string
+	Groups  []string
+	AtUsers []string
+}
+
+// Add添加接收方，自动去重。
+func (rc *Recipients) Add(users, groups, atUsers []string) {
+	rc.Users = strutil.AppendUnique(rc.Users, users...)
+	rc.Groups = strutil.AppendUnique(rc.Groups, groups...)
+	rc.AtUsers = strutil.AppendUnique(rc.AtUsers, atUsers...)
+}
+
+func (rc Recipients) Empty() bool {
+	return len(rc.Users) == 0 && len(rc.Groups) == 0
+}
+
+// Routes为按顺序匹配的路由列表。
+type Routes []*Route
+
+// Compile预编译所有正则表达式，使用Routes前必须调用。
+func (rs Routes) Compile() error {
+	for i, r := range rs {
+		if err := r.Compile(); err != nil {
+			return fmt.Errorf("routes[%v]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Match返回labels匹配的所有接收方，没有匹配时返回空Recipients。
+func (rs Routes) Match(labels KV) Recipients {
+	var rc Recipients
+	for _, r := range rs {
+		if !r.Matches(labels) {
+			continue
+		}
+		rc.Add(r.Users, r.Groups, r.AtUsers)
+		if !r.Continue {
+			break
+		}
+	}
+	return rc
+}
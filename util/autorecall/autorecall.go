@@ -0,0 +1,366 @@
+package autorecall
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/internal/fileutil"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Client为自动撤回所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error)
+	SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error)
+	ModifyUserMessages(msgids []client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) ([]client.UserMsgIdPair, *client.Warning[client.UserMsgIdPair], error)
+	ModifyGroupMessages(msgids []client.GroupMsgIdPair, atUsers []string, msg client.Message, opt *client.ModifyOptions) ([]client.GroupMsgIdPair, *client.Warning[client.GroupMsgIdPair], error)
+}
+
+// Pending为待撤回的消息。
+type Pending struct {
+	Id          string                  `json:"id"`
+	At          time.Time               `json:"at"` // 撤回时间
+	UserMsgIds  []client.UserMsgIdPair  `json:"user_msgids,omitempty"`
+	GroupMsgIds []client.GroupMsgIdPair `json:"group_msgids,omitempty"`
+	PageId      string                  `json:"page_id,omitempty"` // 页面消息id，不为空时按页面消息撤回
+	Attempts    int                     `json:"attempts,omitempty"`
+	LastError   string                  `json:"last_error,omitempty"`
+}
+
+func (p *Pending) recall() message.Recall {
+	if p.PageId != "" {
+		return message.NewRecall().WithPageId(p.PageId)
+	}
+	return message.NewRecall()
+}
+
+type Options struct {
+	// 撤回失败后重试间隔，默认为1分钟。
+	RetryInterval time.Duration
+
+	// 最大尝试次数，超过后放弃撤回，默认为5。
+	MaxAttempts int
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Recaller发送消息并在ttl后自动撤回。待撤回消息保存在本地文件中，进程重启后继续撤回。
+type Recaller struct {
+	cli  Client
+	path string
+	opts Options
+
+	mu      sync.Mutex
+	pending map[string]*Pending
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open打开（或新建）path文件保存待撤回消息，并启动后台撤回。*Options可以为空（详见Options定义/默认值）。
+func Open(path string, cli Client, opts *Options) (*Recaller, error) {
+	r := &Recaller{
+		cli:     cli,
+		path:    path,
+		pending: make(map[string]*Pending),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.RetryInterval <= 0 {
+		r.opts.RetryInterval = time.Minute
+	}
+	if r.opts.MaxAttempts <= 0 {
+		r.opts.MaxAttempts = 5
+	}
+	if r.opts.Now == nil {
+		r.opts.Now = time.Now
+	}
+
+	p, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("autorecall: read %v: %w", path, err)
+	}
+	if len(p) > 0 {
+		var list []*Pending
+		if err = json.Unmarshal(p, &list); err != nil {
+			return nil, fmt.Errorf("autorecall: parse %v: %w", path, err)
+		}
+		for _, pd := range list {
+			r.pending[pd.Id] = pd
+		}
+	}
+
+	go r.loop()
+	return r, nil
+}
+
+func (r *Recaller) errorf(format string, args ...any) {
+	if r.opts.Errorf != nil {
+		r.opts.Errorf(format, args...)
+	}
+}
+
+// 发送单聊消息，ttl后撤回。
+func (r *Recaller) SendMessageToUsers(users []string, msg client.Message, ttl time.Duration) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	pairs, warn, err := r.cli.SendMessageToUsers(users, msg)
+	if len(pairs) > 0 {
+		if _, serr := r.Schedule(&Pending{At: r.opts.Now().Add(ttl), UserMsgIds: pairs}); serr != nil {
+			r.errorf("%v", serr)
+		}
+	}
+	return pairs, warn, err
+}
+
+// 发送单聊消息，ttl后撤回。返回消息id。
+func (r *Recaller) SendMessageToUser(user string, msg client.Message, ttl time.Duration) (string, error) {
+	pairs, warn, err := r.SendMessageToUsers([]string{user}, msg, ttl)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", fmt.Errorf("send message to user %v failed", user)
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 发送群聊消息，ttl后撤回。
+func (r *Recaller) SendMessageToGroups(groupIds, atUsers []string, msg client.Message, ttl time.Duration) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	pairs, warn, err := r.cli.SendMessageToGroups(groupIds, atUsers, msg)
+	if len(pairs) > 0 {
+		if _, serr := r.Schedule(&Pending{At: r.opts.Now().Add(ttl), GroupMsgIds: pairs}); serr != nil {
+			r.errorf("%v", serr)
+		}
+	}
+	return pairs, warn, err
+}
+
+// 发送群聊消息，ttl后撤回。返回消息id。
+func (r *Recaller) SendMessageToGroup(groupId string, msg client.Message, ttl time.Duration) (string, error) {
+	pairs, warn, err := r.SendMessageToGroups([]string{groupId}, nil, msg, ttl)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", fmt.Errorf("send message to group %v failed", groupId)
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 发送推推页面消息，ttl后撤回。
+func (r *Recaller) SendPageToUsers(users []string, msg client.Message, ttl time.Duration) (string, []client.UserMsgIdPair, *client.Warning[string], error) {
+	pageId, pairs, warn, err := r.cli.SendPageToUsers(users, msg)
+	if len(pairs) > 0 {
+		if _, serr := r.Schedule(&Pending{At: r.opts.Now().Add(ttl), UserMsgIds: pairs, PageId: pageId}); serr != nil {
+			r.errorf("%v", serr)
+		}
+	}
+	return pageId, pairs, warn, err
+}
+
+// 发送推推页面消息，ttl后撤回。
+func (r *Recaller) SendPageToGroups(groupIds []string, msg client.Message, ttl time.Duration) (string, []client.GroupMsgIdPair, *client.Warning[string], error) {
+	pageId, pairs, warn, err := r.cli.SendPageToGroups(groupIds, msg)
+	if len(pairs) > 0 {
+		if _, serr := r.Schedule(&Pending{At: r.opts.Now().Add(ttl), GroupMsgIds: pairs, PageId: pageId}); serr != nil {
+			r.errorf("%v", serr)
+		}
+	}
+	return pageId, pairs, warn, err
+}
+
+// Schedule登记已发送消息的撤回时间p.At，用于撤回非本包发送的消息。p.Id为空时自动生成。返回p.Id。
+func (r *Recaller) Schedule(p *Pending) (string, error) {
+	cp := *p
+	if cp.Id == "" {
+		var b [8]byte
+		rand.Read(b[:])
+		cp.Id = hex.EncodeToString(b[:])
+	}
+
+	r.mu.Lock()
+	r.pending[cp.Id] = &cp
+	err := r.save()
+	r.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return cp.Id, nil
+}
+
+// Pending返回所有待撤回消息，按撤回时间排序。
+func (r *Recaller) Pending() []Pending {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Pending, 0, len(r.pending))
+	for _, p := range r.pending {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].At.Before(list[j].At) })
+	return list
+}
+
+// Close停止后台撤回，未撤回的消息仍保存在文件中，下次Open后继续撤回。
+func (r *Recaller) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+func (r *Recaller) loop() {
+	defer close(r.done)
+	for {
+		next := r.recallDue()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(next.Sub(r.opts.Now()))
+			timeout = timer.C
+		}
+		select {
+		case <-r.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-r.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// recallDue撤回所有到期消息，返回下一次撤回时间。
+func (r *Recaller) recallDue() time.Time {
+	now := r.opts.Now()
+
+	r.mu.Lock()
+	var due []Pending
+	for _, p := range r.pending {
+		if !p.At.After(now) {
+			due = append(due, *p)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, p := range due {
+		r.recall(p, now)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(due) > 0 {
+		if err := r.save(); err != nil {
+			r.errorf("%v", err)
+		}
+	}
+	var next time.Time
+	for _, p := range r.pending {
+		if next.IsZero() || p.At.Before(next) {
+			next = p.At
+		}
+	}
+	return next
+}
+
+// recall撤回p，仅保留失败的消息重试。
+func (r *Recaller) recall(p Pending, now time.Time) {
+	msg := p.recall()
+	var errs []error
+
+	if len(p.UserMsgIds) > 0 {
+		_, warn, err := r.cli.ModifyUserMessages(p.UserMsgIds, msg, nil)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case warn != nil:
+			p.UserMsgIds = warn.Fails
+			errs = append(errs, warn.Explains)
+		default:
+			p.UserMsgIds = nil
+		}
+	}
+	if len(p.GroupMsgIds) > 0 {
+		_, warn, err := r.cli.ModifyGroupMessages(p.GroupMsgIds, nil, msg, nil)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case warn != nil:
+			p.GroupMsgIds = warn.Fails
+			errs = append(errs, warn.Explains)
+		default:
+			p.GroupMsgIds = nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending[p.Id] == nil {
+		return
+	}
+	err := errors.Join(errs...)
+	if err == nil || (len(p.UserMsgIds) == 0 && len(p.GroupMsgIds) == 0) {
+		delete(r.pending, p.Id)
+		return
+	}
+	p.Attempts++
+	p.LastError = err.Error()
+	if p.Attempts >= r.opts.MaxAttempts {
+		r.errorf("autorecall: %v: give up after %v attempts: %v", p.Id, p.Attempts, err)
+		delete(r.pending, p.Id)
+		return
+	}
+	r.errorf("autorecall: %v: attempt %v: %v", p.Id, p.Attempts, err)
+	p.At = now.Add(r.opts.RetryInterval)
+	r.pending[p.Id] = &p
+}
+
+// save将待撤回消息写回文件。调用方需持有r.mu。
+func (r *Recaller) save() error {
+	list := make([]*Pending, 0, len(r.pending))
+	for _, p := range r.pending {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	p, err := json.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("autorecall: json encode: %w", err)
+	}
+
+	if err = fileutil.WriteFile(r.path, p); err != nil {
+		return fmt.Errorf("autorecall: save: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package autorecall
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	recalled []string
+	page     string
+}
+
+func (c *fakeClient) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	return []client.UserMsgIdPair{{User: users[0], MsgId: "u1"}}, nil, nil
+}
+
+func (c *fakeClient) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	return []client.GroupMsgIdPair{{Group: groupIds[0], MsgId: "g1"}}, nil, nil
+}
+
+func (c *fakeClient) SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error) {
+	return "p1", []client.UserMsgIdPair{{User: users[0], MsgId: "u2"}}, nil, nil
+}
+
+func (c *fakeClient) SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error) {
+	return "p2", nil, nil, nil
+}
+
+func (c *fakeClient) ModifyUserMessages(msgids []client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) ([]client.UserMsgIdPair, *client.Warning[client.UserMsgIdPair], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range msgids {
+		c.recalled = append(c.recalled, id.MsgId)
+	}
+	c.page = msg.(message.Recall).PageId
+	return msgids, nil, nil
+}
+
+func (c *fakeClient) ModifyGroupMessages(msgids []client.GroupMsgIdPair, atUsers []string, msg client.Message, opt *client.ModifyOptions) ([]client.GroupMsgIdPair, *client.Warning[client.GroupMsgIdPair], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range msgids {
+		c.recalled = append(c.recalled, id.MsgId)
+	}
+	return msgids, nil, nil
+}
+
+func (c *fakeClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.recalled)
+}
+
+func TestRecall(t *testing.T) {
+	cli := new(fakeClient)
+	r, err := Open(filepath.Join(t.TempDir(), "recall.json"), cli, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err = r.SendMessageToGroup("g", message.NewText("deploying now…"), 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && cli.count() == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if cli.count() != 1 || len(r.Pending()) != 0 {
+		t.Fatalf("recalled: %v, pending: %v", cli.recalled, r.Pending())
+	}
+}
+
+func TestPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recall.json")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := &Options{Now: func() time.Time { return now }}
+
+	cli := new(fakeClient)
+	r, err := Open(path, cli, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SendPageToUsers([]string{"zhangsan"}, message.NewPage().WithTitle("temporary password"), time.Hour)
+	r.Close()
+	if cli.count() != 0 {
+		t.Fatalf("recalled before ttl: %v", cli.recalled)
+	}
+
+	// 重启后，到期消息继续撤回。
+	later := now.Add(2 * time.Hour)
+	r, err = Open(path, cli, &Options{Now: func() time.Time { return later }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for i := 0; i < 100 && cli.count() == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if len(cli.recalled) != 1 || cli.recalled[0] != "u2" || cli.page != "p1" {
+		t.Fatalf("recalled: %v, page: %v", cli.recalled, cli.page)
+	}
+}
@@ -0,0 +1,285 @@
+package dedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/internal/timeutil"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Sender为去重所用的接口，*client.Client实现了该接口。
+type Sender interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+}
+
+// 去重窗口结束时，重复次数的汇报方式。
+const (
+	// 引用原消息，发送"重复N次"文本消息。
+	SummarySend = "send"
+
+	// 修改原消息（不推送），在末尾追加"重复N次"。仅适用于文本消息，其它消息仍使用SummarySend。
+	SummaryEdit = "edit"
+)
+
+type Options struct {
+	// 去重窗口，从第一次发送开始计时，窗口内相同接收方的相同消息只发送一次。默认为1分钟。
+	Window time.Duration
+
+	// SummarySend或SummaryEdit，默认为SummarySend。
+	Summary string
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+type entry struct {
+	user    string
+	group   string
+	msgid   string
+	msg     client.Message
+	repeats int
+	timer   *time.Timer
+}
+
+// Client在发送前按"接收方+消息内容"去重，窗口内的重复消息不发送，窗口结束时汇报重复次数。
+//
+// 被抑制的接收方不在返回值中，Warning.Fails也不包含。
+type Client struct {
+	cli  Sender
+	opts Options
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// New新建去重发送客户端。*Options可以为空（详见Options定义/默认值）。
+func New(cli Sender, opts *Options) *Client {
+	c := &Client{
+		cli:     cli,
+		entries: make(map[string]*entry),
+	}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Window <= 0 {
+		c.opts.Window = time.Minute
+	}
+	if c.opts.Summary == "" {
+		c.opts.Summary = SummarySend
+	}
+	return c
+}
+
+func (c *Client) errorf(format string, args ...any) {
+	if c.opts.Errorf != nil {
+		c.opts.Errorf(format, args...)
+	}
+}
+
+// hash计算消息内容摘要，消息类型不同视为不同消息。
+func hash(msg client.Message, extra ...string) string {
+	h := sha256.New()
+	p, _ := json.Marshal(msg)
+	h.Write([]byte(msg.Type() + "\x00" + msg.Index() + "\x00"))
+	h.Write(p)
+	for _, s := range extra {
+		h.Write([]byte("\x00" + s))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// suppress判断key是否在窗口内已发送或正在发送，是则累计重复次数；
+// 否则以e占位，发送后由sent记录结果，避免并发发送相同消息。
+func (c *Client) suppress(key string, e *entry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.entries[key]; old != nil {
+		old.repeats++
+		return true
+	}
+	c.entries[key] = e
+	return false
+}
+
+// sent记录占位e的发送结果：成功时开始计时去重窗口，失败（msgid为空）时删除占位，之后的相同消息可重新发送。
+func (c *Client) sent(key string, e *entry, msgid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[key] != e {
+		return // 已Close
+	}
+	if msgid == "" {
+		delete(c.entries, key)
+		if e.repeats > 0 {
+			c.errorf("dedup: send to %v%v failed, %v repeats dropped", e.user, e.group, e.repeats)
+		}
+		return
+	}
+	e.msgid = msgid
+	e.timer = time.AfterFunc(c.opts.Window, func() { c.expire(key) })
+}
+
+// 批量发送单聊消息，窗口内已发送过相同消息的用户不再发送。
+func (c *Client) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	sum := hash(msg)
+	reserved := make(map[string]*entry)
+	var pass []string
+	for _, user := range users {
+		e := &entry{user: user, msg: msg}
+		if !c.suppress("user:"+user+":"+sum, e) {
+			reserved[user] = e
+			pass = append(pass, user)
+		}
+	}
+	if len(pass) == 0 {
+		return nil, nil, nil
+	}
+
+	pairs, warn, err := c.cli.SendMessageToUsers(pass, msg)
+	for _, pair := range pairs {
+		if e := reserved[pair.User]; e != nil {
+			c.sent("user:"+pair.User+":"+sum, e, pair.MsgId)
+			delete(reserved, pair.User)
+		}
+	}
+	for user, e := range reserved {
+		c.sent("user:"+user+":"+sum, e, "")
+	}
+	return pairs, warn, err
+}
+
+// 发送单聊消息。被抑制时返回空消息id。
+func (c *Client) SendMessageToUser(user string, msg client.Message) (string, error) {
+	pairs, warn, err := c.SendMessageToUsers([]string{user}, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", nil
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 批量发送群聊消息，窗口内已发送过相同消息（含@列表）的群不再发送。
+func (c *Client) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	sum := hash(msg, atUsers...)
+	reserved := make(map[string]*entry)
+	var pass []string
+	for _, group := range groupIds {
+		e := &entry{group: group, msg: msg}
+		if !c.suppress("group:"+group+":"+sum, e) {
+			reserved[group] = e
+			pass = append(pass, group)
+		}
+	}
+	if len(pass) == 0 {
+		return nil, nil, nil
+	}
+
+	pairs, warn, err := c.cli.SendMessageToGroups(pass, atUsers, msg)
+	for _, pair := range pairs {
+		if e := reserved[pair.Group]; e != nil {
+			c.sent("group:"+pair.Group+":"+sum, e, pair.MsgId)
+			delete(reserved, pair.Group)
+		}
+	}
+	for group, e := range reserved {
+		c.sent("group:"+group+":"+sum, e, "")
+	}
+	return pairs, warn, err
+}
+
+// 发送群聊消息并@atUsers。被抑制时返回空消息id。
+func (c *Client) SendMessageToGroupAt(groupId string, atUsers []string, msg client.Message) (string, error) {
+	pairs, warn, err := c.SendMessageToGroups([]string{groupId}, atUsers, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", nil
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 发送群聊消息。被抑制时返回空消息id。
+func (c *Client) SendMessageToGroup(groupId string, msg client.Message) (string, error) {
+	return c.SendMessageToGroupAt(groupId, nil, msg)
+}
+
+func (c *Client) expire(key string) {
+	c.mu.Lock()
+	e := c.entries[key]
+	delete(c.entries, key)
+	c.mu.Unlock()
+	if e != nil && e.repeats > 0 {
+		c.summarize(e)
+	}
+}
+
+// Close停止所有去重窗口，并立即汇报重复次数。
+func (c *Client) Close() {
+	c.mu.Lock()
+	entries := c.entries
+	c.entries = make(map[string]*entry)
+	c.mu.Unlock()
+
+	for _, e := range entries {
+		if e.timer == nil {
+			continue // 正在发送
+		}
+		e.timer.Stop()
+		if e.repeats > 0 {
+			c.summarize(e)
+		}
+	}
+}
+
+func (c *Client) summarize(e *entry) {
+	note := fmt.Sprintf("%v内重复%v次", timeutil.ShortDuration(c.opts.Window), e.repeats)
+
+	if text, ok := e.msg.(message.Text); ok && c.opts.Summary == SummaryEdit {
+		text.Content += "\n（" + note + "）"
+		noPush := &client.ModifyOptions{WithoutPush: true}
+		var err error
+		if e.user != "" {
+			err = c.cli.ModifyUserMessage(client.UserMsgIdPair{User: e.user, MsgId: e.msgid}, text, noPush)
+		} else {
+			err = c.cli.ModifyGroupMessage(client.GroupMsgIdPair{Group: e.group, MsgId: e.msgid}, text, noPush)
+		}
+		if err == nil {
+			return
+		}
+		c.errorf("dedup: edit message %v: %v, fallback to send", e.msgid, err)
+	}
+
+	text := message.NewText("上条消息" + note).WithReference(e.msgid)
+	var err error
+	var warn *client.Warning[string]
+	if e.user != "" {
+		_, warn, err = c.cli.SendMessageToUsers([]string{e.user}, text)
+	} else {
+		_, warn, err = c.cli.SendMessageToGroups([]string{e.group}, nil, text)
+	}
+	if err == nil && warn != nil {
+		err = warn.Explains
+	}
+	if err != nil {
+		c.errorf("dedup: send summary of %v to %v%v: %v", e.msgid, e.user, e.group, err)
+	}
+}
@@ -0,0 +1,128 @@
+package dedup
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type fakeSender struct {
+	mu       sync.Mutex
+	sent     []message.Text
+	modified []message.Text
+
+	users  []string
+	fail   error
+	during func() // 单聊消息发送过程中调用，模拟并发发送
+}
+
+func (s *fakeSender) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	if during := s.during; during != nil {
+		s.during = nil
+		during()
+	}
+	if s.fail != nil {
+		return nil, nil, s.fail
+	}
+	var pairs []client.UserMsgIdPair
+	for _, user := range users {
+		s.users = append(s.users, user)
+		pairs = append(pairs, client.UserMsgIdPair{User: user, MsgId: "m-" + user})
+	}
+	return pairs, nil, nil
+}
+
+func (s *fakeSender) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pairs []client.GroupMsgIdPair
+	for _, group := range groupIds {
+		s.sent = append(s.sent, msg.(message.Text))
+		pairs = append(pairs, client.GroupMsgIdPair{Group: group, MsgId: "m1"})
+	}
+	return pairs, nil, nil
+}
+
+func (s *fakeSender) ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	return nil
+}
+
+func (s *fakeSender) ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modified = append(s.modified, msg.(message.Text))
+	return nil
+}
+
+func TestSummarySend(t *testing.T) {
+	sender := new(fakeSender)
+	c := New(sender, &Options{Window: time.Hour})
+
+	for i := 0; i < 5; i++ {
+		c.SendMessageToGroup("g1", message.NewText("check failed"))
+	}
+	c.SendMessageToGroup("g1", message.NewText("check ok"))
+	c.SendMessageToGroup("g2", message.NewText("check failed"))
+	if len(sender.sent) != 3 {
+		t.Fatalf("sent: %+v", sender.sent)
+	}
+
+	c.Close()
+	if len(sender.sent) != 4 {
+		t.Fatalf("summary: %+v", sender.sent)
+	}
+	summary := sender.sent[3]
+	if summary.Content != "上条消息1h内重复4次" || summary.Reference != "m1" {
+		t.Fatalf("summary: %+v", summary)
+	}
+}
+
+func TestSummaryEdit(t *testing.T) {
+	sender := new(fakeSender)
+	c := New(sender, &Options{Window: 20 * time.Millisecond, Summary: SummaryEdit})
+
+	c.SendMessageToGroup("g1", message.NewText("check failed"))
+	c.SendMessageToGroup("g1", message.NewText("check failed"))
+	time.Sleep(100 * time.Millisecond)
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.modified) != 1 || !strings.HasSuffix(sender.modified[0].Content, "（20ms内重复1次）") {
+		t.Fatalf("modified: %+v", sender.modified)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	sender := new(fakeSender)
+	c := New(sender, &Options{Window: time.Hour})
+	msg := message.NewText("check failed")
+
+	// 第一条消息发送完成前，相同消息被抑制。
+	sender.during = func() {
+		if id, _ := c.SendMessageToUser("u1", msg); id != "" {
+			t.Errorf("in flight message sent: %v", id)
+		}
+	}
+	if id, err := c.SendMessageToUser("u1", msg); err != nil || id != "m-u1" {
+		t.Fatalf("send: %v, %v", id, err)
+	}
+	if strings.Join(sender.users, ",") != "u1" {
+		t.Fatalf("users: %v", sender.users)
+	}
+
+	// 发送失败时删除占位，相同消息可以重新发送。
+	sender.fail = errors.New("timeout")
+	if _, err := c.SendMessageToUser("u2", msg); err == nil {
+		t.Fatal("expected error")
+	}
+	sender.fail = nil
+	if id, err := c.SendMessageToUser("u2", msg); err != nil || id != "m-u2" {
+		t.Fatalf("resend: %v, %v", id, err)
+	}
+	c.Close()
+}
@@ -0,0 +1,209 @@
+package digest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+)
+
+// Client为汇总发送所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error)
+	SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error)
+}
+
+// 单聊及群聊汇总消息格式，团队帖子固定为RichText。
+const (
+	FormatMixed = "mixed" // 图文混排消息
+	FormatPage  = "page"  // 推推页面消息
+)
+
+type Options struct {
+	// 汇总窗口，从缓存第一条消息开始计时，到期后合并发送。默认为1分钟。
+	Window time.Duration
+
+	// 缓存消息达到该数量时立即合并发送，默认为50。
+	MaxItems int
+
+	// FormatMixed或FormatPage，默认为FormatMixed。
+	Format string
+
+	// 汇总消息标题，默认为"消息汇总"。
+	Title string
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+type bucket struct {
+	user  string
+	group string
+	team  *client.TeamChannel
+	items []Item
+	timer *time.Timer
+}
+
+// Aggregator按接收方（单聊用户、群、团队频道）缓存消息，窗口到期或数量达到上限时合并为一条发送。
+type Aggregator struct {
+	cli  Client
+	opts Options
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// New新建Aggregator。*Options可以为空（详见Options定义/默认值）。
+func New(cli Client, opts *Options) *Aggregator {
+	a := &Aggregator{
+		cli:     cli,
+		buckets: make(map[string]*bucket),
+	}
+	if opts != nil {
+		a.opts = *opts
+	}
+	if a.opts.Window <= 0 {
+		a.opts.Window = time.Minute
+	}
+	if a.opts.MaxItems <= 0 {
+		a.opts.MaxItems = 50
+	}
+	if a.opts.Format == "" {
+		a.opts.Format = FormatMixed
+	}
+	if a.opts.Title == "" {
+		a.opts.Title = "消息汇总"
+	}
+	return a
+}
+
+func (a *Aggregator) errorf(format string, args ...any) {
+	if a.opts.Errorf != nil {
+		a.opts.Errorf(format, args...)
+	}
+}
+
+// AddToUser缓存发给单聊用户的消息。
+func (a *Aggregator) AddToUser(user string, item Item) {
+	a.add("user:"+user, &bucket{user: user}, item)
+}
+
+// AddToGroup缓存发给群的消息。
+func (a *Aggregator) AddToGroup(group string, item Item) {
+	a.add("group:"+group, &bucket{group: group}, item)
+}
+
+// AddToTeam缓存发到团队频道的帖子。
+func (a *Aggregator) AddToTeam(team client.TeamChannel, item Item) {
+	a.add("team:"+team.TeamId+"/"+team.ChannelId, &bucket{team: &team}, item)
+}
+
+func (a *Aggregator) add(key string, b *bucket, item Item) {
+	a.mu.Lock()
+	if old := a.buckets[key]; old != nil {
+		b = old
+	} else {
+		a.buckets[key] = b
+		b.timer = time.AfterFunc(a.opts.Window, func() { a.flushKey(key, b) })
+	}
+	b.items = append(b.items, item)
+	full := len(b.items) >= a.opts.MaxItems
+	if full {
+		delete(a.buckets, key)
+		b.timer.Stop()
+	}
+	a.mu.Unlock()
+
+	if full {
+		a.send(b)
+	}
+}
+
+func (a *Aggregator) flushKey(key string, b *bucket) {
+	a.mu.Lock()
+	if a.buckets[key] != b {
+		a.mu.Unlock()
+		return
+	}
+	delete(a.buckets, key)
+	a.mu.Unlock()
+	a.send(b)
+}
+
+// Flush立即合并发送所有缓存消息。
+func (a *Aggregator) Flush() {
+	a.mu.Lock()
+	buckets := a.buckets
+	a.buckets = make(map[string]*bucket)
+	a.mu.Unlock()
+
+	for _, b := range buckets {
+		b.timer.Stop()
+		a.send(b)
+	}
+}
+
+// Close等同于Flush，进程退出前调用，避免丢失缓存消息。
+func (a *Aggregator) Close() {
+	a.Flush()
+}
+
+func (a *Aggregator) send(b *bucket) {
+	title := a.opts.Title
+	var err error
+	var warn error
+	switch {
+	case b.team != nil:
+		_, w, e := a.cli.SendPostToTeams([]client.TeamChannel{*b.team}, RichText(title, b.items))
+		if w != nil {
+			warn = w.Explains
+		}
+		err = e
+	case a.opts.Format == FormatPage && b.user != "":
+		_, _, w, e := a.cli.SendPageToUsers([]string{b.user}, Page(title, b.items))
+		if w != nil {
+			warn = w.Explains
+		}
+		err = e
+	case a.opts.Format == FormatPage:
+		_, _, w, e := a.cli.SendPageToGroups([]string{b.group}, Page(title, b.items))
+		if w != nil {
+			warn = w.Explains
+		}
+		err = e
+	case b.user != "":
+		_, w, e := a.cli.SendMessageToUsers([]string{b.user}, Mixed(title, b.items))
+		if w != nil {
+			warn = w.Explains
+		}
+		err = e
+	default:
+		_, w, e := a.cli.SendMessageToGroups([]string{b.group}, nil, Mixed(title, b.items))
+		if w != nil {
+			warn = w.Explains
+		}
+		err = e
+	}
+	if err == nil {
+		err = warn
+	}
+	if err != nil {
+		a.errorf("digest: send %v items to %v: %v", len(b.items), b.target(), err)
+	}
+}
+
+func (b *bucket) target() string {
+	switch {
+	case b.team != nil:
+		return fmt.Sprintf("team %v/%v", b.team.TeamId, b.team.ChannelId)
+	case b.user != "":
+		return "user " + b.user
+	default:
+		return "group " + b.group
+	}
+}
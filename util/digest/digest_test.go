@@ -0,0 +1,87 @@
+package digest
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type fakeClient struct {
+	mu    sync.Mutex
+	sent  []client.Message
+	posts []client.Message
+}
+
+func (c *fakeClient) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	return nil, nil, nil
+}
+
+func (c *fakeClient) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg)
+	return nil, nil, nil
+}
+
+func (c *fakeClient) SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, msg)
+	return "p1", nil, nil, nil
+}
+
+func (c *fakeClient) SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error) {
+	return "p1", nil, nil, nil
+}
+
+func (c *fakeClient) SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.posts = append(c.posts, msg)
+	return nil, nil, nil
+}
+
+func TestWindow(t *testing.T) {
+	cli := new(fakeClient)
+	a := New(cli, &Options{Window: 20 * time.Millisecond})
+	a.AddToGroup("g1", Item{Text: "job 1 done"})
+	a.AddToGroup("g1", Item{Title: "job 2", Text: "job 2 failed", Images: []string{"img"}})
+	time.Sleep(100 * time.Millisecond)
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if len(cli.sent) != 1 {
+		t.Fatalf("sent: %v", cli.sent)
+	}
+	mixed := cli.sent[0].(message.Mixed)
+	if !strings.Contains(mixed[0].Value, "消息汇总（2条）\n目录：\n1. job 1 done\n2. job 2") {
+		t.Fatalf("toc: %q", mixed[0].Value)
+	}
+	if len(mixed) != 4 || mixed[3].Type != "image" {
+		t.Fatalf("mixed: %+v", mixed)
+	}
+}
+
+func TestMaxItems(t *testing.T) {
+	cli := new(fakeClient)
+	a := New(cli, &Options{Window: time.Hour, MaxItems: 2, Format: FormatPage})
+	a.AddToUser("zhangsan", Item{Text: "a"})
+	a.AddToTeam(client.TeamChannel{TeamId: "t1", ChannelId: "c1"}, Item{Text: "<b>"})
+	a.AddToUser("zhangsan", Item{Text: "b"})
+	if len(cli.sent) != 1 {
+		t.Fatalf("sent: %v", cli.sent)
+	}
+	page := cli.sent[0].(message.Page)
+	if page.Title != "消息汇总（2条）" || !strings.Contains(page.Content, `<a href="#item-2">b</a>`) {
+		t.Fatalf("page: %+v", page)
+	}
+
+	a.Close()
+	if len(cli.posts) != 1 || !strings.Contains(cli.posts[0].(message.RichText).HTML, "&lt;b&gt;") {
+		t.Fatalf("posts: %+v", cli.posts)
+	}
+}
@@ -0,0 +1,96 @@
+package digest
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Item为一条待汇总消息。
+type Item struct {
+	Title  string   // 目录标题，为空时取Text第一行
+	Text   string   // 正文
+	Images []string // 图片media_id，仅FormatMixed展示
+}
+
+func (it Item) title() string {
+	if it.Title != "" {
+		return it.Title
+	}
+	title, _, _ := strings.Cut(strings.TrimSpace(it.Text), "\n")
+	if r := []rune(title); len(r) > 30 {
+		title = string(r[:30]) + "…"
+	}
+	return title
+}
+
+func heading(title string, n int) string {
+	return fmt.Sprintf("%v（%v条）", title, n)
+}
+
+// Mixed将items合并为一条图文混排消息：标题、目录，及每条消息的正文与图片。
+func Mixed(title string, items []Item) message.Mixed {
+	var toc strings.Builder
+	toc.WriteString(heading(title, len(items)))
+	if len(items) > 1 {
+		toc.WriteString("\n目录：")
+		for i, it := range items {
+			fmt.Fprintf(&toc, "\n%v. %v", i+1, it.title())
+		}
+	}
+
+	mixed := message.NewMixed().WithText(toc.String())
+	for i, it := range items {
+		text := "\n\n" + it.Text
+		if len(items) > 1 {
+			text = fmt.Sprintf("\n\n【%v】%v\n%v", i+1, it.title(), it.Text)
+		}
+		mixed = mixed.WithText(text)
+		for _, img := range it.Images {
+			mixed = mixed.WithImage(img)
+		}
+	}
+	return mixed
+}
+
+// htmlDigest生成带目录锚点的html。
+func htmlDigest(title string, items []Item) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h2>%v</h2>\n", html.EscapeString(heading(title, len(items))))
+	if len(items) > 1 {
+		b.WriteString("<h3>目录</h3>\n<ol>\n")
+		for i, it := range items {
+			fmt.Fprintf(&b, "<li><a href=\"#item-%v\">%v</a></li>\n", i+1, html.EscapeString(it.title()))
+		}
+		b.WriteString("</ol>\n")
+	}
+	for i, it := range items {
+		fmt.Fprintf(&b, "<h3 id=\"item-%v\">%v. %v</h3>\n", i+1, i+1, html.EscapeString(it.title()))
+		for _, line := range strings.Split(it.Text, "\n") {
+			fmt.Fprintf(&b, "<p>%v</p>\n", html.EscapeString(line))
+		}
+	}
+	return b.String()
+}
+
+// Page将items合并为一条推推页面消息，正文为带目录的html。
+func Page(title string, items []Item) message.Page {
+	summary := make([]string, 0, 3)
+	for i, it := range items {
+		if i == 3 {
+			break
+		}
+		summary = append(summary, it.title())
+	}
+	return message.NewPage().
+		WithTitle(heading(title, len(items))).
+		WithSummary(strings.Join(summary, "\n")).
+		WithContent(htmlDigest(title, items))
+}
+
+// RichText将items合并为一条团队帖子，正文为带目录的html。
+func RichText(title string, items []Item) message.RichText {
+	return message.NewRichTextHTML(htmlDigest(title, items))
+}
@@ -0,0 +1,299 @@
+package escalation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Client为升级流程所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendVoiceToUsers(accounts []string, msg client.Message) ([]client.UserVoiceResult, error)
+	QueryVoiceDetail(callId string) (*client.VoiceDetail, error)
+	SendSingleStrongNotice(touser, content string, opts ...client.StrongNoticeOption) error
+}
+
+// 升级步骤类型。
+const (
+	StepVoice        = "voice"         // 电话报警，接听即结束升级
+	StepStrongNotice = "strong_notice" // 单聊强通知，等待Wait后进入下一步，由调用方在确认后调用Stop结束
+)
+
+type Step struct {
+	Kind  string   `json:"kind"`  // StepVoice, StepStrongNotice
+	Users []string `json:"users"` // 域账号列表，如主值班、副值班、主管
+
+	// 本步骤等待时长。电话报警为等待接听超时时间，强通知为发送后等待确认时长。默认为5分钟。
+	// json中为time.ParseDuration格式的字符串，如"5m"、"90s"。
+	Wait time.Duration `json:"-"`
+
+	// 强通知选项，用户超过1分钟未接收时，发短信/打电话提醒。仅StepStrongNotice有效。
+	SMS  bool `json:"sms,omitempty"`
+	Call bool `json:"call,omitempty"`
+}
+
+// stepJSON与Step字段相同，但没有MarshalJSON/UnmarshalJSON方法，避免递归。
+type stepJSON Step
+
+func (s Step) MarshalJSON() ([]byte, error) {
+	var wait string
+	if s.Wait != 0 {
+		wait = s.Wait.String()
+	}
+	return json.Marshal(struct {
+		stepJSON
+		Wait string `json:"wait,omitempty"`
+	}{stepJSON(s), wait})
+}
+
+func (s *Step) UnmarshalJSON(p []byte) error {
+	v := struct {
+		*stepJSON
+		Wait string `json:"wait"`
+	}{stepJSON: (*stepJSON)(s)}
+	if err := json.Unmarshal(p, &v); err != nil {
+		return err
+	}
+	if v.Wait == "" {
+		return nil
+	}
+	wait, err := time.ParseDuration(v.Wait)
+	if err != nil {
+		return fmt.Errorf("escalation: step wait: %w", err)
+	}
+	s.Wait = wait
+	return nil
+}
+
+// 升级策略，按顺序执行Steps，全部执行完仍无人响应时，重复Repeat次。
+type Policy struct {
+	Steps  []Step `json:"steps"`
+	Repeat int    `json:"repeat,omitempty"`
+}
+
+type Options struct {
+	// 查询电话接听状态间隔，默认为10秒。
+	PollInterval time.Duration
+
+	// 判断电话是否已接听，默认为通话时长大于0。
+	Answered func(*client.VoiceDetail) bool
+
+	// Logf用于记录每一步执行情况，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Logf func(string, ...any)
+}
+
+// 升级结果。
+type Result struct {
+	Answered bool   // 是否有人接听电话
+	Callee   string // 接听电话的号码
+	Step     int    // 结束时所在步骤，从0开始
+	Stopped  bool   // 是否由Stop结束
+}
+
+// Escalation为一次正在执行的升级流程。
+type Escalation struct {
+	cli    Client
+	policy Policy
+	text   string
+	opts   Options
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	result   Result
+}
+
+// Start按policy开始升级，content为电话报警及强通知内容。*Options可以为空（详见Options定义/默认值）。
+func Start(cli Client, policy Policy, content string, opts *Options) *Escalation {
+	e := &Escalation{
+		cli:    cli,
+		policy: policy,
+		text:   content,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.PollInterval <= 0 {
+		e.opts.PollInterval = 10 * time.Second
+	}
+	if e.opts.Answered == nil {
+		e.opts.Answered = func(detail *client.VoiceDetail) bool {
+			return detail.Duration > 0
+		}
+	}
+
+	go e.run()
+	return e
+}
+
+// Stop结束升级，如有人已确认报警。可多次调用。
+func (e *Escalation) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stop)
+	})
+}
+
+// Done在升级结束后关闭。
+func (e *Escalation) Done() <-chan struct{} {
+	return e.done
+}
+
+// Wait等待升级结束，返回升级结果。
+func (e *Escalation) Wait() Result {
+	<-e.done
+	return e.result
+}
+
+func (e *Escalation) logf(format string, args ...any) {
+	if e.opts.Logf != nil {
+		e.opts.Logf("escalation: "+format, args...)
+	}
+}
+
+func (e *Escalation) run() {
+	defer close(e.done)
+
+	for round := 0; round <= e.policy.Repeat; round++ {
+		for i, step := range e.policy.Steps {
+			e.result.Step = i
+			if e.stopped() {
+				e.result.Stopped = true
+				e.logf("stopped before step %v", i)
+				return
+			}
+
+			wait := step.Wait
+			if wait <= 0 {
+				wait = 5 * time.Minute
+			}
+
+			e.logf("round %v step %v: %v to %v", round, i, step.Kind, strings.Join(step.Users, ","))
+			switch step.Kind {
+			case StepVoice:
+				if callee, ok := e.voice(step, wait); ok {
+					e.result.Answered = true
+					e.result.Callee = callee
+					e.logf("round %v step %v: answered by %v", round, i, callee)
+					return
+				}
+			case StepStrongNotice:
+				e.strongNotice(step)
+				if e.sleep(wait) {
+					e.result.Stopped = true
+					e.logf("round %v step %v: stopped", round, i)
+					return
+				}
+			default:
+				e.logf("round %v step %v: unknown step kind %q, skip", round, i, step.Kind)
+				continue
+			}
+
+			if e.stopped() {
+				e.result.Stopped = true
+				e.logf("round %v step %v: stopped", round, i)
+				return
+			}
+			e.logf("round %v step %v: no response in %v, escalate", round, i, wait)
+		}
+	}
+	e.logf("all steps exhausted without response")
+}
+
+func (e *Escalation) stopped() bool {
+	select {
+	case <-e.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep等待d时长，期间调用Stop则返回true。
+func (e *Escalation) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-e.stop:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (e *Escalation) strongNotice(step Step) {
+	var opts []client.StrongNoticeOption
+	if step.SMS {
+		opts = append(opts, client.WithSMSNotice())
+	}
+	if step.Call {
+		opts = append(opts, client.WithCallNotice())
+	}
+	for _, user := range step.Users {
+		err := e.cli.SendSingleStrongNotice(user, e.text, opts...)
+		if err != nil {
+			e.logf("send strong notice to %v: %v", user, err)
+		} else {
+			e.logf("send strong notice to %v ok", user)
+		}
+	}
+}
+
+// voice拨打电话，并轮询接听状态直到有人接听、超时或Stop。
+func (e *Escalation) voice(step Step, wait time.Duration) (string, bool) {
+	results, err := e.cli.SendVoiceToUsers(step.Users, message.NewVoice(e.text))
+	if err != nil {
+		e.logf("send voice to %v: %v", strings.Join(step.Users, ","), err)
+		return "", false
+	}
+
+	var calls []client.UserVoiceResult
+	for _, r := range results {
+		if r.Success && r.CallId != "" {
+			calls = append(calls, r)
+			e.logf("voice call %v to %v", r.CallId, r.Mobile)
+		} else {
+			e.logf("voice call to %v failed: %v", r.Mobile, r.Error)
+		}
+	}
+	if len(calls) == 0 {
+		return "", false
+	}
+
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		if e.sleep(e.opts.PollInterval) {
+			return "", false
+		}
+		for _, call := range calls {
+			detail, err := e.cli.QueryVoiceDetail(call.CallId)
+			if err != nil {
+				e.logf("query voice %v: %v", call.CallId, err)
+				continue
+			}
+			if e.opts.Answered(detail) {
+				return call.Mobile, true
+			}
+			e.logf("voice %v state: %v %v", call.CallId, detail.State, detail.StateDesc)
+		}
+	}
+	return "", false
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Answered:
+		return fmt.Sprintf("answered by %v at step %v", r.Callee, r.Step)
+	case r.Stopped:
+		return fmt.Sprintf("stopped at step %v", r.Step)
+	default:
+		return "no response"
+	}
+}
@@ -0,0 +1,132 @@
+package escalation
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+)
+
+var _ Client = (*client.Client)(nil)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	answer   string // 接听电话的账号
+	calls    []string
+	notices  []string
+	callUser map[string]string
+}
+
+func (c *fakeClient) SendVoiceToUsers(accounts []string, msg client.Message) ([]client.UserVoiceResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.callUser == nil {
+		c.callUser = make(map[string]string)
+	}
+	var results []client.UserVoiceResult
+	for _, account := range accounts {
+		c.calls = append(c.calls, account)
+		id := "call-" + account
+		c.callUser[id] = account
+		results = append(results, client.UserVoiceResult{Mobile: account, Success: true, CallId: id})
+	}
+	return results, nil
+}
+
+func (c *fakeClient) QueryVoiceDetail(callId string) (*client.VoiceDetail, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	detail := &client.VoiceDetail{CallId: callId, State: "200005"}
+	if c.callUser[callId] == c.answer {
+		detail.State = "200000"
+		detail.Duration = 10
+	}
+	return detail, nil
+}
+
+func (c *fakeClient) SendSingleStrongNotice(touser, content string, opts ...client.StrongNoticeOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.notices = append(c.notices, touser)
+	return nil
+}
+
+func TestEscalate(t *testing.T) {
+	cli := &fakeClient{answer: "manager"}
+	policy := Policy{Steps: []Step{
+		{Kind: StepVoice, Users: []string{"primary"}, Wait: 20 * time.Millisecond},
+		{Kind: StepStrongNotice, Users: []string{"secondary"}, Wait: time.Millisecond, Call: true},
+		{Kind: StepVoice, Users: []string{"secondary"}, Wait: 20 * time.Millisecond},
+		{Kind: StepVoice, Users: []string{"manager"}, Wait: 20 * time.Millisecond},
+	}}
+	var logs []string
+	e := Start(cli, policy, "服务异常", &Options{
+		PollInterval: time.Millisecond,
+		Logf: func(format string, args ...any) {
+			logs = append(logs, format)
+		},
+	})
+	r := e.Wait()
+	if !r.Answered || r.Callee != "manager" || r.Step != 3 {
+		t.Fatalf("result: %+v", r)
+	}
+	if got := strings.Join(cli.calls, ","); got != "primary,secondary,manager" {
+		t.Fatalf("calls: %v", got)
+	}
+	if len(cli.notices) != 1 || cli.notices[0] != "secondary" {
+		t.Fatalf("notices: %v", cli.notices)
+	}
+	if len(logs) == 0 {
+		t.Fatal("no logs")
+	}
+}
+
+func TestStop(t *testing.T) {
+	cli := new(fakeClient)
+	policy := Policy{Steps: []Step{
+		{Kind: StepStrongNotice, Users: []string{"primary"}, Wait: time.Hour},
+		{Kind: StepVoice, Users: []string{"manager"}},
+	}}
+	e := Start(cli, policy, "服务异常", nil)
+	time.Sleep(10 * time.Millisecond)
+	e.Stop()
+	e.Stop()
+	r := e.Wait()
+	if !r.Stopped || r.Step != 0 || len(cli.calls) != 0 {
+		t.Fatalf("result: %+v, calls: %v", r, cli.calls)
+	}
+}
+
+func TestPolicyJSON(t *testing.T) {
+	var p Policy
+	err := json.Unmarshal([]byte(`{"steps": [
+		{"kind": "voice", "users": ["primary"], "wait": "5m"},
+		{"kind": "strong_notice", "users": ["secondary"], "sms": true}
+	], "repeat": 1}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Steps) != 2 || p.Steps[0].Wait != 5*time.Minute || p.Steps[0].Users[0] != "primary" ||
+		p.Steps[1].Wait != 0 || !p.Steps[1].SMS || p.Repeat != 1 {
+		t.Fatalf("policy: %+v", p)
+	}
+
+	b, err := json.Marshal(p.Steps[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != `{"kind":"voice","users":["primary"],"wait":"5m0s"}` {
+		t.Fatalf("marshal: %v", got)
+	}
+
+	// 数字会被误认为纳秒，不接受。
+	if err = json.Unmarshal([]byte(`{"kind": "voice", "wait": 300}`), new(Step)); err == nil {
+		t.Fatal("expected error for numeric wait")
+	}
+	if err = json.Unmarshal([]byte(`{"kind": "voice", "wait": "5 minutes"}`), new(Step)); err == nil {
+		t.Fatal("expected error for invalid wait")
+	}
+}
@@ -0,0 +1,320 @@
+package faq
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/interactive"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/qa"
+)
+
+// 候选问题按钮名称，即interactive.IAAction.Name。
+const ActionChoose = "faq_choose"
+
+type Options struct {
+	// 最高得分不低于Threshold时直接回答，默认为0.6。
+	Threshold float64
+
+	// 最高得分在[MinScore, Threshold)之间时，发送"你是不是想问"卡片供用户选择，默认为0.2。
+	// 低于MinScore时不回答（见Fallback）。
+	MinScore float64
+
+	// "你是不是想问"卡片中最多展示几个候选问题，默认为3。
+	MaxSuggestions int
+
+	// 没有匹配的问题时的回复，默认为空，表示不回复。
+	Fallback string
+
+	// 检查知识库文件是否修改的间隔，修改后自动重新加载。默认为5秒。
+	ReloadInterval time.Duration
+
+	// 解析知识库文件，v为*KB。默认为json.Unmarshal。
+	// 本库只依赖标准库，yaml等格式由调用方提供解析函数，如yaml.Unmarshal（KB字段名均为小写，无需yaml标签）。
+	Unmarshal func(data []byte, v any) error
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// FAQ为问答知识库应答器。
+//
+// 用法：
+//
+//	f, err := faq.Open("faq.json", nil)
+//	cb := qa.NewHandler(f.Answer, cli, nil)
+//	interactive.NewCallbackHandler(f.OnConfirmed(cli))
+type FAQ struct {
+	path string
+	opts Options
+
+	mu      sync.RWMutex
+	idx     *index
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open加载知识库文件（格式见KB，默认为json，yaml等见Options.Unmarshal），并在文件修改后自动重新加载。
+// 重新加载失败时继续使用原知识库。*Options可以为空（详见Options定义/默认值）。
+func Open(path string, opts *Options) (*FAQ, error) {
+	f := newFAQ(opts)
+	f.path = path
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	f.stop = make(chan struct{})
+	f.done = make(chan struct{})
+	go f.watch()
+	return f, nil
+}
+
+// New以kb新建FAQ，不自动重新加载。*Options可以为空（详见Options定义/默认值）。
+func New(kb *KB, opts *Options) (*FAQ, error) {
+	f := newFAQ(opts)
+	idx, err := newIndex(kb)
+	if err != nil {
+		return nil, err
+	}
+	f.idx = idx
+	return f, nil
+}
+
+func newFAQ(opts *Options) *FAQ {
+	f := new(FAQ)
+	if opts != nil {
+		f.opts = *opts
+	}
+	if f.opts.Threshold <= 0 {
+		f.opts.Threshold = 0.6
+	}
+	if f.opts.MinScore <= 0 {
+		f.opts.MinScore = 0.2
+	}
+	if f.opts.MaxSuggestions <= 0 {
+		f.opts.MaxSuggestions = 3
+	}
+	if f.opts.ReloadInterval <= 0 {
+		f.opts.ReloadInterval = 5 * time.Second
+	}
+	if f.opts.Unmarshal == nil {
+		f.opts.Unmarshal = json.Unmarshal
+	}
+	return f
+}
+
+func (f *FAQ) errorf(format string, args ...any) {
+	if f.opts.Errorf != nil {
+		f.opts.Errorf(format, args...)
+	}
+}
+
+// Close停止检查知识库文件。
+func (f *FAQ) Close() {
+	if f.stop == nil {
+		return
+	}
+	close(f.stop)
+	<-f.done
+}
+
+func (f *FAQ) watch() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opts.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(f.path)
+		if err != nil {
+			f.errorf("faq: stat %v: %v", f.path, err)
+			continue
+		}
+		f.mu.RLock()
+		changed := !fi.ModTime().Equal(f.modTime)
+		f.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err = f.reload(); err != nil {
+			f.errorf("%v", err)
+		}
+	}
+}
+
+func (f *FAQ) reload() error {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("faq: stat %v: %w", f.path, err)
+	}
+	p, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("faq: read %v: %w", f.path, err)
+	}
+	var kb KB
+	if err = f.opts.Unmarshal(p, &kb); err != nil {
+		f.setModTime(fi.ModTime()) // 文件改正后再重新加载
+		return fmt.Errorf("faq: parse %v: %w", f.path, err)
+	}
+	idx, err := newIndex(&kb)
+	if err != nil {
+		f.setModTime(fi.ModTime())
+		return err
+	}
+
+	f.mu.Lock()
+	f.idx = idx
+	f.modTime = fi.ModTime()
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *FAQ) setModTime(t time.Time) {
+	f.mu.Lock()
+	f.modTime = t
+	f.mu.Unlock()
+}
+
+func (f *FAQ) index() *index {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.idx
+}
+
+// Match返回与question最相近的至多n条问答，按得分降序排列。
+func (f *FAQ) Match(question string, n int) []Match {
+	idx := f.index()
+	if idx == nil {
+		return nil
+	}
+	return idx.match(question, n)
+}
+
+// Entry按id查找问答，不存在时返回nil。
+func (f *FAQ) Entry(id string) *Entry {
+	idx := f.index()
+	if idx == nil {
+		return nil
+	}
+	return idx.ids[id]
+}
+
+// Answer实现qa.Handler：得分高时直接回答；得分低时单/群聊发送"你是不是想问"卡片，
+// 团队帖子不支持卡片，以文本列出候选问题。
+func (f *FAQ) Answer(q *qa.Question) client.Message {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil
+	}
+	matches := f.Match(q.Text, f.opts.MaxSuggestions)
+	if len(matches) == 0 || matches[0].Score < f.opts.MinScore {
+		if f.opts.Fallback == "" {
+			return nil
+		}
+		return message.NewText(f.opts.Fallback)
+	}
+	if matches[0].Score >= f.opts.Threshold {
+		return message.NewText(matches[0].Entry.Answer)
+	}
+
+	var suggestions []*Entry
+	for _, m := range matches {
+		if m.Score >= f.opts.MinScore {
+			suggestions = append(suggestions, m.Entry)
+		}
+	}
+	if q.Conversation.Type == qa.ConversationTeam {
+		lines := []string{"你是不是想问："}
+		for i, e := range suggestions {
+			lines = append(lines, fmt.Sprintf("%v. %v", i+1, e.Question))
+		}
+		return message.NewText(strings.Join(lines, "\n"))
+	}
+	return Card(q.Text, suggestions)
+}
+
+// Card生成"你是不是想问"卡片，每个候选问题一个按钮。
+func Card(question string, suggestions []*Entry) interactive.Interactive {
+	card := interactive.Interactive{
+		Summary: "你是不是想问：",
+		Head:    &interactive.IAHead{Text: "你是不是想问：", BgColor: "3873FA", TColor: "FFFFFF"},
+		Body:    &interactive.IABody{Content: question},
+	}
+	for _, e := range suggestions {
+		card.Action = append(card.Action, &interactive.IAAction{
+			Text:        e.Question,
+			Name:        ActionChoose,
+			Value:       e.Id,
+			BorderColor: "#3873FA",
+			Color:       "3873FA",
+		})
+	}
+	return card
+}
+
+// Client为更新卡片所用的接口，*client.Client实现了该接口。
+type Client interface {
+	ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+}
+
+// OnConfirmed处理"你是不是想问"卡片按钮点击，将卡片修改为所选问题的答案。
+//
+// 用法：interactive.NewCallbackHandler(f.OnConfirmed(cli))。
+func (f *FAQ) OnConfirmed(cli Client) interactive.OnConfirmed {
+	return func(cm *interactive.ConfirmMessage) {
+		var action *interactive.CbAction
+		for _, a := range cm.Action {
+			if a.Name == ActionChoose {
+				action = a
+				break
+			}
+		}
+		if action == nil {
+			return
+		}
+
+		id := decodeString(action.Value)
+		e := f.Entry(id)
+		if e == nil {
+			f.errorf("faq: message %v: entry %q not found", cm.MsgId, id)
+			return
+		}
+
+		card := interactive.Interactive{
+			Summary: e.Question,
+			Head:    &interactive.IAHead{Text: e.Question, BgColor: "3873FA", TColor: "FFFFFF"},
+			Body:    &interactive.IABody{Content: e.Answer},
+		}
+		var err error
+		switch cm.Conv.Type {
+		case "single":
+			err = cli.ModifyUserMessage(client.UserMsgIdPair{User: cm.User.Account, MsgId: cm.MsgId}, card, nil)
+		case "group":
+			err = cli.ModifyGroupMessage(client.GroupMsgIdPair{Group: cm.Conv.Target, MsgId: cm.MsgId}, card, nil)
+		default:
+			return
+		}
+		if err != nil {
+			f.errorf("faq: entry %q: update card %v: %v", id, cm.MsgId, err)
+		}
+	}
+}
+
+func decodeString(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		return string(raw)
+	}
+	return s
+}
@@ -0,0 +1,155 @@
+package faq
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/interactive"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/qa"
+)
+
+var testKB = &KB{
+	Synonyms: [][]string{{"vpn", "梯子"}, {"密码", "口令"}},
+	Entries: []*Entry{
+		{Id: "pwd", Question: "如何重置域账号密码", Similar: []string{"忘记密码怎么办"}, Answer: "访问自助平台重置"},
+		{Id: "vpn", Question: "VPN连不上怎么办", Keywords: []string{"vpn"}, Answer: "检查网络后重新登录VPN"},
+		{Id: "wifi", Question: "How to connect office WiFi", Answer: "Use your domain account"},
+		{Id: "leave", Question: "怎么请假", Patterns: []string{`请.{0,2}假`}, Answer: "在OA提交请假申请"},
+	},
+}
+
+func TestMatch(t *testing.T) {
+	f, err := New(testKB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct{ question, want string }{
+		{"忘记口令了怎么办", "pwd"},
+		{"梯子连不上", "vpn"},
+		{"how do I connect to the wifi", "wifi"},
+		{"我想请两天假", "leave"},
+	}
+	for _, c := range cases {
+		ms := f.Match(c.question, 3)
+		if len(ms) == 0 || ms[0].Entry.Id != c.want {
+			t.Errorf("Match(%q): %+v, want %v", c.question, ms, c.want)
+		}
+	}
+	if ms := f.Match("今天天气不错", 3); len(ms) != 0 {
+		t.Errorf("unexpected matches: %+v", ms)
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	f, err := New(testKB, &Options{Fallback: "不知道"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	single := qa.Conversation{Type: qa.ConversationSingle}
+
+	if msg, ok := f.Answer(&qa.Question{Text: "如何重置域账号密码", Conversation: single}).(message.Text); !ok || msg.Content != "访问自助平台重置" {
+		t.Fatalf("exact question: %#v", msg)
+	}
+	if msg, ok := f.Answer(&qa.Question{Text: "今天天气不错", Conversation: single}).(message.Text); !ok || msg.Content != "不知道" {
+		t.Fatalf("fallback: %#v", msg)
+	}
+	card, ok := f.Answer(&qa.Question{Text: "账号", Conversation: single}).(interactive.Interactive)
+	if !ok || len(card.Action) == 0 || card.Action[0].Value != "pwd" {
+		t.Fatalf("did you mean: %#v", card)
+	}
+
+	var modified client.Message
+	cm := &interactive.ConfirmMessage{MsgId: "m1", Action: []*interactive.CbAction{{Name: ActionChoose, Value: json.RawMessage(`"pwd"`)}}}
+	cm.Conv.Type = "single"
+	f.OnConfirmed(fakeClient(func(msg client.Message) { modified = msg }))(cm)
+	if card, ok := modified.(interactive.Interactive); !ok || card.Body.Content != "访问自助平台重置" {
+		t.Fatalf("confirmed: %#v", modified)
+	}
+}
+
+type fakeClient func(client.Message)
+
+func (fc fakeClient) ModifyUserMessage(_ client.UserMsgIdPair, msg client.Message, _ *client.ModifyOptions) error {
+	fc(msg)
+	return nil
+}
+
+func (fc fakeClient) ModifyGroupMessage(_ client.GroupMsgIdPair, msg client.Message, _ *client.ModifyOptions) error {
+	fc(msg)
+	return nil
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faq.json")
+	write := func(kb *KB) {
+		p, _ := json.Marshal(kb)
+		if err := os.WriteFile(path, p, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(&KB{Entries: []*Entry{{Id: "a", Question: "怎么报销", Answer: "v1"}}})
+
+	f, err := Open(path, &Options{ReloadInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	write(&KB{Entries: []*Entry{{Id: "a", Question: "怎么报销", Answer: "v2"}}})
+	os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	for i := 0; i < 100; i++ {
+		if e := f.Entry("a"); e != nil && e.Answer == "v2" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("not reloaded: %+v", f.Entry("a"))
+}
+
+// unmarshalLines解析每行"问题 = 答案"格式的知识库，模拟yaml等自定义格式。
+func unmarshalLines(data []byte, v any) error {
+	kb := v.(*KB)
+	for _, line := range strings.Split(string(data), "\n") {
+		q, a, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		q = strings.TrimSpace(q)
+		kb.Entries = append(kb.Entries, &Entry{Id: q, Question: q, Answer: strings.TrimSpace(a)})
+	}
+	return nil
+}
+
+func TestUnmarshal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faq.txt")
+	if err := os.WriteFile(path, []byte("怎么报销 = v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Open(path, &Options{ReloadInterval: 10 * time.Millisecond, Unmarshal: unmarshalLines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if e := f.Entry("怎么报销"); e == nil || e.Answer != "v1" {
+		t.Fatalf("entry: %+v", e)
+	}
+
+	// 自定义格式同样自动重新加载。
+	if err = os.WriteFile(path, []byte("怎么报销 = v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, time.Now().Add(time.Second), time.Now().Add(time.Second))
+	for i := 0; i < 100; i++ {
+		if e := f.Entry("怎么报销"); e != nil && e.Answer == "v2" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("not reloaded: %+v", f.Entry("怎么报销"))
+}
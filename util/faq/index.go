@@ -0,0 +1,271 @@
+package faq
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Entry为一条问答。
+type Entry struct {
+	Id       string   `json:"id,omitempty"`       // 默认为Question
+	Question string   `json:"question"`           // 标准问题
+	Similar  []string `json:"similar,omitempty"`  // 相似问法
+	Keywords []string `json:"keywords,omitempty"` // 关键词，问题中包含关键词时提高得分
+	Patterns []string `json:"patterns,omitempty"` // 正则表达式，问题匹配任一正则时直接命中
+	Answer   string   `json:"answer"`
+}
+
+// KB为问答知识库，知识库文件默认为json格式（其它格式见Options.Unmarshal）。
+type KB struct {
+	// 同义词组，每组第一个词为标准词，如[["vpn", "梯子"], ["密码", "口令"]]。
+	// 匹配前问题和知识库中的同义词均替换为标准词。
+	Synonyms [][]string `json:"synonyms,omitempty"`
+	Entries  []*Entry   `json:"entries"`
+}
+
+// Match为一条匹配结果，Score取值[0, 1]。
+type Match struct {
+	Entry *Entry
+	Score float64
+}
+
+// BM25参数。
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 问题包含全部关键词时，得分增加keywordBoost。
+const keywordBoost = 0.5
+
+type doc struct {
+	entry int
+	tf    map[string]int
+	len   int
+	self  float64 // 文档与自身的BM25得分，用于归一化
+}
+
+type index struct {
+	entries  []*Entry
+	ids      map[string]*Entry
+	patterns [][]*regexp.Regexp
+	keywords [][]string
+	synonyms *strings.Replacer
+
+	docs  []doc
+	df    map[string]int
+	avgdl float64
+}
+
+func newIndex(kb *KB) (*index, error) {
+	idx := &index{
+		entries: kb.Entries,
+		ids:     make(map[string]*Entry, len(kb.Entries)),
+		df:      make(map[string]int),
+	}
+
+	var pairs []string
+	for _, group := range kb.Synonyms {
+		if len(group) < 2 {
+			continue
+		}
+		std := strings.ToLower(group[0])
+		for _, word := range group[1:] {
+			pairs = append(pairs, strings.ToLower(word), std)
+		}
+	}
+	// strings.Replacer在同一位置优先匹配靠前的词，长词在前避免被短词截断。
+	idx.synonyms = strings.NewReplacer(sortByLength(pairs)...)
+
+	for i, e := range kb.Entries {
+		if e.Question == "" {
+			return nil, fmt.Errorf("faq: entry %v: empty question", i)
+		}
+		if e.Id == "" {
+			e.Id = e.Question
+		}
+		if _, ok := idx.ids[e.Id]; ok {
+			return nil, fmt.Errorf("faq: duplicate entry id %q", e.Id)
+		}
+		idx.ids[e.Id] = e
+
+		var res []*regexp.Regexp
+		for _, p := range e.Patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("faq: entry %q: %w", e.Id, err)
+			}
+			res = append(res, re)
+		}
+		idx.patterns = append(idx.patterns, res)
+
+		var keywords []string
+		for _, k := range e.Keywords {
+			if k = idx.normalize(k); k != "" {
+				keywords = append(keywords, k)
+			}
+		}
+		idx.keywords = append(idx.keywords, keywords)
+
+		for _, q := range append([]string{e.Question}, e.Similar...) {
+			tokens := tokenize(idx.normalize(q))
+			if len(tokens) == 0 {
+				continue
+			}
+			d := doc{entry: i, tf: make(map[string]int), len: len(tokens)}
+			for _, t := range tokens {
+				if d.tf[t] == 0 {
+					idx.df[t]++
+				}
+				d.tf[t]++
+			}
+			idx.docs = append(idx.docs, d)
+			idx.avgdl += float64(d.len)
+		}
+	}
+	if len(idx.docs) > 0 {
+		idx.avgdl /= float64(len(idx.docs))
+	}
+	for i := range idx.docs {
+		d := &idx.docs[i]
+		d.self = idx.bm25(d.tf, d)
+	}
+	return idx, nil
+}
+
+func sortByLength(pairs []string) []string {
+	type pair struct{ old, new string }
+	ps := make([]pair, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		ps = append(ps, pair{pairs[i], pairs[i+1]})
+	}
+	sort.SliceStable(ps, func(i, j int) bool { return len(ps[i].old) > len(ps[j].old) })
+	out := make([]string, 0, len(pairs))
+	for _, p := range ps {
+		out = append(out, p.old, p.new)
+	}
+	return out
+}
+
+func (idx *index) normalize(text string) string {
+	return idx.synonyms.Replace(strings.ToLower(strings.TrimSpace(text)))
+}
+
+func (idx *index) bm25(query map[string]int, d *doc) float64 {
+	n := float64(len(idx.docs))
+	var score float64
+	for term := range query {
+		tf := float64(d.tf[term])
+		if tf == 0 {
+			continue
+		}
+		df := float64(idx.df[term])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.len)/idx.avgdl))
+	}
+	return score
+}
+
+// match返回得分最高的至多n条结果，按得分降序排列。
+func (idx *index) match(question string, n int) []Match {
+	q := idx.normalize(question)
+	tokens := tokenize(q)
+	query := &doc{tf: make(map[string]int), len: len(tokens)}
+	for _, t := range tokens {
+		query.tf[t]++
+	}
+	// 以问题与文档各自的自身得分归一化（类似余弦相似度），完全相同时得分为1。
+	self := idx.bm25(query.tf, query)
+
+	scores := make([]float64, len(idx.entries))
+	for i := range idx.docs {
+		d := &idx.docs[i]
+		if d.self <= 0 || self <= 0 {
+			continue
+		}
+		if s := idx.bm25(query.tf, d) / math.Sqrt(d.self*self); s > scores[d.entry] {
+			scores[d.entry] = s
+		}
+	}
+	for i := range idx.entries {
+		if kws := idx.keywords[i]; len(kws) > 0 {
+			hit := 0
+			for _, k := range kws {
+				if strings.Contains(q, k) {
+					hit++
+				}
+			}
+			scores[i] += keywordBoost * float64(hit) / float64(len(kws))
+		}
+		for _, re := range idx.patterns[i] {
+			if re.MatchString(question) {
+				scores[i] = 1
+				break
+			}
+		}
+	}
+
+	var matches []Match
+	for i, s := range scores {
+		if s > 0 {
+			matches = append(matches, Match{Entry: idx.entries[i], Score: math.Min(s, 1)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
+
+// tokenize切分文本：连续汉字切为二元组（单字时保留单字），字母数字按词切分，去掉标点及空白。
+func tokenize(text string) []string {
+	var tokens []string
+	var word, han []rune
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, stem(string(word)))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch len(han) {
+		case 0:
+		case 1:
+			tokens = append(tokens, string(han))
+		default:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
+
+// stem去掉英文复数后缀，如"passwords"与"password"视为同一个词。
+func stem(word string) string {
+	if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+		return word[:len(word)-1]
+	}
+	return word
+}
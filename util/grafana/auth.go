@@ -0,0 +1,139 @@
+package grafana
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuthOptions为Grafana webhook身份验证参数，对应webhook contact point的Basic auth及HMAC签名配置。
+// 各项验证均为可选，未配置的项跳过。
+type AuthOptions struct {
+	// Basic auth用户名及密码。
+	Username string
+	Password string
+
+	// HMAC签名密钥，签名为hex(HMAC-SHA256(secret, [timestamp + ":"] + body))。
+	HMACSecret string
+
+	// HMAC签名所在头部，默认为"X-Grafana-Alerting-Signature"。
+	HMACHeader string
+
+	// HMAC签名时间戳所在头部，为空表示签名不包含时间戳。
+	TimestampHeader string
+
+	// 针对时间戳头部（秒级时间戳），指定请求过期时间。默认为0，表示请求不会过期。
+	Expire time.Duration
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// 身份验证失败返回的http.StatusCode。默认为http.StatusUnauthorized(401)。
+	FailStatusCode int
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// WithAuth验证Grafana webhook请求的Basic auth及HMAC签名，验证通过后交由handler处理。
+func WithAuth(opt *AuthOptions, handler http.Handler) http.Handler {
+	if opt == nil || (opt.Username == "" && opt.Password == "" && opt.HMACSecret == "") {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opt.Username != "" || opt.Password != "" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || !equal(user, opt.Username) || !equal(pass, opt.Password) {
+				authFail(opt, w, "grafana: auth: basic auth not match, request user %q", user)
+				return
+			}
+		}
+
+		if opt.HMACSecret == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		header := opt.HMACHeader
+		if header == "" {
+			header = "X-Grafana-Alerting-Signature"
+		}
+		reqSign := r.Header.Get(header)
+
+		var timestamp string
+		if opt.TimestampHeader != "" {
+			timestamp = r.Header.Get(opt.TimestampHeader)
+			if opt.Expire > 0 {
+				ts, err := strconv.ParseInt(timestamp, 10, 64)
+				if err != nil {
+					authFail(opt, w, "grafana: auth: parse request timestamp %q: %v", timestamp, err)
+					return
+				}
+				now := time.Now
+				if opt.Now != nil {
+					now = opt.Now
+				}
+				diff := now().Sub(time.Unix(ts, 0))
+				if diff < 0 {
+					diff = -diff
+				}
+				if diff > opt.Expire {
+					authFail(opt, w, "grafana: auth: request timestamp %v exceeded expire duration %v",
+						timestamp, opt.Expire)
+					return
+				}
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		_, err := buf.ReadFrom(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if opt.Errorf != nil {
+				opt.Errorf("grafana: auth: read request body: %v", err)
+			}
+			return
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(buf)
+
+		mac := hmac.New(sha256.New, []byte(opt.HMACSecret))
+		if opt.TimestampHeader != "" {
+			mac.Write([]byte(timestamp))
+			mac.Write([]byte(":"))
+		}
+		mac.Write(buf.Bytes())
+		sign := hex.EncodeToString(mac.Sum(nil))
+
+		if !hmac.Equal([]byte(sign), []byte(reqSign)) {
+			authFail(opt, w, "grafana: auth: hmac signature not match, request %v", reqSign)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func authFail(opt *AuthOptions, w http.ResponseWriter, format string, args ...any) {
+	if opt.FailStatusCode > 0 {
+		w.WriteHeader(opt.FailStatusCode)
+	} else {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	if opt.Errorf != nil {
+		opt.Errorf(format, args...)
+	}
+}
@@ -0,0 +1,365 @@
+package grafana
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/interactive"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/alertack"
+	"github.com/eachain/360-tuitui-robot/util/alertmanager"
+	"github.com/eachain/360-tuitui-robot/util/tracker"
+)
+
+// Client为Receiver发消息所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	UploadFromURL(rawurl string) (mediaId string, isImage bool, err error)
+}
+
+// 消息格式。
+const (
+	FormatCard  = "card"  // interactive.Interactive，silenceURL/dashboardURL/panelURL展示为链接按钮，默认值
+	FormatText  = "text"  // message.Text
+	FormatMixed = "mixed" // message.Mixed，可带报警截图
+	FormatPage  = "page"  // message.Page，Template按html模板渲染
+)
+
+// 默认标题模板，模板参数为*Payload。优先使用Grafana渲染好的标题。
+const DefaultTitleTemplate = `{{if .Title}}{{.Title}}{{else}}[{{.Status | upper}}:{{len .Alerts.Firing}}] {{.CommonLabels.alertname}}{{end}}`
+
+// 默认文本模板，模板参数为*Payload。
+const DefaultTemplate = `{{range .Alerts}}[{{.Status}}] {{.Labels.alertname}}
+{{range .Labels.SortedPairs}}{{if ne .Name "alertname"}}  {{.Name}}: {{.Value}}
+{{end}}{{end}}{{range .Annotations.SortedPairs}}  {{.Name}}: {{.Value}}
+{{end}}{{if .Values}}  values:{{range $k, $v := .Values}} {{$k}}={{$v}}{{end}}
+{{end}}
+{{end}}{{if .TruncatedAlerts}}...and {{.TruncatedAlerts}} more alerts{{end}}`
+
+// 默认页面模板，模板参数为*Payload。
+const DefaultPageTemplate = `{{range .Alerts}}<h3>[{{.Status}}] {{.Labels.alertname}}</h3>
+<p>{{range .Labels.SortedPairs}}{{.Name}}: {{.Value}}<br/>{{end}}{{range .Annotations.SortedPairs}}{{.Name}}: {{.Value}}<br/>{{end}}{{if .Values}}values:{{range $k, $v := .Values}} {{$k}}={{$v}}{{end}}{{end}}</p>
+<p>{{if .DashboardURL}}<a href="{{.DashboardURL}}">Dashboard</a> {{end}}{{if .PanelURL}}<a href="{{.PanelURL}}">Panel</a> {{end}}{{if .SilenceURL}}<a href="{{.SilenceURL}}">Silence</a>{{end}}</p>
+{{end}}{{if .TruncatedAlerts}}<p>...and {{.TruncatedAlerts}} more alerts</p>{{end}}`
+
+// 按组织、文件夹及标签选择报警接收方。
+type Route struct {
+	OrgId  int64  `json:"org_id,omitempty"` // 组织id，为0时匹配所有组织
+	Folder string `json:"folder,omitempty"` // 报警规则所在文件夹，为空时匹配所有文件夹
+	alertmanager.Route
+}
+
+func (r *Route) matches(payload *Payload) bool {
+	if r.OrgId != 0 && r.OrgId != payload.OrgId {
+		return false
+	}
+	if r.Folder != "" && r.Folder != payload.Folder() {
+		return false
+	}
+	return r.Route.Matches(payload.CommonLabels)
+}
+
+type Options struct {
+	// 按顺序匹配的路由，匹配标签为CommonLabels。
+	Routes []*Route
+
+	// 没有路由匹配时的默认接收方。
+	Default alertmanager.Recipients
+
+	// 消息格式，可选值有FormatCard(默认)、FormatText、FormatMixed、FormatPage。
+	Format string
+
+	// 消息内容模板，模板参数为*Payload，可用函数见alertmanager.Funcs。
+	// 默认为DefaultTemplate，FormatPage默认为DefaultPageTemplate。
+	Template string
+
+	// 标题模板，用于FormatCard及FormatPage，默认为DefaultTitleTemplate。
+	TitleTemplate string
+
+	// 是否在卡片上加"Ack"、"Silence 1h"按钮，仅FormatCard有效，且仅对触发中的报警生效。
+	// 按钮点击回调见github.com/eachain/360-tuitui-robot/util/alertack.NewHandler。
+	AckButtons bool
+
+	// 是否上传报警截图（第一条带imageURL的报警），用于FormatCard、FormatMixed及FormatPage。
+	UploadImages bool
+
+	// 如果不为nil，用Payload.GroupKey跟踪报警消息：同一分组的后续通知修改原消息，而不是发送新消息。
+	Tracker *tracker.Tracker
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+type executor interface {
+	Execute(io.Writer, any) error
+}
+
+// Receiver接收Grafana webhook通知，按路由渲染并发送推推消息。
+//
+// 身份验证见WithAuth。
+type Receiver struct {
+	cli   Client
+	opts  Options
+	tmpl  executor
+	title executor
+}
+
+// New新建Receiver，*Options可以为空（详见Options定义/默认值）。
+func New(cli Client, opts *Options) (*Receiver, error) {
+	r := &Receiver{cli: cli}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.Format == "" {
+		r.opts.Format = FormatCard
+	}
+	for i, route := range r.opts.Routes {
+		if err := route.Compile(); err != nil {
+			return nil, fmt.Errorf("grafana: routes[%v]: %w", i, err)
+		}
+	}
+
+	var err error
+	switch r.opts.Format {
+	case FormatCard, FormatText, FormatMixed:
+		text := r.opts.Template
+		if text == "" {
+			text = DefaultTemplate
+		}
+		r.tmpl, err = template.New("grafana").Funcs(alertmanager.Funcs).Parse(text)
+	case FormatPage:
+		text := r.opts.Template
+		if text == "" {
+			text = DefaultPageTemplate
+		}
+		r.tmpl, err = htmltemplate.New("grafana").Funcs(alertmanager.Funcs).Parse(text)
+	default:
+		return nil, fmt.Errorf("grafana: unknown format %q", r.opts.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("grafana: parse template: %w", err)
+	}
+
+	text := r.opts.TitleTemplate
+	if text == "" {
+		text = DefaultTitleTemplate
+	}
+	r.title, err = template.New("title").Funcs(alertmanager.Funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("grafana: parse title template: %w", err)
+	}
+	return r, nil
+}
+
+// ServeHTTP实现http.Handler。发送失败时返回500，Grafana会稍后重试。
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	payload := new(Payload)
+	err := json.NewDecoder(req.Body).Decode(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		r.errorf("grafana: json decode request body: %v", err)
+		return
+	}
+
+	err = r.Notify(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		r.errorf("%v", err)
+	}
+}
+
+// Route返回payload匹配的所有接收方。没有路由匹配时返回Options.Default。
+func (r *Receiver) Route(payload *Payload) alertmanager.Recipients {
+	var rc alertmanager.Recipients
+	for _, route := range r.opts.Routes {
+		if !route.matches(payload) {
+			continue
+		}
+		rc.Add(route.Users, route.Groups, route.AtUsers)
+		if !route.Continue {
+			break
+		}
+	}
+	if rc.Empty() {
+		rc = r.opts.Default
+	}
+	return rc
+}
+
+// Notify路由、渲染并发送一次通知。
+func (r *Receiver) Notify(payload *Payload) error {
+	rc := r.Route(payload)
+	if rc.Empty() {
+		return nil
+	}
+
+	msg, err := r.Render(payload)
+	if err != nil {
+		return err
+	}
+
+	if r.opts.Tracker != nil && payload.GroupKey != "" {
+		target := tracker.Target{Users: rc.Users, Groups: rc.Groups, AtUsers: rc.AtUsers}
+		if payload.Status == alertmanager.StatusResolved {
+			err = r.opts.Tracker.Resolve(payload.GroupKey, target, msg)
+		} else {
+			err = r.opts.Tracker.Fire(payload.GroupKey, target, msg)
+		}
+		if err != nil {
+			return fmt.Errorf("grafana: group %v: %w", payload.GroupKey, err)
+		}
+		return nil
+	}
+
+	var errs []error
+	if len(rc.Users) > 0 {
+		_, warn, err := r.cli.SendMessageToUsers(rc.Users, msg)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			r.errorf("grafana: group %v: send to users: %v", payload.GroupKey, warn.Explains)
+		}
+	}
+	if len(rc.Groups) > 0 {
+		_, warn, err := r.cli.SendMessageToGroups(rc.Groups, rc.AtUsers, msg)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			r.errorf("grafana: group %v: send to groups: %v", payload.GroupKey, warn.Explains)
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("grafana: group %v: %w", payload.GroupKey, err)
+	}
+	return nil
+}
+
+// Render按Options.Format及模板渲染消息。
+func (r *Receiver) Render(payload *Payload) (client.Message, error) {
+	buf := new(bytes.Buffer)
+	err := r.title.Execute(buf, payload)
+	if err != nil {
+		return nil, fmt.Errorf("grafana: execute title template: %w", err)
+	}
+	title := strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	err = r.tmpl.Execute(buf, payload)
+	if err != nil {
+		return nil, fmt.Errorf("grafana: execute template: %w", err)
+	}
+	content := strings.TrimSpace(buf.String())
+
+	switch r.opts.Format {
+	case FormatText:
+		return message.NewText(title + "\n\n" + content), nil
+
+	case FormatMixed:
+		mixed := message.NewMixed().WithText(title + "\n\n" + content)
+		if mediaId := r.uploadImage(payload); mediaId != "" {
+			mixed = mixed.WithImage(mediaId)
+		}
+		return mixed, nil
+
+	case FormatPage:
+		return message.NewPage().
+			WithTitle(title).
+			WithSummary(title).
+			WithImage(r.uploadImage(payload)).
+			WithContent(content), nil
+
+	default:
+		return r.card(payload, title, content), nil
+	}
+}
+
+func (r *Receiver) card(payload *Payload, title, content string) interactive.Interactive {
+	head := &interactive.IAHead{Text: title, BgColor: "FA5151", TColor: "FFFFFF"}
+	if payload.Status == alertmanager.StatusResolved {
+		head.BgColor = "14CC89"
+	}
+
+	card := interactive.Interactive{
+		Summary: title,
+		Head:    head,
+		Body: &interactive.IABody{
+			Content: content,
+			Image:   r.uploadImage(payload),
+		},
+	}
+
+	links := []struct {
+		text string
+		url  func(Alert) string
+	}{
+		{"Dashboard", func(a Alert) string { return a.DashboardURL }},
+		{"Panel", func(a Alert) string { return a.PanelURL }},
+		{"Silence", func(a Alert) string { return a.SilenceURL }},
+	}
+	for _, link := range links {
+		for _, alert := range payload.Alerts {
+			if url := link.url(alert); url != "" {
+				card.Action = append(card.Action, linkButton(link.text, url))
+				break
+			}
+		}
+	}
+
+	if r.opts.AckButtons && payload.Status != alertmanager.StatusResolved {
+		// Grafana不支持手动恢复报警，不加"Resolve"按钮
+		card = alertack.WithoutResolve(alertack.WithButtons(card, alertack.Alert{
+			Fingerprint: payload.GroupKey,
+			Labels:      payload.CommonLabels,
+		}))
+	}
+	return card
+}
+
+// linkButton点击后打开url，不触发可交互式消息回调。
+func linkButton(text, url string) *interactive.IAAction {
+	return &interactive.IAAction{
+		Text: text,
+		Biz: &interactive.IABiz{
+			Name: "web",
+			Data: &interactive.IABizData{URL: url, MobileURL: url},
+		},
+	}
+}
+
+func (r *Receiver) uploadImage(payload *Payload) string {
+	if !r.opts.UploadImages {
+		return ""
+	}
+	for _, alert := range payload.Alerts {
+		if alert.ImageURL == "" {
+			continue
+		}
+		mediaId, isImage, err := r.cli.UploadFromURL(alert.ImageURL)
+		if err != nil {
+			r.errorf("grafana: upload image from url %q: %v", alert.ImageURL, err)
+			return ""
+		}
+		if !isImage {
+			r.errorf("grafana: upload image from url %q: not a image", alert.ImageURL)
+			return ""
+		}
+		return mediaId
+	}
+	return ""
+}
+
+func (r *Receiver) errorf(format string, args ...any) {
+	if r.opts.Errorf != nil {
+		r.opts.Errorf(format, args...)
+	}
+}
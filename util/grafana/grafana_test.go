@@ -0,0 +1,115 @@
+package grafana
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/eachain/360-tuitui-robot/interactive"
+	"github.com/eachain/360-tuitui-robot/util/alertmanager"
+)
+
+const payloadV10 = `{
+  "receiver": "tuitui",
+  "status": "firing",
+  "orgId": 2,
+  "alerts": [{
+    "status": "firing",
+    "labels": {"alertname": "HighCPU", "grafana_folder": "infra", "instance": "node-1"},
+    "annotations": {"summary": "cpu > 90%"},
+    "startsAt": "2024-06-01T10:00:00Z",
+    "endsAt": "0001-01-01T00:00:00Z",
+    "generatorURL": "http://grafana/alerting/grafana/abc/view",
+    "fingerprint": "57c6d9296de2ad39",
+    "silenceURL": "http://grafana/alerting/silence/new?matcher=alertname%3DHighCPU",
+    "dashboardURL": "http://grafana/d/abc",
+    "panelURL": "http://grafana/d/abc?viewPanel=1",
+    "values": {"B": 95.5},
+    "valueString": "[ var='B' labels={instance=node-1} value=95.5 ]"
+  }],
+  "groupLabels": {"alertname": "HighCPU"},
+  "commonLabels": {"alertname": "HighCPU", "grafana_folder": "infra", "instance": "node-1"},
+  "commonAnnotations": {"summary": "cpu > 90%"},
+  "externalURL": "http://grafana/",
+  "version": "1",
+  "groupKey": "{}/{}:{alertname=\"HighCPU\"}",
+  "truncatedAlerts": 0,
+  "title": "[FIRING:1] HighCPU (infra node-1)",
+  "state": "alerting",
+  "message": "**Firing**"
+}`
+
+func TestReceiverCard(t *testing.T) {
+	var payload Payload
+	if err := json.Unmarshal([]byte(payloadV10), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := New(nil, &Options{
+		Routes: []*Route{
+			{OrgId: 1, Route: alertmanager.Route{Users: []string{"org1"}}},
+			{OrgId: 2, Folder: "infra", Route: alertmanager.Route{Users: []string{"infra"}, Continue: true}},
+			{Route: alertmanager.Route{MatchRE: map[string]string{"instance": "node-.*"}, Groups: []string{"g"}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := r.Route(&payload)
+	if len(rc.Users) != 1 || rc.Users[0] != "infra" || len(rc.Groups) != 1 {
+		t.Fatalf("route: %+v", rc)
+	}
+
+	msg, err := r.Render(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	card := msg.(interactive.Interactive)
+	if card.Head.Text != payload.Title {
+		t.Fatalf("card title: %v", card.Head.Text)
+	}
+	if !strings.Contains(card.Body.Content, "values: B=95.5") {
+		t.Fatalf("card content: %v", card.Body.Content)
+	}
+	if len(card.Action) != 3 || card.Action[2].Biz.Data.URL != payload.Alerts[0].SilenceURL {
+		t.Fatalf("card actions: %+v", card.Action)
+	}
+}
+
+func TestWithAuth(t *testing.T) {
+	body := `{"status":"firing"}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1717236000:" + body))
+	sign := hex.EncodeToString(mac.Sum(nil))
+
+	handler := WithAuth(&AuthOptions{
+		Username:        "grafana",
+		Password:        "pass",
+		HMACSecret:      "secret",
+		TimestampHeader: "X-Grafana-Alerting-Signature-Timestamp",
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tc := range []struct {
+		sign   string
+		status int
+	}{
+		{sign, http.StatusOK},
+		{"bad", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.SetBasicAuth("grafana", "pass")
+		r.Header.Set("X-Grafana-Alerting-Signature", tc.sign)
+		r.Header.Set("X-Grafana-Alerting-Signature-Timestamp", "1717236000")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Fatalf("sign %v: status %v, expect %v", tc.sign, w.Code, tc.status)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package grafana
+
+import (
+	"encoding/json"
+
+	"github.com/eachain/360-tuitui-robot/util/alertmanager"
+)
+
+// Grafana统一报警(unified alerting)单条报警，在Alertmanager报警基础上增加了Grafana特有字段。
+//
+// Grafana 9.x起支持silenceURL/dashboardURL/panelURL/valueString，9.1起支持values，imageURL需配置报警截图。
+type Alert struct {
+	alertmanager.Alert
+	SilenceURL   string                 `json:"silenceURL,omitempty"`
+	DashboardURL string                 `json:"dashboardURL,omitempty"`
+	PanelURL     string                 `json:"panelURL,omitempty"`
+	Values       map[string]json.Number `json:"values,omitempty"`
+	ValueString  string                 `json:"valueString,omitempty"`
+	ImageURL     string                 `json:"imageURL,omitempty"`
+}
+
+// Folder返回报警规则所在文件夹，即grafana_folder标签。
+func (a Alert) Folder() string {
+	return a.Labels["grafana_folder"]
+}
+
+type Alerts []Alert
+
+// Firing返回仍在触发的报警。
+func (as Alerts) Firing() Alerts {
+	var firing Alerts
+	for _, a := range as {
+		if a.Status == alertmanager.StatusFiring {
+			firing = append(firing, a)
+		}
+	}
+	return firing
+}
+
+// Resolved返回已恢复的报警。
+func (as Alerts) Resolved() Alerts {
+	var resolved Alerts
+	for _, a := range as {
+		if a.Status == alertmanager.StatusResolved {
+			resolved = append(resolved, a)
+		}
+	}
+	return resolved
+}
+
+// Grafana webhook请求内容，适用于Grafana 9/10/11统一报警（version "1"）。
+//
+// 文档：https://grafana.com/docs/grafana/latest/alerting/configure-notifications/manage-contact-points/integrations/webhook-notifier/。
+type Payload struct {
+	Receiver          string          `json:"receiver"`
+	Status            string          `json:"status"` // firing, resolved
+	OrgId             int64           `json:"orgId"`
+	Alerts            Alerts          `json:"alerts"`
+	GroupLabels       alertmanager.KV `json:"groupLabels"`
+	CommonLabels      alertmanager.KV `json:"commonLabels"`
+	CommonAnnotations alertmanager.KV `json:"commonAnnotations"`
+	ExternalURL       string          `json:"externalURL"`
+	Version           string          `json:"version"`
+	GroupKey          string          `json:"groupKey"`
+	TruncatedAlerts   int             `json:"truncatedAlerts"`
+	Title             string          `json:"title"`   // Grafana渲染好的标题
+	State             string          `json:"state"`   // alerting, ok
+	Message           string          `json:"message"` // Grafana渲染好的消息内容
+}
+
+// Folder返回所有报警共同的文件夹，报警来自不同文件夹时返回空。
+func (p *Payload) Folder() string {
+	return p.CommonLabels["grafana_folder"]
+}
@@ -0,0 +1,209 @@
+package idempotent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+)
+
+// 相同幂等键的请求正在发送中，尚未返回结果。调用方可稍后重试。
+var ErrInProgress = errors.New("idempotent: request with the same key is in progress")
+
+// 相同幂等键的请求发送失败（如超时），消息可能已经送达，也可能没有。
+// 之后相同幂等键的请求均返回该错误，不再发送；调用方确认需要重发时，先调用Forget。
+var ErrUncertain = errors.New("idempotent: result of request with the same key is uncertain")
+
+// Sender为幂等发送所用的接口，*client.Client实现了该接口。
+type Sender interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error)
+}
+
+type Options struct {
+	// 发送结果保存时长，在此期间相同幂等键的请求直接返回原结果，默认为24小时。
+	Expire time.Duration
+
+	// 发送中标记的保存时长，避免进程在发送过程中退出导致幂等键永久处于发送中状态，默认为1分钟。
+	// 应大于一次发消息请求的超时时间。
+	PendingExpire time.Duration
+
+	// 幂等键前缀，默认为"tuitui:robot:idempotent:"。
+	Prefix string
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Client为幂等发消息客户端。
+//
+// 每次发送需提供调用方生成的幂等键，发送成功后记录key→消息id，
+// 之后相同幂等键的请求不再发送，直接返回原结果。
+//
+// 如果发送返回error（如超时），消息可能已经送达，也可能没有，此时幂等键记为未知状态，返回ErrUncertain，
+// 之后的重试不会重复发送。是否重发由调用方决定：调用Forget后再次发送。
+type Client struct {
+	sender Sender
+	store  Store
+	opts   Options
+}
+
+const (
+	pending   = "pending"
+	uncertain = "uncertain"
+)
+
+// New新建幂等发消息客户端，*Options可以为空（详见Options定义/默认值）。
+func New(sender Sender, store Store, opts *Options) *Client {
+	c := &Client{sender: sender, store: store}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.Expire <= 0 {
+		c.opts.Expire = 24 * time.Hour
+	}
+	if c.opts.PendingExpire <= 0 {
+		c.opts.PendingExpire = time.Minute
+	}
+	if c.opts.Prefix == "" {
+		c.opts.Prefix = "tuitui:robot:idempotent:"
+	}
+	return c
+}
+
+type result[R, F any] struct {
+	Oks      []R    `json:"oks,omitempty"`
+	Fails    []F    `json:"fails,omitempty"`
+	Explains string `json:"explains,omitempty"`
+}
+
+func (r result[R, F]) warning() *client.Warning[F] {
+	if len(r.Fails) == 0 && r.Explains == "" {
+		return nil
+	}
+	return &client.Warning[F]{Fails: r.Fails, Explains: errors.New(r.Explains)}
+}
+
+func do[R, F any](c *Client, key string, send func() ([]R, *client.Warning[F], error)) ([]R, *client.Warning[F], error) {
+	ctx := context.Background()
+
+	ok, err := c.store.SetNX(ctx, key, pending, seconds(c.opts.PendingExpire))
+	if err != nil {
+		return nil, nil, fmt.Errorf("idempotent: store setnx %v: %w", key, err)
+	}
+	if !ok {
+		val, found, err := c.store.Get(ctx, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("idempotent: store get %v: %w", key, err)
+		}
+		if !found || val == pending {
+			return nil, nil, ErrInProgress
+		}
+		if val == uncertain {
+			return nil, nil, ErrUncertain
+		}
+		var res result[R, F]
+		err = json.Unmarshal([]byte(val), &res)
+		if err != nil {
+			return nil, nil, fmt.Errorf("idempotent: json decode result of %v: %w", key, err)
+		}
+		return res.Oks, res.warning(), nil
+	}
+
+	oks, warn, err := send()
+	if err != nil {
+		if setErr := c.store.Set(ctx, key, uncertain, seconds(c.opts.Expire)); setErr != nil && c.opts.Errorf != nil {
+			// 发送中标记过期前，重试仍返回ErrInProgress
+			c.opts.Errorf("idempotent: store set %v: %v", key, setErr)
+		}
+		return nil, nil, fmt.Errorf("%w: %w", ErrUncertain, err)
+	}
+
+	res := result[R, F]{Oks: oks}
+	if warn != nil {
+		res.Fails = warn.Fails
+		if warn.Explains != nil {
+			res.Explains = warn.Explains.Error()
+		}
+	}
+	p, _ := json.Marshal(res)
+	err = c.store.Set(ctx, key, string(p), seconds(c.opts.Expire))
+	if err != nil && c.opts.Errorf != nil {
+		// 消息已经发送成功，不应返回error导致调用方重试。
+		c.opts.Errorf("idempotent: store set %v: %v", key, err)
+	}
+	return oks, warn, nil
+}
+
+// seconds将d向上取整为秒，避免不足1秒的时长变为0。
+func seconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+// Forget删除幂等键key的记录（单聊、群聊、团队帖子），之后相同幂等键的请求将重新发送。
+// 用于ErrUncertain后，调用方确认消息未送达或可以接受重复时重发。
+func (c *Client) Forget(key string) error {
+	ctx := context.Background()
+	for _, kind := range []string{"users:", "groups:", "teams:"} {
+		if err := c.store.Del(ctx, c.opts.Prefix+kind+key); err != nil {
+			return fmt.Errorf("idempotent: store del %v: %w", c.opts.Prefix+kind+key, err)
+		}
+	}
+	return nil
+}
+
+// 批量发送单聊消息。key为调用方提供的幂等键，返回值同client.Client.SendMessageToUsers。
+func (c *Client) SendMessageToUsers(key string, users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	return do(c, c.opts.Prefix+"users:"+key, func() ([]client.UserMsgIdPair, *client.Warning[string], error) {
+		return c.sender.SendMessageToUsers(users, msg)
+	})
+}
+
+// 发送单聊消息。key为调用方提供的幂等键，返回消息id。
+func (c *Client) SendMessageToUser(key, user string, msg client.Message) (string, error) {
+	pairs, warn, err := c.SendMessageToUsers(key, []string{user}, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", fmt.Errorf("send message to user %v failed", user)
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 批量发送群聊消息。key为调用方提供的幂等键，其它参数及返回值同client.Client.SendMessageToGroups。
+func (c *Client) SendMessageToGroups(key string, groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	return do(c, c.opts.Prefix+"groups:"+key, func() ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+		return c.sender.SendMessageToGroups(groupIds, atUsers, msg)
+	})
+}
+
+// 发送群聊消息。key为调用方提供的幂等键，返回消息id。
+func (c *Client) SendMessageToGroup(key, groupId string, msg client.Message) (string, error) {
+	pairs, warn, err := c.SendMessageToGroups(key, []string{groupId}, nil, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", fmt.Errorf("send message to group %v failed", groupId)
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 批量发送帖子到团队。key为调用方提供的幂等键，其它参数及返回值同client.Client.SendPostToTeams。
+func (c *Client) SendPostToTeams(key string, teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+	return do(c, c.opts.Prefix+"teams:"+key, func() ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+		return c.sender.SendPostToTeams(teams, msg)
+	})
+}
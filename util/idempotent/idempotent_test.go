@@ -0,0 +1,89 @@
+package idempotent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type countSender struct {
+	calls int
+	err   error
+}
+
+func (s *countSender) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	s.calls++
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	return []client.UserMsgIdPair{{User: users[0], MsgId: "1001"}},
+		&client.Warning[string]{Fails: users[1:], Explains: errors.New("user not found")}, nil
+}
+
+func (s *countSender) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	s.calls++
+	return nil, nil, s.err
+}
+
+func (s *countSender) SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+	s.calls++
+	return nil, nil, s.err
+}
+
+func TestSendMessageToUsers(t *testing.T) {
+	sender := new(countSender)
+	cli := New(sender, NewMemStore(), nil)
+
+	for i := 0; i < 3; i++ {
+		pairs, warn, err := cli.SendMessageToUsers("alert-1", []string{"zhangsan", "nobody"}, message.NewText("hi"))
+		if err != nil {
+			t.Fatalf("send %v: %v", i, err)
+		}
+		if len(pairs) != 1 || pairs[0].MsgId != "1001" {
+			t.Fatalf("send %v pairs: %v", i, pairs)
+		}
+		if warn == nil || len(warn.Fails) != 1 || warn.Fails[0] != "nobody" {
+			t.Fatalf("send %v warning: %v", i, warn)
+		}
+	}
+	if sender.calls != 1 {
+		t.Fatalf("sender called %v times", sender.calls)
+	}
+}
+
+func TestSendErrorUncertain(t *testing.T) {
+	sender := &countSender{err: errors.New("timeout")}
+	cli := New(sender, NewMemStore(), &Options{PendingExpire: 500 * time.Millisecond})
+
+	_, err := cli.SendMessageToUser("alert-2", "zhangsan", message.NewText("hi"))
+	if !errors.Is(err, ErrUncertain) {
+		t.Fatalf("expect ErrUncertain, got %v", err)
+	}
+
+	// 超时后重试不应重复发送
+	sender.err = nil
+	_, err = cli.SendMessageToUser("alert-2", "zhangsan", message.NewText("hi"))
+	if !errors.Is(err, ErrUncertain) || sender.calls != 1 {
+		t.Fatalf("retry: %v, sender called %v times", err, sender.calls)
+	}
+
+	if err = cli.Forget("alert-2"); err != nil {
+		t.Fatal(err)
+	}
+	msgid, err := cli.SendMessageToUser("alert-2", "zhangsan", message.NewText("hi"))
+	if err != nil || msgid != "1001" || sender.calls != 2 {
+		t.Fatalf("resend: %v, %v, sender called %v times", msgid, err, sender.calls)
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	if n := seconds(500 * time.Millisecond); n != 1 {
+		t.Fatalf("seconds(500ms) = %v", n)
+	}
+	if n := seconds(time.Minute); n != 60 {
+		t.Fatalf("seconds(1m) = %v", n)
+	}
+}
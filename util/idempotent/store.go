@@ -0,0 +1,91 @@
+package idempotent
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store保存幂等键对应的发送结果，接口风格同github.com/eachain/360-tuitui-robot/util/cache.Cache。
+//
+// Store的实现方式有很多种，这里示例两种简单易实现方式：
+//  1. 内存模式，用于单实例，可以用NewMemStore()实现；
+//  2. redis模式，用于分布式环境，可由业务将*redis.Client的SetNX/Set/Get/Del简单包装实现。
+type Store interface {
+	// SetNX当key不存在时写入value，返回是否写入成功。
+	SetNX(ctx context.Context, key string, value string, expireSeconds int64) (bool, error)
+	// Set覆盖写入value。
+	Set(ctx context.Context, key string, value string, expireSeconds int64) error
+	// Get读取key对应的值，key不存在时ok为false。
+	Get(ctx context.Context, key string) (value string, ok bool, err error)
+	// Del删除key。
+	Del(ctx context.Context, key string) error
+}
+
+type memEntry struct {
+	value  string
+	expire time.Time
+}
+
+type memStore struct {
+	mu      sync.Mutex
+	entries map[string]memEntry
+	evicted time.Time
+}
+
+// NewMemStore用内存实现Store，可用于单实例应用。过期的key在读写时惰性清理。
+func NewMemStore() Store {
+	return &memStore{entries: make(map[string]memEntry)}
+}
+
+func (ms *memStore) SetNX(_ context.Context, key, value string, expireSeconds int64) (bool, error) {
+	now := time.Now()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.evict(now)
+	if e, ok := ms.entries[key]; ok && now.Before(e.expire) {
+		return false, nil
+	}
+	ms.entries[key] = memEntry{value: value, expire: now.Add(time.Duration(expireSeconds) * time.Second)}
+	return true, nil
+}
+
+func (ms *memStore) Set(_ context.Context, key, value string, expireSeconds int64) error {
+	now := time.Now()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.evict(now)
+	ms.entries[key] = memEntry{value: value, expire: now.Add(time.Duration(expireSeconds) * time.Second)}
+	return nil
+}
+
+func (ms *memStore) Get(_ context.Context, key string) (string, bool, error) {
+	now := time.Now()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	e, ok := ms.entries[key]
+	if !ok || !now.Before(e.expire) {
+		return "", false, nil
+	}
+	return e.value, true, nil
+}
+
+func (ms *memStore) Del(_ context.Context, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.entries, key)
+	return nil
+}
+
+// evict每分钟最多全量扫描一次，清理过期key。
+func (ms *memStore) evict(now time.Time) {
+	if now.Sub(ms.evicted) < time.Minute {
+		return
+	}
+	ms.evicted = now
+	for key, e := range ms.entries {
+		if !now.Before(e.expire) {
+			delete(ms.entries, key)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Package markdown安全地拼接Markdown富文本（团队帖子）及页面消息。
+//
+// 用户输入的文本经Text/Textf转义，不会被解析为Markdown、html或模板指令；
+// @用户、@标签、@所有人以模板函数输出，模板分隔符在渲染时选取，保证不与正文冲突。
+package markdown
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/richtext"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// @对象所用的模板函数。
+const (
+	FuncAtUser = "tuitui_at"
+	FuncAtTag  = "tuitui_at_tag"
+	FuncAtAll  = "tuitui_at_all"
+)
+
+// Mention为一个@对象。
+type Mention struct {
+	Type webhook.PostAtType // AtUser, AtTag, AtAll
+	Id   string             // 用户域账号或标签id，AtAll时为空
+}
+
+func AtUser(account string) Mention { return Mention{Type: webhook.AtUser, Id: account} }
+func AtTag(tagId string) Mention    { return Mention{Type: webhook.AtTag, Id: tagId} }
+func AtAll() Mention                { return Mention{Type: webhook.AtAll} }
+
+// Template返回以left、right为分隔符的模板指令，如{{tuitui_at "zhangsan"}}。
+func (m Mention) Template(left, right string) string {
+	switch m.Type {
+	case webhook.AtAll:
+		return left + FuncAtAll + right
+	case webhook.AtTag:
+		return left + FuncAtTag + " " + strconv.Quote(m.Id) + right
+	default:
+		return left + FuncAtUser + " " + strconv.Quote(m.Id) + right
+	}
+}
+
+// 候选模板分隔符，依次选取第一对不出现在正文中的。
+var candidateDelims = [][2]string{
+	{"{{", "}}"},
+	{"[[", "]]"},
+	{"{%", "%}"},
+	{"<%", "%>"},
+	{"${", "}$"},
+}
+
+// Delims返回不出现在contents中的一对模板分隔符。
+func Delims(contents ...string) (left, right string) {
+	unused := func(l, r string) bool {
+		for _, c := range contents {
+			if strings.Contains(c, l) || strings.Contains(c, r) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, d := range candidateDelims {
+		if unused(d[0], d[1]) {
+			return d[0], d[1]
+		}
+	}
+	for i := 0; ; i++ {
+		l, r := "{{"+strconv.Itoa(i)+"#", "#"+strconv.Itoa(i)+"}}"
+		if unused(l, r) {
+			return l, r
+		}
+	}
+}
+
+// 片段：content为Markdown原文，mention不为空时为@对象。
+type part struct {
+	content string
+	mention *Mention
+}
+
+// Builder用于拼接Markdown。零值可用，所有方法返回Builder本身以便链式调用。
+type Builder struct {
+	parts []part
+}
+
+func New() *Builder {
+	return new(Builder)
+}
+
+func (b *Builder) add(content string) *Builder {
+	b.parts = append(b.parts, part{content: content})
+	return b
+}
+
+// Markdown追加可信的Markdown原文，不做任何转义。
+// 原文中出现的模板分隔符同样会使渲染时避开这些分隔符。
+func (b *Builder) Markdown(md string) *Builder {
+	return b.add(md)
+}
+
+// Text追加纯文本，转义其中的Markdown及html特殊字符。
+func (b *Builder) Text(text string) *Builder {
+	return b.add(richtext.EscapeMarkdown(text))
+}
+
+// Textf以可信的format格式化args，除数字及bool外，args均按fmt.Sprint转为字符串后转义。
+func (b *Builder) Textf(format string, args ...any) *Builder {
+	escaped := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			escaped[i] = v
+		case string:
+			escaped[i] = richtext.EscapeMarkdown(v)
+		case error:
+			escaped[i] = richtext.EscapeMarkdown(v.Error())
+		case fmt.Stringer:
+			escaped[i] = richtext.EscapeMarkdown(v.String())
+		default:
+			escaped[i] = richtext.EscapeMarkdown(fmt.Sprint(v))
+		}
+	}
+	return b.add(fmt.Sprintf(format, escaped...))
+}
+
+// Bold追加加粗文本。
+func (b *Builder) Bold(text string) *Builder {
+	if text == "" {
+		return b
+	}
+	return b.add("**" + richtext.EscapeMarkdown(text) + "**")
+}
+
+// Code追加行内代码。
+func (b *Builder) Code(code string) *Builder {
+	return b.add(richtext.Markdown(&richtext.Node{Type: richtext.Code, Text: code}))
+}
+
+// CodeBlock追加代码块，代码块前后自动换行。
+func (b *Builder) CodeBlock(lang, code string) *Builder {
+	b.ensureNewline()
+	b.add(richtext.Markdown(&richtext.Node{Type: richtext.CodeBlock, Lang: lang, Text: code}))
+	return b.add("\n")
+}
+
+// Link追加链接，text为空时展示url。
+func (b *Builder) Link(text, url string) *Builder {
+	link := &richtext.Node{Type: richtext.Link, Href: url}
+	if text != "" {
+		link.Children = []*richtext.Node{{Type: richtext.Text, Text: text}}
+	}
+	return b.add(richtext.Markdown(link))
+}
+
+// At追加@对象。
+func (b *Builder) At(m Mention) *Builder {
+	b.parts = append(b.parts, part{mention: &m})
+	return b
+}
+
+func (b *Builder) AtUser(account string) *Builder { return b.At(AtUser(account)) }
+func (b *Builder) AtTag(tagId string) *Builder    { return b.At(AtTag(tagId)) }
+func (b *Builder) AtAll() *Builder                { return b.At(AtAll()) }
+
+// Line换行。
+func (b *Builder) Line() *Builder {
+	return b.add("\n")
+}
+
+// Paragraph另起一段。
+func (b *Builder) Paragraph() *Builder {
+	b.ensureNewline()
+	return b.add("\n")
+}
+
+func (b *Builder) ensureNewline() {
+	for i := len(b.parts) - 1; i >= 0; i-- {
+		if b.parts[i].mention != nil {
+			break
+		}
+		if c := b.parts[i].content; c != "" {
+			if !strings.HasSuffix(c, "\n") {
+				b.add("\n")
+			}
+			return
+		}
+	}
+	if len(b.parts) > 0 {
+		b.add("\n")
+	}
+}
+
+// Render返回Markdown及所用模板分隔符，不含@对象时分隔符为空。
+func (b *Builder) Render() (md, left, right string) {
+	var contents []string
+	for _, p := range b.parts {
+		if p.mention == nil {
+			contents = append(contents, p.content)
+		}
+	}
+	if len(contents) == len(b.parts) {
+		return strings.Join(contents, ""), "", ""
+	}
+
+	left, right = Delims(contents...)
+	for i := 0; ; i++ {
+		if md, ok := b.render(left, right); ok {
+			return md, left, right
+		}
+		// 分隔符与相邻正文拼接后出现在非@位置，如"{"后接"{{tuitui_at"
+		left, right = "{{"+strconv.Itoa(i)+"#", "#"+strconv.Itoa(i)+"}}"
+	}
+}
+
+// render以left、right渲染，分隔符只出现在@对象处时ok为true。
+func (b *Builder) render(left, right string) (md string, ok bool) {
+	var s strings.Builder
+	lefts, rights := make(map[int]bool), make(map[int]bool)
+	for _, p := range b.parts {
+		if p.mention == nil {
+			s.WriteString(p.content)
+			continue
+		}
+		lefts[s.Len()] = true
+		s.WriteString(p.mention.Template(left, right))
+		rights[s.Len()-len(right)] = true
+	}
+	md = s.String()
+	return md, onlyAt(md, left, lefts) && onlyAt(md, right, rights)
+}
+
+// onlyAt判断sub在s中出现的位置（含重叠）是否均在pos中。
+func onlyAt(s, sub string, pos map[int]bool) bool {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if strings.HasPrefix(s[i:], sub) && !pos[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String返回Markdown，同Render。
+func (b *Builder) String() string {
+	md, _, _ := b.Render()
+	return md
+}
+
+// RichText返回Markdown富文本（团队帖子），含@对象时设置模板分隔符。
+func (b *Builder) RichText() message.RichText {
+	md, left, right := b.Render()
+	rt := message.NewRichTextMarkdown(md)
+	if left != "" {
+		rt = rt.WithDelims(left, right)
+	}
+	return rt
+}
+
+// Page返回Markdown格式的页面消息，含@对象时设置模板分隔符。
+func (b *Builder) Page(title string) message.Page {
+	md, left, right := b.Render()
+	page := message.NewPage().WithTitle(title).WithFormat("markdown").WithContent(md)
+	if left != "" {
+		page = page.WithDelims(left, right)
+	}
+	return page
+}
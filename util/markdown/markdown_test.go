@@ -0,0 +1,47 @@
+package markdown
+
+import "testing"
+
+func TestBuilder(t *testing.T) {
+	rt := New().AtUser("zhangsan").Text(" 报警 <b>{{.x}}</b> *x*").
+		Paragraph().Textf("错误：%v，次数：%d", "a_b", 3).
+		CodeBlock("", "x := 1").
+		Link("详情", "https://a.com/(1)").
+		RichText()
+
+	if rt.DelimsLeft != "[[" || rt.DelimsRight != "]]" {
+		t.Fatalf("delims: %q %q", rt.DelimsLeft, rt.DelimsRight)
+	}
+	expected := `[[tuitui_at "zhangsan"]] 报警 &lt;b&gt;{{.x}}&lt;/b&gt; \*x\*` + "\n\n" +
+		`错误：a\_b，次数：3` + "\n```\nx := 1\n```\n" +
+		`[详情](https://a.com/%281%29)`
+	if rt.Markdown != expected {
+		t.Fatalf("markdown:\n%s\nexpected:\n%s", rt.Markdown, expected)
+	}
+}
+
+func TestNoMention(t *testing.T) {
+	rt := New().Text("- {{x}}").RichText()
+	if rt.DelimsLeft != "" || rt.DelimsRight != "" {
+		t.Fatalf("unexpected delims: %q %q", rt.DelimsLeft, rt.DelimsRight)
+	}
+	if rt.Markdown != `\- {{x}}` {
+		t.Fatalf("markdown: %q", rt.Markdown)
+	}
+}
+
+func TestDelimsBoundary(t *testing.T) {
+	// "{"紧邻"{{"时会被解析为"{{{"，需换用其它分隔符
+	md, left, right := New().Markdown("{").AtAll().Markdown("}").Render()
+	if left == "{{" {
+		t.Fatalf("delims collide with content: %q", md)
+	}
+	if md != "{"+left+FuncAtAll+right+"}" {
+		t.Fatalf("markdown: %q", md)
+	}
+
+	l, r := Delims("{{a}}", "[[b]]")
+	if l != "{%" || r != "%}" {
+		t.Fatalf("delims: %q %q", l, r)
+	}
+}
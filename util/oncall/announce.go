@@ -0,0 +1,90 @@
+package oncall
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// 最长休眠时间，休眠期间换班等调整，最迟在此时间后生效。
+const maxSleep = time.Hour
+
+// Announcer在交接班时向服务配置的群发通知。
+type Announcer struct {
+	s    *Schedule
+	cli  Client
+	opts Options
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Announce启动交接班通知，仅通知配置了Group的服务。*Options可以为空（详见Options定义/默认值）。
+func Announce(s *Schedule, cli Client, opts *Options) *Announcer {
+	a := &Announcer{
+		s:    s,
+		cli:  cli,
+		opts: opts.withDefault(),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Stop停止通知，等待后台goroutine退出。
+func (a *Announcer) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+func (a *Announcer) run() {
+	defer close(a.done)
+
+	// 每个服务已通知到的时间点。
+	cursor := make(map[string]time.Time)
+	for {
+		now := a.opts.Now()
+		wake := now.Add(maxSleep)
+		for _, name := range a.s.Services() {
+			group := a.s.Group(name)
+			if group == "" {
+				continue
+			}
+			after, ok := cursor[name]
+			if !ok {
+				after = now
+				cursor[name] = now
+			}
+			handoff, ok, err := a.s.NextHandoff(name, after)
+			if err != nil || !ok {
+				continue
+			}
+			if !handoff.Time.After(now) {
+				a.announce(group, handoff)
+				cursor[name] = handoff.Time
+				wake = now
+				continue
+			}
+			if handoff.Time.Before(wake) {
+				wake = handoff.Time
+			}
+		}
+
+		timer := time.NewTimer(wake.Sub(now))
+		select {
+		case <-a.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (a *Announcer) announce(group string, h Handoff) {
+	text := fmt.Sprintf("【%v】值班交接：%v → %v", h.Service, h.From, h.To)
+	if _, err := a.cli.SendMessageToGroup(group, message.NewText(text)); err != nil {
+		a.opts.errorf("oncall: announce handoff of %v to group %v: %v", h.Service, group, err)
+	}
+}
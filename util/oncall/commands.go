@@ -0,0 +1,235 @@
+package oncall
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// Client为值班命令及交接通知所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUser(user string, msg client.Message) (string, error)
+	SendMessageToGroup(groupId string, msg client.Message) (string, error)
+}
+
+type Options struct {
+	// 查看值班表时展示的天数，默认为7天。
+	Days int
+
+	// 换班请求等待对方同意的时长，默认为1小时。
+	SwapExpire time.Duration
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+func (o *Options) withDefault() Options {
+	var opts Options
+	if o != nil {
+		opts = *o
+	}
+	if opts.Days <= 0 {
+		opts.Days = 7
+	}
+	if opts.SwapExpire <= 0 {
+		opts.SwapExpire = time.Hour
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return opts
+}
+
+func (o Options) errorf(format string, args ...any) {
+	if o.Errorf != nil {
+		o.Errorf(format, args...)
+	}
+}
+
+const usage = `值班命令：
+oncall：查看所有服务当前值班人
+oncall <服务>：查看服务值班表
+swap <服务> <域账号>：请求与对方交换各自最近的一个班次，对方同意后生效
+accept <域账号>：同意对方的换班请求
+reject <域账号>：拒绝对方的换班请求`
+
+// Commands返回处理单聊值班命令的webhook.Callback，支持：
+//   - oncall：查看所有服务当前值班人；
+//   - oncall <服务>：查看服务值班表；
+//   - swap <服务> <域账号>：请求与对方交换各自最近的一个班次，通知对方确认；
+//   - accept <域账号>、reject <域账号>：同意或拒绝对方的换班请求，同意后换班生效；
+//   - help：查看帮助。
+//
+// 换班请求保存在内存中，Options.SwapExpire内未确认则失效。
+// 其它消息忽略，可通过util/chain与其它Callback组合使用。
+func Commands(s *Schedule, cli Client, opts *Options) webhook.Callback {
+	c := &commander{
+		s:     s,
+		o:     opts.withDefault(),
+		swaps: make(map[swapKey]swapRequest),
+	}
+	return webhook.Callback{
+		OnReceiveSingleMessage: func(event webhook.SingleMessageEvent) {
+			reply, notify := c.command(event.User.Account, event.Text)
+			if reply == "" {
+				return
+			}
+			if _, err := cli.SendMessageToUser(event.User.Account, message.NewText(reply)); err != nil {
+				c.o.errorf("oncall: reply to %v: %v", event.User.Account, err)
+			}
+			for user, text := range notify {
+				if _, err := cli.SendMessageToUser(user, message.NewText(text)); err != nil {
+					c.o.errorf("oncall: notify %v: %v", user, err)
+				}
+			}
+		},
+	}
+}
+
+// swapKey为换班请求的发起人及对方。
+type swapKey struct {
+	from, to string
+}
+
+type swapRequest struct {
+	service string
+	expire  time.Time
+}
+
+type commander struct {
+	s *Schedule
+	o Options
+
+	mu    sync.Mutex
+	swaps map[swapKey]swapRequest // 等待对方确认的换班请求
+}
+
+// command执行命令，返回回复内容及需要通知的其他人。
+func (c *commander) command(user, text string) (string, map[string]string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	now := c.o.Now()
+
+	switch strings.ToLower(fields[0]) {
+	case "help":
+		return usage, nil
+
+	case "oncall":
+		if len(fields) == 1 {
+			return current(c.s, now), nil
+		}
+		shifts, err := c.s.Shifts(fields[1], now, now.AddDate(0, 0, c.o.Days))
+		if err != nil {
+			return err.Error(), nil
+		}
+		return formatShifts(fields[1], shifts), nil
+
+	case "swap":
+		if len(fields) != 3 {
+			return usage, nil
+		}
+		return c.request(fields[1], user, fields[2], now)
+
+	case "accept", "reject":
+		if len(fields) != 2 {
+			return usage, nil
+		}
+		req, ok := c.take(swapKey{from: fields[1], to: user}, now)
+		if !ok {
+			return fmt.Sprintf("没有来自%v的换班请求", fields[1]), nil
+		}
+		if strings.ToLower(fields[0]) == "reject" {
+			return fmt.Sprintf("已拒绝%v的换班请求", fields[1]),
+				map[string]string{fields[1]: fmt.Sprintf("%v拒绝了你的%v换班请求", user, req.service)}
+		}
+		return c.swap(req.service, fields[1], user, now)
+	}
+	return "", nil
+}
+
+// request发起换班请求，等待对方确认。
+func (c *commander) request(service, user, other string, now time.Time) (string, map[string]string) {
+	mine, theirs, err := c.s.SwapPreview(service, user, other, now)
+	if err != nil {
+		return "换班失败：" + err.Error(), nil
+	}
+
+	c.mu.Lock()
+	for k, req := range c.swaps {
+		if !now.Before(req.expire) {
+			delete(c.swaps, k)
+		}
+	}
+	c.swaps[swapKey{from: user, to: other}] = swapRequest{service: service, expire: now.Add(c.o.SwapExpire)}
+	c.mu.Unlock()
+
+	reply := fmt.Sprintf("已向%v发送%v换班请求，对方同意后生效：\n%v 由 %v 值班\n%v 由 %v 值班",
+		other, service, formatRange(mine), other, formatRange(theirs), user)
+	notice := fmt.Sprintf("%v请求与你交换%v的班次：\n%v 由你值班\n%v 由 %v 值班\n回复\"accept %v\"同意，\"reject %v\"拒绝",
+		user, service, formatRange(mine), formatRange(theirs), user, user, user)
+	return reply, map[string]string{other: notice}
+}
+
+// take取出未过期的换班请求。
+func (c *commander) take(k swapKey, now time.Time) (swapRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	req, ok := c.swaps[k]
+	delete(c.swaps, k)
+	return req, ok && now.Before(req.expire)
+}
+
+// swap在对方同意后执行换班，from为请求发起人，to为同意的人。
+func (c *commander) swap(service, from, to string, now time.Time) (string, map[string]string) {
+	mine, theirs, err := c.s.Swap(service, from, to, now)
+	if err != nil {
+		return "换班失败：" + err.Error(), map[string]string{from: fmt.Sprintf("%v同意了换班，但换班失败：%v", to, err)}
+	}
+	reply := fmt.Sprintf("%v换班成功：\n%v 由你值班\n%v 由 %v 值班",
+		service, formatRange(mine), formatRange(theirs), from)
+	notice := fmt.Sprintf("%v同意了换班，%v换班成功：\n%v 由 %v 值班\n%v 由你值班",
+		to, service, formatRange(mine), to, formatRange(theirs))
+	return reply, map[string]string{from: notice}
+}
+
+func current(s *Schedule, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("当前值班：")
+	for _, name := range s.Services() {
+		user, err := s.WhoIsOnCall(name, now)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%v：%v", name, user)
+	}
+	return b.String()
+}
+
+func formatShifts(name string, shifts []Shift) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v值班表：", name)
+	for _, shift := range shifts {
+		fmt.Fprintf(&b, "\n%v %v", formatRange(shift), shift.User)
+		if shift.Override {
+			b.WriteString("（替班）")
+		}
+	}
+	return b.String()
+}
+
+func formatRange(shift Shift) string {
+	const layout = "01-02 15:04"
+	loc := shift.Start.Location()
+	return shift.Start.Format(layout) + " ~ " + shift.End.In(loc).Format(layout)
+}
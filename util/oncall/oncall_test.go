@@ -0,0 +1,204 @@
+package oncall
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+const config = `{
+  "services": [{
+    "name": "api",
+    "timezone": "UTC",
+    "rotation": {"type": "weekly", "users": ["a", "b", "c"], "start": "2024-01-01", "handoff": "10:00"},
+    "overrides": [{"user": "d", "start": "2024-01-03T00:00:00Z", "end": "2024-01-04T00:00:00Z"}],
+    "group": "g1"
+  }]
+}`
+
+func load(t *testing.T) (*Schedule, string) {
+	path := filepath.Join(t.TempDir(), "oncall.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestWhoIsOnCall(t *testing.T) {
+	s, _ := load(t)
+	cases := map[string]string{
+		"2024-01-01T09:59:00Z": "c", // 交接前仍为上一班
+		"2024-01-01T10:00:00Z": "a",
+		"2024-01-03T12:00:00Z": "d", // 替班
+		"2024-01-08T10:00:00Z": "b",
+		"2024-01-15T10:00:00Z": "c",
+		"2024-01-22T10:00:00Z": "a",
+	}
+	for at, want := range cases {
+		got, err := s.WhoIsOnCall("api", date(at))
+		if err != nil || got != want {
+			t.Errorf("%v: got %v, %v, want %v", at, got, err, want)
+		}
+	}
+
+	h, ok, err := s.NextHandoff("api", date("2024-01-04T00:00:00Z"))
+	if err != nil || !ok || h.From != "a" || h.To != "b" || !h.Time.Equal(date("2024-01-08T10:00:00Z")) {
+		t.Fatalf("next handoff: %+v, %v, %v", h, ok, err)
+	}
+}
+
+func TestShifts(t *testing.T) {
+	s, _ := load(t)
+	shifts, err := s.Shifts("api", date("2024-01-02T00:00:00Z"), date("2024-01-09T00:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, shift := range shifts {
+		got = append(got, shift.User+"@"+shift.Start.Format("02T15"))
+	}
+	want := "a@01T10,d@03T00,a@04T00,b@08T10"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("shifts: %v, want %v", strings.Join(got, ","), want)
+	}
+}
+
+type fakeClient struct {
+	users  map[string][]string
+	groups map[string][]string
+}
+
+func (c *fakeClient) SendMessageToUser(user string, msg client.Message) (string, error) {
+	if c.users == nil {
+		c.users = make(map[string][]string)
+	}
+	c.users[user] = append(c.users[user], msg.(message.Text).Content)
+	return "m1", nil
+}
+
+func (c *fakeClient) SendMessageToGroup(groupId string, msg client.Message) (string, error) {
+	if c.groups == nil {
+		c.groups = make(map[string][]string)
+	}
+	c.groups[groupId] = append(c.groups[groupId], msg.(message.Text).Content)
+	return "m1", nil
+}
+
+func TestSwap(t *testing.T) {
+	s, path := load(t)
+	cli := new(fakeClient)
+	now := date("2024-01-05T00:00:00Z")
+	cb := Commands(s, cli, &Options{Now: func() time.Time { return now }})
+
+	var event webhook.SingleMessageEvent
+	event.User.Account = "a"
+	event.Text = "swap api c"
+	cb.OnReceiveSingleMessage(event)
+
+	// 对方同意前不换班。
+	if len(cli.users["c"]) != 1 || !strings.Contains(cli.users["c"][0], "accept a") {
+		t.Fatalf("request: %v", cli.users["c"])
+	}
+	if got, _ := s.WhoIsOnCall("api", now); got != "a" {
+		t.Fatalf("before accept, on call: %v", got)
+	}
+
+	// 只有被请求的人可以同意。
+	event.User.Account = "b"
+	event.Text = "accept a"
+	cb.OnReceiveSingleMessage(event)
+	if got, _ := s.WhoIsOnCall("api", now); got != "a" {
+		t.Fatalf("accepted by b, on call: %v", got)
+	}
+
+	event.User.Account = "c"
+	event.Text = "accept a"
+	cb.OnReceiveSingleMessage(event)
+
+	if len(cli.users["c"]) != 2 || !strings.Contains(cli.users["c"][1], "换班成功") {
+		t.Fatalf("reply: %v", cli.users["c"])
+	}
+	if len(cli.users["a"]) != 2 || !strings.Contains(cli.users["a"][1], "同意") {
+		t.Fatalf("notify: %v", cli.users["a"])
+	}
+	if got, _ := s.WhoIsOnCall("api", now); got != "c" {
+		t.Fatalf("after swap, on call: %v", got)
+	}
+	if got, _ := s.WhoIsOnCall("api", date("2024-01-15T12:00:00Z")); got != "a" {
+		t.Fatalf("after swap, on call: %v", got)
+	}
+
+	// 换班写回配置文件。
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.WhoIsOnCall("api", now); got != "c" {
+		t.Fatalf("reloaded, on call: %v", got)
+	}
+}
+
+func TestSwapRejectExpire(t *testing.T) {
+	s, _ := load(t)
+	cli := new(fakeClient)
+	now := date("2024-01-05T00:00:00Z")
+	cb := Commands(s, cli, &Options{Now: func() time.Time { return now }})
+
+	var event webhook.SingleMessageEvent
+	send := func(user, text string) {
+		event.User.Account, event.Text = user, text
+		cb.OnReceiveSingleMessage(event)
+	}
+
+	send("a", "swap api c")
+	send("c", "reject a")
+	send("c", "accept a")
+	if got, _ := s.WhoIsOnCall("api", now); got != "a" {
+		t.Fatalf("rejected, on call: %v", got)
+	}
+
+	send("a", "swap api c")
+	now = now.Add(2 * time.Hour)
+	send("c", "accept a")
+	if got, _ := s.WhoIsOnCall("api", now); got != "a" {
+		t.Fatalf("expired, on call: %v", got)
+	}
+	if last := cli.users["c"][len(cli.users["c"])-1]; !strings.Contains(last, "没有来自a的换班请求") {
+		t.Fatalf("expired reply: %v", last)
+	}
+}
+
+func TestSaveErrorRollback(t *testing.T) {
+	s, path := load(t)
+	s.path = filepath.Join(path, "missing", "oncall.json")
+	now := date("2024-01-05T00:00:00Z")
+
+	if _, _, err := s.Swap("api", "a", "c", now); err == nil {
+		t.Fatal("expected save error")
+	}
+	err := s.AddOverride("api", Override{User: "b", Start: now, End: now.Add(time.Hour)})
+	if err == nil {
+		t.Fatal("expected save error")
+	}
+	if got, _ := s.WhoIsOnCall("api", now); got != "a" {
+		t.Fatalf("after failed save, on call: %v", got)
+	}
+}
@@ -0,0 +1,444 @@
+package oncall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/internal/fileutil"
+)
+
+// 轮值方式。
+const (
+	RotationDaily  = "daily"  // 每天交接一次
+	RotationWeekly = "weekly" // 每周交接一次，交接日为Start对应的星期
+)
+
+type Rotation struct {
+	Type    string   `json:"type"`    // RotationDaily, RotationWeekly
+	Users   []string `json:"users"`   // 按顺序轮值的域账号
+	Start   string   `json:"start"`   // 第一个班次开始日期，格式如"2024-01-01"，此日由Users[0]值班
+	Handoff string   `json:"handoff"` // 交接时间，格式如"10:00"，默认为"00:00"
+}
+
+// 临时替班，[Start, End)期间由User值班。多条重叠时，靠后的生效。
+type Override struct {
+	User  string    `json:"user"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type Service struct {
+	Name      string     `json:"name"`
+	TimeZone  string     `json:"timezone,omitempty"` // 如"Asia/Shanghai"，默认为"Local"
+	Rotation  Rotation   `json:"rotation"`
+	Overrides []Override `json:"overrides,omitempty"`
+
+	// 交接班时发通知的群，为空不通知。
+	Group string `json:"group,omitempty"`
+}
+
+type Config struct {
+	Services []Service `json:"services"`
+}
+
+// 班次，[Start, End)期间由User值班。
+type Shift struct {
+	User     string
+	Start    time.Time
+	End      time.Time
+	Override bool // 是否为临时替班
+}
+
+// 交接班。
+type Handoff struct {
+	Service string
+	Time    time.Time
+	From    string
+	To      string
+}
+
+type service struct {
+	Service
+	loc    *time.Location
+	start  time.Time // 第一个班次开始时间
+	period int       // 班次天数
+}
+
+// Schedule为值班表，可并发使用。
+type Schedule struct {
+	mu       sync.RWMutex
+	path     string
+	services map[string]*service
+	order    []string
+}
+
+// Load从JSON配置文件加载值班表。替班调整（如Swap）会写回该文件。
+func Load(path string) (*Schedule, error) {
+	p, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("oncall: read config: %w", err)
+	}
+	var cfg Config
+	if err = json.Unmarshal(p, &cfg); err != nil {
+		return nil, fmt.Errorf("oncall: parse config %v: %w", path, err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.path = path
+	return s, nil
+}
+
+// New以cfg新建值班表，替班调整仅保存在内存中。
+func New(cfg Config) (*Schedule, error) {
+	s := &Schedule{services: make(map[string]*service)}
+	for _, svc := range cfg.Services {
+		parsed, err := parseService(svc)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.services[svc.Name]; ok {
+			return nil, fmt.Errorf("oncall: duplicate service %q", svc.Name)
+		}
+		s.services[svc.Name] = parsed
+		s.order = append(s.order, svc.Name)
+	}
+	return s, nil
+}
+
+func parseService(svc Service) (*service, error) {
+	if svc.Name == "" {
+		return nil, errors.New("oncall: service name is empty")
+	}
+	rot := svc.Rotation
+	if len(rot.Users) == 0 {
+		return nil, fmt.Errorf("oncall: service %v: rotation has no users", svc.Name)
+	}
+
+	parsed := &service{Service: svc}
+	switch rot.Type {
+	case RotationDaily:
+		parsed.period = 1
+	case RotationWeekly:
+		parsed.period = 7
+	default:
+		return nil, fmt.Errorf("oncall: service %v: unknown rotation type %q", svc.Name, rot.Type)
+	}
+
+	parsed.loc = time.Local
+	if svc.TimeZone != "" {
+		loc, err := time.LoadLocation(svc.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("oncall: service %v: %w", svc.Name, err)
+		}
+		parsed.loc = loc
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", rot.Start, parsed.loc)
+	if err != nil {
+		return nil, fmt.Errorf("oncall: service %v: rotation start: %w", svc.Name, err)
+	}
+	var hm time.Time
+	if rot.Handoff != "" {
+		hm, err = time.Parse("15:04", rot.Handoff)
+		if err != nil {
+			return nil, fmt.Errorf("oncall: service %v: rotation handoff: %w", svc.Name, err)
+		}
+	}
+	parsed.start = time.Date(date.Year(), date.Month(), date.Day(), hm.Hour(), hm.Minute(), 0, 0, parsed.loc)
+
+	for _, o := range svc.Overrides {
+		if o.User == "" || !o.Start.Before(o.End) {
+			return nil, fmt.Errorf("oncall: service %v: invalid override %+v", svc.Name, o)
+		}
+	}
+	return parsed, nil
+}
+
+// Services返回所有服务名，按配置顺序排列。
+func (s *Schedule) Services() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.order...)
+}
+
+// Group返回服务交接班通知群。
+func (s *Schedule) Group(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if svc := s.services[name]; svc != nil {
+		return svc.Group
+	}
+	return ""
+}
+
+func (s *Schedule) service(name string) (*service, error) {
+	svc := s.services[name]
+	if svc == nil {
+		return nil, fmt.Errorf("oncall: unknown service %q", name)
+	}
+	return svc, nil
+}
+
+// WhoIsOnCall返回服务在t时刻的值班人。
+func (s *Schedule) WhoIsOnCall(name string, t time.Time) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	svc, err := s.service(name)
+	if err != nil {
+		return "", err
+	}
+	user, _ := svc.at(t)
+	return user, nil
+}
+
+// Shifts返回服务[from, to)期间的班次，已合并临时替班。第一个班次的Start可能早于from。
+func (s *Schedule) Shifts(name string, from, to time.Time) ([]Shift, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	svc, err := s.service(name)
+	if err != nil {
+		return nil, err
+	}
+	return svc.shifts(from, to), nil
+}
+
+// NextHandoff返回服务after之后（不含after）最近一次交接班，一个轮值周期内无交接时返回false。
+func (s *Schedule) NextHandoff(name string, after time.Time) (Handoff, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	svc, err := s.service(name)
+	if err != nil {
+		return Handoff{}, false, err
+	}
+
+	horizon := after.AddDate(0, 0, svc.period*len(svc.Rotation.Users)+1)
+	shifts := svc.shifts(after, horizon)
+	for i := 1; i < len(shifts); i++ {
+		if shifts[i].Start.After(after) {
+			return Handoff{
+				Service: name,
+				Time:    shifts[i].Start,
+				From:    shifts[i-1].User,
+				To:      shifts[i].User,
+			}, true, nil
+		}
+	}
+	return Handoff{}, false, nil
+}
+
+// AddOverride添加临时替班。
+func (s *Schedule) AddOverride(name string, o Override) error {
+	if o.User == "" || !o.Start.Before(o.End) {
+		return fmt.Errorf("oncall: invalid override %+v", o)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, err := s.service(name)
+	if err != nil {
+		return err
+	}
+	n := len(svc.Overrides)
+	svc.Overrides = append(svc.Overrides, o)
+	if err = s.save(); err != nil {
+		svc.Overrides = svc.Overrides[:n]
+		return err
+	}
+	return nil
+}
+
+// SwapPreview返回Swap(name, a, b, now)将交换的班次，不做修改。
+func (s *Schedule) SwapPreview(name, a, b string, now time.Time) (Shift, Shift, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	svc, err := s.service(name)
+	if err != nil {
+		return Shift{}, Shift{}, err
+	}
+	return svc.swapShifts(name, a, b, now)
+}
+
+// Swap交换a与b在now之后各自最近的一个班次（a正在值班时，从now开始交换）。
+func (s *Schedule) Swap(name, a, b string, now time.Time) (Shift, Shift, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, err := s.service(name)
+	if err != nil {
+		return Shift{}, Shift{}, err
+	}
+	sa, sb, err := svc.swapShifts(name, a, b, now)
+	if err != nil {
+		return Shift{}, Shift{}, err
+	}
+
+	n := len(svc.Overrides)
+	svc.Overrides = append(svc.Overrides,
+		Override{User: b, Start: sa.Start, End: sa.End},
+		Override{User: a, Start: sb.Start, End: sb.End},
+	)
+	if err = s.save(); err != nil {
+		svc.Overrides = svc.Overrides[:n]
+		return Shift{}, Shift{}, err
+	}
+	return sa, sb, nil
+}
+
+// swapShifts返回a与b在now之后各自最近的一个班次。
+func (svc *service) swapShifts(name, a, b string, now time.Time) (Shift, Shift, error) {
+	if a == b {
+		return Shift{}, Shift{}, errors.New("oncall: swap with oneself")
+	}
+	horizon := now.AddDate(0, 0, 2*svc.period*len(svc.Rotation.Users)+1)
+	shifts := svc.shifts(now, horizon)
+	sa, okA := nextShift(shifts, a, now)
+	sb, okB := nextShift(shifts, b, now)
+	if !okA {
+		return Shift{}, Shift{}, fmt.Errorf("oncall: %v has no upcoming shift of %v", a, name)
+	}
+	if !okB {
+		return Shift{}, Shift{}, fmt.Errorf("oncall: %v has no upcoming shift of %v", b, name)
+	}
+	return sa, sb, nil
+}
+
+func nextShift(shifts []Shift, user string, now time.Time) (Shift, bool) {
+	for _, shift := range shifts {
+		if shift.User == user && shift.End.After(now) {
+			if shift.Start.Before(now) {
+				shift.Start = now
+			}
+			return shift, true
+		}
+	}
+	return Shift{}, false
+}
+
+// save将当前配置写回配置文件。
+func (s *Schedule) save() error {
+	if s.path == "" {
+		return nil
+	}
+	var cfg Config
+	for _, name := range s.order {
+		cfg.Services = append(cfg.Services, s.services[name].Service)
+	}
+	p, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("oncall: json encode config: %w", err)
+	}
+	if err = fileutil.WriteFile(s.path, append(p, '\n')); err != nil {
+		return fmt.Errorf("oncall: save config: %w", err)
+	}
+	return nil
+}
+
+// rotation返回t所在轮值班次。
+func (svc *service) rotation(t time.Time) Shift {
+	lt := t.In(svc.loc)
+	start := svc.start
+	day := time.Date(lt.Year(), lt.Month(), lt.Day(), start.Hour(), start.Minute(), 0, 0, svc.loc)
+	if lt.Before(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	n := floorDiv(daysBetween(start, day), svc.period)
+	users := svc.Rotation.Users
+	shiftStart := start.AddDate(0, 0, n*svc.period)
+	return Shift{
+		User:  users[(n%len(users)+len(users))%len(users)],
+		Start: shiftStart,
+		End:   shiftStart.AddDate(0, 0, svc.period),
+	}
+}
+
+// at返回t时刻值班人及是否为临时替班。
+func (svc *service) at(t time.Time) (string, bool) {
+	for i := len(svc.Overrides) - 1; i >= 0; i-- {
+		o := svc.Overrides[i]
+		if !t.Before(o.Start) && t.Before(o.End) {
+			return o.User, true
+		}
+	}
+	return svc.rotation(t).User, false
+}
+
+func (svc *service) shifts(from, to time.Time) []Shift {
+	// 收集所有可能的交接点：轮值班次边界及替班起止时间。
+	first := svc.rotation(from)
+	points := []time.Time{from}
+	for t := first.End; t.Before(to); t = t.AddDate(0, 0, svc.period) {
+		points = append(points, t)
+	}
+	for _, o := range svc.Overrides {
+		for _, t := range [...]time.Time{o.Start, o.End} {
+			if t.After(from) && t.Before(to) {
+				points = append(points, t)
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Before(points[j]) })
+
+	var shifts []Shift
+	for i, t := range points {
+		end := to
+		if i+1 < len(points) {
+			end = points[i+1]
+		}
+		if !t.Before(end) {
+			continue
+		}
+		user, override := svc.at(t)
+		if n := len(shifts); n > 0 && shifts[n-1].User == user && shifts[n-1].Override == override {
+			shifts[n-1].End = end
+			continue
+		}
+		shifts = append(shifts, Shift{User: user, Start: t, End: end, Override: override})
+	}
+
+	// 补全第一个及最后一个班次的完整起止时间。
+	if len(shifts) > 0 {
+		if !shifts[0].Override && !svc.overrideBetween(first.Start, from) {
+			shifts[0].Start = first.Start
+		}
+		last := &shifts[len(shifts)-1]
+		if !last.Override {
+			r := svc.rotation(last.End.Add(-time.Nanosecond))
+			if r.User == last.User && !svc.overrideBetween(last.End, r.End) {
+				last.End = r.End
+			}
+		}
+	}
+	return shifts
+}
+
+// overrideBetween返回(a, b]期间是否有替班开始或结束。
+func (svc *service) overrideBetween(a, b time.Time) bool {
+	for _, o := range svc.Overrides {
+		for _, t := range [...]time.Time{o.Start, o.End} {
+			if t.After(a) && !t.After(b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func daysBetween(a, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/internal/fileutil"
+)
+
+// journal以json lines格式记录每条请求的状态变更，同一请求以最后一行为准。
+type journal struct {
+	path    string
+	fp      *os.File
+	appends int // 上次压缩后追加的行数
+}
+
+// openJournal读取path中记录的所有请求状态，并压缩文件：丢弃完成时间早于retain的记录。
+func openJournal(path string, retain time.Duration, now time.Time) (*journal, map[string]*State, error) {
+	states, err := replay(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	prune(states, retain, now)
+
+	j := &journal{path: path}
+	if err = j.compact(states); err != nil {
+		return nil, nil, err
+	}
+	return j, states, nil
+}
+
+// prune删除完成时间早于retain的请求。
+func prune(states map[string]*State, retain time.Duration, now time.Time) {
+	for id, st := range states {
+		if st.Status != StatusPending && now.Sub(st.Updated) > retain {
+			delete(states, id)
+		}
+	}
+}
+
+func replay(path string) (map[string]*State, error) {
+	states := make(map[string]*State)
+	fp, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, fmt.Errorf("outbox: journal: open: %w", err)
+	}
+	defer fp.Close()
+
+	sc := bufio.NewScanner(fp)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		st := new(State)
+		// 进程崩溃时最后一行可能不完整，跳过即可，该请求仍保留上一次的状态。
+		if json.Unmarshal(sc.Bytes(), st) != nil || st.Id == "" {
+			continue
+		}
+		states[st.Id] = st
+	}
+	if err = sc.Err(); err != nil {
+		return nil, fmt.Errorf("outbox: journal: read: %w", err)
+	}
+	return states, nil
+}
+
+// compact以states重写文件，每个请求只保留一行，之后的记录追加到新文件。
+func (j *journal) compact(states map[string]*State) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, st := range states {
+		if err := enc.Encode(st); err != nil {
+			return fmt.Errorf("outbox: journal: json encode request %v: %w", st.Id, err)
+		}
+	}
+	if err := fileutil.WriteFile(j.path, buf.Bytes()); err != nil {
+		return fmt.Errorf("outbox: journal: write compact file: %w", err)
+	}
+
+	fp, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("outbox: journal: open: %w", err)
+	}
+	if j.fp != nil {
+		j.fp.Close()
+	}
+	j.fp = fp
+	j.appends = 0
+	return nil
+}
+
+func (j *journal) write(st *State) error {
+	p, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("outbox: journal: json encode request %v: %w", st.Id, err)
+	}
+	p = append(p, '\n')
+	if _, err = j.fp.Write(p); err != nil {
+		return fmt.Errorf("outbox: journal: write request %v: %w", st.Id, err)
+	}
+	if err = j.fp.Sync(); err != nil {
+		return fmt.Errorf("outbox: journal: sync request %v: %w", st.Id, err)
+	}
+	j.appends++
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.fp.Close()
+}
@@ -0,0 +1,433 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Sender为Outbox发消息所用的接口，*client.Client实现了该接口。
+type Sender interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error)
+}
+
+// 消息接收方，单聊、群聊、团队可同时指定。
+type Target struct {
+	Users   []string             `json:"users,omitempty"`    // 单聊域账号列表
+	Groups  []string             `json:"groups,omitempty"`   // 群id列表
+	AtUsers []string             `json:"at_users,omitempty"` // 群消息@列表，如果需要@所有人，传["@all"]
+	Teams   []client.TeamChannel `json:"teams,omitempty"`    // 团队频道列表
+}
+
+func (t Target) empty() bool {
+	return len(t.Users) == 0 && len(t.Groups) == 0 && len(t.Teams) == 0
+}
+
+type Status string
+
+const (
+	StatusPending Status = "pending" // 等待发送或重试
+	StatusSent    Status = "sent"    // 所有接收方均已发送成功
+	StatusFailed  Status = "failed"  // 超过最大尝试次数，仍有接收方未发送成功
+)
+
+// 请求状态，即Outbox持久化到文件的内容。
+type State struct {
+	Id       string          `json:"id"`
+	Target   Target          `json:"target"`    // 原始接收方
+	MsgType  string          `json:"msgtype"`   // client.Message.Type()
+	MsgIndex string          `json:"msg_index"` // client.Message.Index()
+	Msg      json.RawMessage `json:"msg"`       // json格式消息内容
+	Created  time.Time       `json:"created"`
+
+	Status    Status    `json:"status"`
+	Remain    Target    `json:"remain"`     // 尚未发送成功的接收方
+	Attempts  int       `json:"attempts"`   // 已尝试次数
+	LastError string    `json:"last_error"` // 最后一次发送失败原因
+	NextTry   time.Time `json:"next_try"`   // 下次重试时间
+	Updated   time.Time `json:"updated"`
+
+	UserMsgIds  []client.UserMsgIdPair  `json:"user_msgids,omitempty"`  // 单聊消息id
+	GroupMsgIds []client.GroupMsgIdPair `json:"group_msgids,omitempty"` // 群聊消息id
+	TeamPosts   []client.TeamPost       `json:"team_posts,omitempty"`   // 团队帖子id
+}
+
+func (st *State) clone() *State {
+	cp := *st
+	cp.UserMsgIds = append([]client.UserMsgIdPair(nil), st.UserMsgIds...)
+	cp.GroupMsgIds = append([]client.GroupMsgIdPair(nil), st.GroupMsgIds...)
+	cp.TeamPosts = append([]client.TeamPost(nil), st.TeamPosts...)
+	return &cp
+}
+
+type Options struct {
+	// 最大尝试次数（包括第一次发送），默认为10。
+	MaxAttempts int
+
+	// 重试间隔，每次失败后翻倍，最长不超过MaxBackoff。
+	// 默认MinBackoff为1秒，MaxBackoff为5分钟。
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// 已完成（发送成功或失败）的请求，在文件中保留多久，保留期内可通过Outbox.Status查询。
+	// 在Open时及每追加1000条记录后清理，同时压缩文件。默认为24小时。
+	Retain time.Duration
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Outbox将发消息请求持久化到本地文件，并在后台发送，失败时按退避策略重试，保证至少一次送达。
+//
+// 进程重启后，通过Open重新打开同一文件，未发送完成的请求将继续发送。
+type Outbox struct {
+	sender Sender
+	opts   Options
+
+	mu           sync.Mutex
+	journal      *journal
+	states       map[string]*State
+	compactEvery int // 每追加多少条记录后清理并压缩文件
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Open打开（或新建）path文件作为发件箱，并启动后台发送。*Options可以为空（详见Options定义/默认值）。
+func Open(path string, sender Sender, opts *Options) (*Outbox, error) {
+	ob := &Outbox{
+		sender: sender,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if opts != nil {
+		ob.opts = *opts
+	}
+	if ob.opts.MaxAttempts <= 0 {
+		ob.opts.MaxAttempts = 10
+	}
+	if ob.opts.MinBackoff <= 0 {
+		ob.opts.MinBackoff = time.Second
+	}
+	if ob.opts.MaxBackoff <= 0 {
+		ob.opts.MaxBackoff = 5 * time.Minute
+	}
+	if ob.opts.Retain <= 0 {
+		ob.opts.Retain = 24 * time.Hour
+	}
+	if ob.opts.Now == nil {
+		ob.opts.Now = time.Now
+	}
+
+	j, states, err := openJournal(path, ob.opts.Retain, ob.opts.Now())
+	if err != nil {
+		return nil, err
+	}
+	ob.journal = j
+	ob.states = states
+	ob.compactEvery = 1000
+
+	ob.wg.Add(1)
+	go ob.loop()
+	return ob, nil
+}
+
+// Send将消息持久化到发件箱，返回请求id，可通过Status查询发送状态。
+//
+// Send返回nil error仅表示请求已写入文件，消息将在后台异步发送。
+func (ob *Outbox) Send(target Target, msg client.Message) (string, error) {
+	if target.empty() {
+		return "", errors.New("outbox: send: empty target")
+	}
+	raw, err := message.RawOf(msg)
+	if err != nil {
+		return "", fmt.Errorf("outbox: send: json encode message: %w", err)
+	}
+
+	now := ob.opts.Now()
+	st := &State{
+		Id:       newId(),
+		Target:   target,
+		MsgType:  raw.MsgType,
+		MsgIndex: raw.MsgIndex,
+		Msg:      raw.Content,
+		Created:  now,
+		Status:   StatusPending,
+		Remain:   target,
+		NextTry:  now,
+		Updated:  now,
+	}
+
+	ob.mu.Lock()
+	if ob.journal == nil {
+		ob.mu.Unlock()
+		return "", errors.New("outbox: send: closed")
+	}
+	err = ob.journal.write(st)
+	if err == nil {
+		ob.states[st.Id] = st
+		ob.compact()
+	}
+	ob.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	ob.notify()
+	return st.Id, nil
+}
+
+// Status查询请求状态，返回的*State为副本。
+func (ob *Outbox) Status(id string) (*State, bool) {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	st := ob.states[id]
+	if st == nil {
+		return nil, false
+	}
+	return st.clone(), true
+}
+
+// Pending返回尚未完成的请求数量。
+func (ob *Outbox) Pending() int {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	n := 0
+	for _, st := range ob.states {
+		if st.Status == StatusPending {
+			n++
+		}
+	}
+	return n
+}
+
+// Close停止后台发送并关闭文件。未发送完成的请求，在下次Open时继续发送。
+func (ob *Outbox) Close() error {
+	ob.mu.Lock()
+	if ob.journal == nil {
+		ob.mu.Unlock()
+		return nil
+	}
+	close(ob.done)
+	ob.mu.Unlock()
+
+	ob.wg.Wait()
+
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	err := ob.journal.close()
+	ob.journal = nil
+	return err
+}
+
+func (ob *Outbox) notify() {
+	select {
+	case ob.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (ob *Outbox) loop() {
+	defer ob.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ob.done:
+			return
+		case <-ob.wake:
+		case <-timer.C:
+		}
+
+		next := ob.deliverDue()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			timer.Reset(next.Sub(ob.opts.Now()))
+		}
+	}
+}
+
+// deliverDue发送所有到期请求，返回最近一次重试时间，没有待发送请求时返回零值。
+func (ob *Outbox) deliverDue() time.Time {
+	for {
+		select {
+		case <-ob.done:
+			return time.Time{}
+		default:
+		}
+
+		now := ob.opts.Now()
+		var due *State
+		var next time.Time
+
+		ob.mu.Lock()
+		for _, st := range ob.states {
+			if st.Status != StatusPending {
+				continue
+			}
+			if !st.NextTry.After(now) {
+				if due == nil || st.NextTry.Before(due.NextTry) {
+					due = st
+				}
+			} else if next.IsZero() || st.NextTry.Before(next) {
+				next = st.NextTry
+			}
+		}
+		if due != nil {
+			due = due.clone()
+		}
+		ob.mu.Unlock()
+
+		if due == nil {
+			return next
+		}
+
+		ob.deliver(due)
+
+		ob.mu.Lock()
+		if ob.journal != nil {
+			if err := ob.journal.write(due); err != nil && ob.opts.Errorf != nil {
+				ob.opts.Errorf("%v", err)
+			}
+		}
+		ob.states[due.Id] = due
+		ob.compact()
+		ob.mu.Unlock()
+	}
+}
+
+// compact每追加compactEvery条记录后，清理过期的已完成请求并压缩文件，
+// 避免长期运行时内存及文件无限增长。调用方需持有ob.mu。
+func (ob *Outbox) compact() {
+	if ob.journal == nil || ob.journal.appends < ob.compactEvery {
+		return
+	}
+	prune(ob.states, ob.opts.Retain, ob.opts.Now())
+	if err := ob.journal.compact(ob.states); err != nil {
+		ob.journal.appends = 0 // 再追加compactEvery条后重试
+		if ob.opts.Errorf != nil {
+			ob.opts.Errorf("%v", err)
+		}
+	}
+}
+
+// deliver尝试发送一次，并更新st状态。
+func (ob *Outbox) deliver(st *State) {
+	msg := message.NewRaw(st.MsgType, st.MsgIndex, st.Msg)
+	var remain Target
+	var errs []error
+
+	if len(st.Remain.Users) > 0 {
+		pairs, warn, err := ob.sender.SendMessageToUsers(st.Remain.Users, msg)
+		if err != nil {
+			remain.Users = st.Remain.Users
+			errs = append(errs, err)
+		} else {
+			st.UserMsgIds = append(st.UserMsgIds, pairs...)
+			if warn != nil {
+				remain.Users = warn.Fails
+				errs = append(errs, warn.Explains)
+			}
+		}
+	}
+
+	if len(st.Remain.Groups) > 0 {
+		pairs, warn, err := ob.sender.SendMessageToGroups(st.Remain.Groups, st.Target.AtUsers, msg)
+		if err != nil {
+			remain.Groups = st.Remain.Groups
+			errs = append(errs, err)
+		} else {
+			st.GroupMsgIds = append(st.GroupMsgIds, pairs...)
+			if warn != nil {
+				remain.Groups = warn.Fails
+				errs = append(errs, warn.Explains)
+			}
+		}
+	}
+	remain.AtUsers = st.Remain.AtUsers
+
+	if len(st.Remain.Teams) > 0 {
+		posts, warn, err := ob.sender.SendPostToTeams(st.Remain.Teams, msg)
+		if err != nil {
+			remain.Teams = st.Remain.Teams
+			errs = append(errs, err)
+		} else {
+			st.TeamPosts = append(st.TeamPosts, posts...)
+			if warn != nil {
+				remain.Teams = warn.Fails
+				errs = append(errs, warn.Explains)
+			}
+		}
+	}
+
+	now := ob.opts.Now()
+	st.Attempts++
+	st.Remain = remain
+	st.Updated = now
+
+	if remain.empty() {
+		st.Status = StatusSent
+		st.LastError = ""
+		st.NextTry = time.Time{}
+		return
+	}
+
+	err := errors.Join(errs...)
+	if err == nil {
+		// 接口返回了失败列表，但没有失败原因。
+		err = errors.New("unknown error")
+	}
+	st.LastError = err.Error()
+	if st.Attempts >= ob.opts.MaxAttempts {
+		st.Status = StatusFailed
+		st.NextTry = time.Time{}
+		if ob.opts.Errorf != nil {
+			ob.opts.Errorf("outbox: request %v failed after %v attempts: %v", st.Id, st.Attempts, err)
+		}
+		return
+	}
+
+	st.NextTry = now.Add(ob.backoff(st.Attempts))
+	if ob.opts.Errorf != nil {
+		ob.opts.Errorf("outbox: request %v attempt %v: %v, retry at %v",
+			st.Id, st.Attempts, err, st.NextTry.Format(time.RFC3339))
+	}
+}
+
+func (ob *Outbox) backoff(attempts int) time.Duration {
+	d := ob.opts.MinBackoff
+	for i := 1; i < attempts && d < ob.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > ob.opts.MaxBackoff {
+		d = ob.opts.MaxBackoff
+	}
+	return d
+}
+
+func newId() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
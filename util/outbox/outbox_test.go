@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type flakySender struct {
+	mu    sync.Mutex
+	fails int
+	sent  []string
+}
+
+func (s *flakySender) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails > 0 {
+		s.fails--
+		return nil, nil, errors.New("network unreachable")
+	}
+	var pairs []client.UserMsgIdPair
+	for _, user := range users {
+		s.sent = append(s.sent, user)
+		pairs = append(pairs, client.UserMsgIdPair{User: user, MsgId: "msg-" + user})
+	}
+	return pairs, nil, nil
+}
+
+func (s *flakySender) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	return nil, nil, errors.New("not implemented")
+}
+
+func (s *flakySender) SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+	return nil, nil, errors.New("not implemented")
+}
+
+func waitStatus(t *testing.T, ob *Outbox, id string, status Status) *State {
+	for i := 0; i < 200; i++ {
+		st, ok := ob.Status(id)
+		if ok && st.Status == status {
+			return st
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("request %v not %v", id, status)
+	return nil
+}
+
+func TestOutboxRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.journal")
+	sender := &flakySender{fails: 2}
+	ob, err := Open(path, sender, &Options{MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+
+	id, err := ob.Send(Target{Users: []string{"zhangsan"}}, message.NewText("hello"))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	st := waitStatus(t, ob, id, StatusSent)
+	if st.Attempts != 3 {
+		t.Fatalf("attempts: %v", st.Attempts)
+	}
+	if len(st.UserMsgIds) != 1 || st.UserMsgIds[0].MsgId != "msg-zhangsan" {
+		t.Fatalf("user msgids: %v", st.UserMsgIds)
+	}
+	ob.Close()
+
+	ob, err = Open(path, sender, nil)
+	if err != nil {
+		t.Fatalf("reopen outbox: %v", err)
+	}
+	defer ob.Close()
+	st, ok := ob.Status(id)
+	if !ok || st.Status != StatusSent {
+		t.Fatalf("reopen status: %v", st)
+	}
+}
+
+func TestOutboxResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.journal")
+	ob, err := Open(path, &flakySender{fails: 1 << 30}, &Options{MinBackoff: time.Hour})
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+	id, err := ob.Send(Target{Users: []string{"lisi"}}, message.NewText("hello"))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	st := waitStatus(t, ob, id, StatusPending)
+	for st.Attempts == 0 {
+		time.Sleep(time.Millisecond)
+		st, _ = ob.Status(id)
+	}
+	ob.Close()
+
+	sender := new(flakySender)
+	ob, err = Open(path, sender, &Options{Now: func() time.Time { return time.Now().Add(2 * time.Hour) }})
+	if err != nil {
+		t.Fatalf("reopen outbox: %v", err)
+	}
+	defer ob.Close()
+	waitStatus(t, ob, id, StatusSent)
+	if len(sender.sent) != 1 || sender.sent[0] != "lisi" {
+		t.Fatalf("sent after resume: %v", sender.sent)
+	}
+}
+
+func TestOutboxCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.journal")
+	var mu sync.Mutex
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	ob, err := Open(path, new(flakySender), &Options{Retain: time.Hour, Now: clock})
+	if err != nil {
+		t.Fatalf("open outbox: %v", err)
+	}
+	defer ob.Close()
+	ob.mu.Lock()
+	ob.compactEvery = 2
+	ob.mu.Unlock()
+
+	var old []string
+	for i := 0; i < 3; i++ {
+		id, err := ob.Send(Target{Users: []string{"zhangsan"}}, message.NewText("hello"))
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		waitStatus(t, ob, id, StatusSent)
+		old = append(old, id)
+	}
+
+	// 运行期间清理超过Retain的已完成请求，并压缩文件。
+	mu.Lock()
+	now = now.Add(2 * time.Hour)
+	mu.Unlock()
+	id, err := ob.Send(Target{Users: []string{"lisi"}}, message.NewText("hello"))
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitStatus(t, ob, id, StatusSent)
+
+	for _, id := range old {
+		if _, ok := ob.Status(id); ok {
+			t.Fatalf("expired request %v not pruned", id)
+		}
+	}
+	ob.mu.Lock()
+	states, err := replay(path)
+	ob.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[id] == nil {
+		t.Fatalf("journal not compacted: %v", states)
+	}
+}
@@ -0,0 +1,345 @@
+package progress
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Client为进度消息所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUser(user string, msg client.Message) (string, error)
+	SendMessageToGroup(groupId string, msg client.Message) (string, error)
+	SendPostToTeam(team client.TeamChannel, msg client.Message) (string, error)
+	ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyTeamPost(post client.ModifyTeamPostRequest, msg client.Message) error
+}
+
+// Target为进度消息接收方，User、Group、Team三选一。
+type Target struct {
+	User  string
+	Group string
+	Team  *client.TeamChannel
+}
+
+func ToUser(user string) Target             { return Target{User: user} }
+func ToGroup(group string) Target           { return Target{Group: group} }
+func ToTeam(team client.TeamChannel) Target { return Target{Team: &team} }
+
+// 步骤状态。
+const (
+	StepPending = "pending"
+	StepRunning = "running"
+	StepDone    = "done"
+	StepFailed  = "failed"
+)
+
+var stepIcons = map[string]string{
+	StepPending: "⬜",
+	StepRunning: "⏳",
+	StepDone:    "✅",
+	StepFailed:  "❌",
+}
+
+type step struct {
+	name  string
+	state string
+}
+
+type Options struct {
+	// 标题，如"部署 api-server"。
+	Title string
+
+	// 两次修改消息最短间隔，期间的更新合并为一次修改。默认为2秒。
+	Interval time.Duration
+
+	// 进度条宽度（字符数），默认为20。
+	Width int
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Progress为一条实时更新的进度消息：第一次发送正常推送，之后的更新均以WithoutPush修改原消息。
+// 所有方法可并发调用。
+type Progress struct {
+	cli    Client
+	target Target
+	opts   Options
+	start  time.Time
+
+	mu       sync.Mutex
+	msgid    string
+	steps    []step
+	done     int
+	total    int
+	status   string
+	finished bool
+	result   string
+
+	lastModify time.Time
+	timer      *time.Timer
+	seq        int // 已渲染的消息序号
+
+	sending sync.Mutex // 修改消息时持有，不持有mu
+	sent    int        // 已修改的消息序号，由sending保护
+}
+
+// Start发送初始进度消息。*Options可以为空（详见Options定义/默认值）。
+func Start(cli Client, target Target, opts *Options) (*Progress, error) {
+	p := &Progress{cli: cli, target: target}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Interval <= 0 {
+		p.opts.Interval = 2 * time.Second
+	}
+	if p.opts.Width <= 0 {
+		p.opts.Width = 20
+	}
+	if p.opts.Now == nil {
+		p.opts.Now = time.Now
+	}
+	p.start = p.opts.Now()
+	p.lastModify = p.start
+
+	msg := p.message()
+	var err error
+	switch {
+	case target.User != "":
+		p.msgid, err = cli.SendMessageToUser(target.User, msg)
+	case target.Group != "":
+		p.msgid, err = cli.SendMessageToGroup(target.Group, msg)
+	case target.Team != nil:
+		p.msgid, err = cli.SendPostToTeam(*target.Team, msg)
+	default:
+		err = errors.New("target is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("progress: send initial message: %w", err)
+	}
+	return p, nil
+}
+
+func (p *Progress) errorf(format string, args ...any) {
+	if p.opts.Errorf != nil {
+		p.opts.Errorf(format, args...)
+	}
+}
+
+// Steps设置步骤列表，所有步骤初始为StepPending。
+func (p *Progress) Steps(names ...string) {
+	p.update(func() {
+		p.steps = p.steps[:0]
+		for _, name := range names {
+			p.steps = append(p.steps, step{name: name, state: StepPending})
+		}
+	})
+}
+
+// SetStep设置步骤状态，步骤不存在时追加到列表末尾。
+func (p *Progress) SetStep(name, state string) {
+	p.update(func() {
+		for i := range p.steps {
+			if p.steps[i].name == name {
+				p.steps[i].state = state
+				return
+			}
+		}
+		p.steps = append(p.steps, step{name: name, state: state})
+	})
+}
+
+// Set设置进度done/total，total为0时不展示进度条。
+func (p *Progress) Set(done, total int) {
+	p.update(func() {
+		p.done, p.total = done, total
+	})
+}
+
+// Status设置当前状态描述，如"正在上传制品…"。
+func (p *Progress) Status(text string) {
+	p.update(func() {
+		p.status = text
+	})
+}
+
+// Success以成功状态结束，立即更新消息。之后的更新均被忽略。
+func (p *Progress) Success(text string) error {
+	return p.finish("✅ 成功："+text, false)
+}
+
+// Fail以失败状态结束，立即更新消息，正在执行的步骤标记为失败。之后的更新均被忽略。
+// err可以为nil，此时只展示"失败"。
+func (p *Progress) Fail(err error) error {
+	if err == nil {
+		return p.finish("❌ 失败", true)
+	}
+	return p.finish("❌ 失败："+err.Error(), true)
+}
+
+func (p *Progress) finish(result string, failed bool) error {
+	p.mu.Lock()
+	if p.finished {
+		p.mu.Unlock()
+		return nil
+	}
+	p.finished = true
+	if failed {
+		for i := range p.steps {
+			if p.steps[i].state == StepRunning {
+				p.steps[i].state = StepFailed
+			}
+		}
+	}
+	p.result = result
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	seq, msg := p.prepare()
+	p.mu.Unlock()
+	return p.modify(seq, msg)
+}
+
+// update应用f，并按Interval节流修改消息。
+func (p *Progress) update(f func()) {
+	p.mu.Lock()
+	if p.finished {
+		p.mu.Unlock()
+		return
+	}
+	f()
+
+	if p.timer != nil {
+		p.mu.Unlock()
+		return // 已有待执行的修改，合并
+	}
+	wait := p.opts.Interval - p.opts.Now().Sub(p.lastModify)
+	if wait > 0 {
+		p.timer = time.AfterFunc(wait, p.flush)
+		p.mu.Unlock()
+		return
+	}
+	seq, msg := p.prepare()
+	p.mu.Unlock()
+	if err := p.modify(seq, msg); err != nil {
+		p.errorf("%v", err)
+	}
+}
+
+// flush执行节流等待中的修改。
+func (p *Progress) flush() {
+	p.mu.Lock()
+	if p.timer == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.timer = nil
+	seq, msg := p.prepare()
+	p.mu.Unlock()
+	if err := p.modify(seq, msg); err != nil {
+		p.errorf("%v", err)
+	}
+}
+
+// prepare渲染当前进度并分配序号，调用方需持有p.mu。
+func (p *Progress) prepare() (int, client.Message) {
+	p.lastModify = p.opts.Now()
+	p.seq++
+	return p.seq, p.message()
+}
+
+// modify以第seq次渲染的msg修改消息，调用方不能持有p.mu。
+// 并发修改时，已修改为更新的内容后不再修改为旧内容。
+func (p *Progress) modify(seq int, msg client.Message) error {
+	p.sending.Lock()
+	defer p.sending.Unlock()
+	if seq < p.sent {
+		return nil
+	}
+	p.sent = seq
+	noPush := &client.ModifyOptions{WithoutPush: true}
+
+	var err error
+	switch {
+	case p.target.User != "":
+		err = p.cli.ModifyUserMessage(client.UserMsgIdPair{User: p.target.User, MsgId: p.msgid}, msg, noPush)
+	case p.target.Group != "":
+		err = p.cli.ModifyGroupMessage(client.GroupMsgIdPair{Group: p.target.Group, MsgId: p.msgid}, msg, noPush)
+	default:
+		err = p.cli.ModifyTeamPost(client.ModifyTeamPostRequest{
+			TeamId:    p.target.Team.TeamId,
+			ChannelId: p.target.Team.ChannelId,
+			PostId:    p.msgid,
+			Tags:      p.target.Team.Tags,
+		}, msg)
+	}
+	if err != nil {
+		return fmt.Errorf("progress: modify message %v: %w", p.msgid, err)
+	}
+	return nil
+}
+
+// message渲染当前进度，团队帖子为html，其它为文本消息。
+func (p *Progress) message() client.Message {
+	text := p.render()
+	if p.target.Team == nil {
+		return message.NewText(text)
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(&b, "<p>%v</p>", html.EscapeString(line))
+	}
+	return message.NewRichTextHTML(b.String())
+}
+
+func (p *Progress) render() string {
+	var lines []string
+	if p.opts.Title != "" {
+		lines = append(lines, p.opts.Title)
+	}
+	if p.total > 0 {
+		lines = append(lines, Bar(p.done, p.total, p.opts.Width))
+	}
+	for _, s := range p.steps {
+		lines = append(lines, stepIcons[s.state]+" "+s.name)
+	}
+	if p.status != "" && !p.finished {
+		lines = append(lines, "状态："+p.status)
+	}
+	if p.result != "" {
+		lines = append(lines, p.result)
+	}
+	elapsed := p.opts.Now().Sub(p.start).Round(time.Second)
+	lines = append(lines, "耗时："+elapsed.String())
+	return strings.Join(lines, "\n")
+}
+
+// Bar渲染文本进度条，如"[██████░░░░░░░░░░░░░░] 30% (3/10)"。
+func Bar(done, total, width int) string {
+	if total <= 0 {
+		return ""
+	}
+	if done < 0 {
+		done = 0
+	}
+	if done > total {
+		done = total
+	}
+	filled := done * width / total
+	return fmt.Sprintf("[%v%v] %v%% (%v/%v)",
+		strings.Repeat("█", filled), strings.Repeat("░", width-filled),
+		done*100/total, done, total)
+}
@@ -0,0 +1,127 @@
+package progress
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	sent     string
+	modified []string
+	noPush   bool
+
+	p      *Progress
+	locked bool // 修改消息时p.mu被持有
+}
+
+func (c *fakeClient) SendMessageToUser(user string, msg client.Message) (string, error) {
+	return "", errors.New("unexpected")
+}
+
+func (c *fakeClient) SendMessageToGroup(groupId string, msg client.Message) (string, error) {
+	c.sent = msg.(message.Text).Content
+	return "m1", nil
+}
+
+func (c *fakeClient) SendPostToTeam(team client.TeamChannel, msg client.Message) (string, error) {
+	return "", errors.New("unexpected")
+}
+
+func (c *fakeClient) ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	return errors.New("unexpected")
+}
+
+func (c *fakeClient) ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	if c.p != nil {
+		if c.p.mu.TryLock() {
+			c.p.mu.Unlock()
+		} else {
+			c.locked = true
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.modified = append(c.modified, msg.(message.Text).Content)
+	c.noPush = opt.WithoutPush
+	return nil
+}
+
+func (c *fakeClient) ModifyTeamPost(post client.ModifyTeamPostRequest, msg client.Message) error {
+	return errors.New("unexpected")
+}
+
+func TestProgress(t *testing.T) {
+	cli := new(fakeClient)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	p, err := Start(cli, ToGroup("g1"), &Options{Title: "部署 api", Interval: time.Hour, Now: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli.sent != "部署 api\n耗时：0s" {
+		t.Fatalf("initial: %q", cli.sent)
+	}
+
+	// Interval内的更新合并，不修改消息。
+	p.Steps("build", "test", "deploy")
+	p.SetStep("build", StepDone)
+	p.SetStep("test", StepRunning)
+	p.Set(1, 3)
+	if len(cli.modified) != 0 {
+		t.Fatalf("throttled updates modified: %v", cli.modified)
+	}
+
+	mu.Lock()
+	now = now.Add(83 * time.Second)
+	mu.Unlock()
+	p.Fail(errors.New("test failed"))
+	p.Success("ignored")
+
+	want := "部署 api\n[██████░░░░░░░░░░░░░░] 33% (1/3)\n✅ build\n❌ test\n⬜ deploy\n❌ 失败：test failed\n耗时：1m23s"
+	if len(cli.modified) != 1 || cli.modified[0] != want || !cli.noPush {
+		t.Fatalf("modified: %q", cli.modified)
+	}
+}
+
+func TestFailNil(t *testing.T) {
+	cli := new(fakeClient)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p, err := Start(cli, ToGroup("g1"), &Options{Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.p = p
+
+	p.Status("deploying")
+	if err = p.Fail(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(cli.modified) != 1 || cli.modified[0] != "❌ 失败\n耗时：0s" {
+		t.Fatalf("modified: %q", cli.modified)
+	}
+	if cli.locked {
+		t.Fatal("modify message while holding p.mu")
+	}
+}
+
+func TestBar(t *testing.T) {
+	if got := Bar(10, 10, 4); got != "[████] 100% (10/10)" {
+		t.Fatalf("bar: %v", got)
+	}
+	if !strings.HasPrefix(Bar(0, 10, 4), "[░░░░] 0%") {
+		t.Fatalf("bar: %v", Bar(0, 10, 4))
+	}
+}
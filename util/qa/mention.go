@@ -0,0 +1,110 @@
+package qa
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// Mention为问题中除机器人外的@对象。
+type Mention = webhook.Mention
+
+// propsGetter为获取机器人名称所用的接口，*client.Client实现了该接口。
+type propsGetter interface {
+	GetRobotProps() (*client.RobotProperties, error)
+}
+
+// robotName缓存机器人名称，过期后通过GetRobotProps重新获取，机器人改名后自动生效。
+type robotName struct {
+	cli propsGetter
+	ttl time.Duration
+
+	mu     sync.Mutex
+	name   string
+	expire time.Time
+}
+
+// get返回机器人名称。获取失败时返回上次获取的名称。
+func (rn *robotName) get() (string, error) {
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	if time.Now().Before(rn.expire) {
+		return rn.name, nil
+	}
+	props, err := rn.cli.GetRobotProps()
+	if err != nil {
+		// 稍后重试，避免每条消息都请求
+		rn.expire = time.Now().Add(time.Minute)
+		return rn.name, err
+	}
+	rn.name = props.Name
+	rn.expire = time.Now().Add(rn.ttl)
+	return rn.name, nil
+}
+
+func (r *replier) robotName() string {
+	if r.opts.RobotName != "" {
+		return r.opts.RobotName
+	}
+	name, err := r.name.get()
+	if err != nil {
+		r.errorf("qa: get robot props: %v", err)
+	}
+	return name
+}
+
+// mentions返回消息中除机器人外的@对象，并按需去掉问题中的"@机器人"。单聊原样返回。
+func (r *replier) mentions(m *webhook.InboundMessage) (question string, others []Mention) {
+	if m.Conversation.Type == ConversationSingle {
+		return m.Text, nil
+	}
+	var me string
+	if m.AtMe {
+		me = r.robotName()
+	}
+	for _, at := range m.Mentions {
+		if at.Type == webhook.AtUser && at.User != nil && me != "" && at.User.Name == me {
+			continue
+		}
+		others = append(others, at)
+	}
+	return r.trimAtMe(m.Text, me), others
+}
+
+// trimAtMe去掉question中所有"@机器人"，me为空表示没有@机器人。
+func (r *replier) trimAtMe(question, me string) string {
+	if !r.opts.TrimAtMe || me == "" {
+		return question
+	}
+	return strings.TrimSpace(stripMention(question, me))
+}
+
+// stripMention去掉text中的"@name"，"@name"之后须为空白、标点或结尾，避免误删"@name2"。
+func stripMention(text, name string) string {
+	at := "@" + name
+	var b strings.Builder
+	for {
+		i := strings.Index(text, at)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		rest := text[i+len(at):]
+		next, size := utf8.DecodeRuneInString(rest)
+		if size > 0 && (unicode.IsLetter(next) || unicode.IsDigit(next) || next == '_') {
+			b.WriteString(text[:i+len(at)])
+			text = rest
+			continue
+		}
+		b.WriteString(text[:i])
+		if size > 0 && unicode.IsSpace(next) {
+			rest = rest[size:]
+		}
+		text = rest
+	}
+}
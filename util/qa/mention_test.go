@@ -0,0 +1,128 @@
+package qa
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+type fakeProps struct {
+	name  string
+	calls int
+}
+
+func (f *fakeProps) GetRobotProps() (*client.RobotProperties, error) {
+	f.calls++
+	return &client.RobotProperties{Name: f.name}, nil
+}
+
+// mentionKeys将@对象格式化为"user:名称"、"tag:标签id"、"all"，便于比较。
+func mentionKeys(mentions []Mention) string {
+	var keys []string
+	for _, m := range mentions {
+		switch {
+		case m.Type == webhook.AtUser && m.User != nil:
+			keys = append(keys, "user:"+m.User.Name)
+		case m.Type == webhook.AtTag && m.Tag != nil:
+			keys = append(keys, "tag:"+m.Tag.Id)
+		default:
+			keys = append(keys, string(m.Type))
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestMentions(t *testing.T) {
+	props := &fakeProps{name: "机器人"}
+	var got *Question
+	r := &replier{
+		h:    func(q *Question) client.Message { got = q; return nil },
+		opts: &Options{TrimAtMe: true},
+		hist: newHistory(0),
+		name: &robotName{cli: props, ttl: time.Hour},
+	}
+
+	robot := webhook.User{Account: "robot", Name: "机器人"}
+	zhangsan := webhook.User{Account: "zhangsan", Name: "张三"}
+	group := func(text string, atMe bool, at ...webhook.GroupAtUser) {
+		r.OnReceiveGroupMessage(webhook.GroupMessageEvent{
+			GroupId: "g1", AtMe: atMe, At: at,
+			Message: webhook.Message{MsgType: "text", Text: text},
+		})
+	}
+	team := func(text string, atMe bool, at ...webhook.TeamsPostAt) {
+		r.OnCreateTeamsPost(webhook.TeamsPostEvent{
+			TeamId: "t1", ChannelId: "c1", PostId: "p1", Content: text, AtMe: atMe, At: at,
+		})
+	}
+
+	cases := []struct {
+		name     string
+		send     func()
+		text     string
+		mentions string
+	}{
+		{
+			name: "robot and user",
+			send: func() {
+				group("@机器人 @张三 帮我看下", true, webhook.GroupAtUser{User: robot}, webhook.GroupAtUser{User: zhangsan})
+			},
+			text:     "@张三 帮我看下",
+			mentions: "user:张三",
+		},
+		{
+			name: "at all",
+			send: func() {
+				group("@所有人 @机器人 发布了", true, webhook.GroupAtUser{IsAtAll: true}, webhook.GroupAtUser{User: robot})
+			},
+			text:     "@所有人 发布了",
+			mentions: "all",
+		},
+		{
+			name: "team tag",
+			send: func() {
+				team("@机器人 @后端 看下", true,
+					webhook.TeamsPostAt{Type: webhook.AtUser, User: &robot},
+					webhook.TeamsPostAt{Type: webhook.AtTag, Tag: &webhook.TeamsTag{Id: "backend", Name: "后端"}})
+			},
+			text:     "@后端 看下",
+			mentions: "tag:backend",
+		},
+		{
+			// 没有@机器人时不识别机器人，"@机器人"只是文本，原样保留。
+			name:     "not at me",
+			send:     func() { group("@张三 @机器人 在吗", false, webhook.GroupAtUser{User: zhangsan}) },
+			text:     "@张三 @机器人 在吗",
+			mentions: "user:张三",
+		},
+	}
+	for _, c := range cases {
+		got = nil
+		c.send()
+		if got == nil {
+			t.Fatalf("%v: handler not called", c.name)
+		}
+		if got.Text != c.text || mentionKeys(got.Mentions) != c.mentions {
+			t.Errorf("%v: text %q mentions %q, want %q %q", c.name, got.Text, mentionKeys(got.Mentions), c.text, c.mentions)
+		}
+	}
+	if props.calls != 1 {
+		t.Fatalf("robot name not cached: %v calls", props.calls)
+	}
+
+	// 机器人改名，缓存过期后使用新名称。
+	props.name = "小助手"
+	renamed := webhook.User{Account: "robot", Name: "小助手"}
+	group("@小助手 你好", true, webhook.GroupAtUser{User: renamed})
+	if got.Text != "@小助手 你好" || mentionKeys(got.Mentions) != "user:小助手" {
+		t.Fatalf("before refresh: %q %q", got.Text, mentionKeys(got.Mentions))
+	}
+	r.name.expire = time.Time{}
+	group("@小助手 你好", true, webhook.GroupAtUser{User: renamed})
+	if got.Text != "你好" || len(got.Mentions) != 0 || props.calls != 2 {
+		t.Fatalf("after refresh: %q %q, %v calls", got.Text, mentionKeys(got.Mentions), props.calls)
+	}
+}
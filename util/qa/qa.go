@@ -1,11 +1,11 @@
 package qa
 
 import (
-	"fmt"
-	"strings"
+	"time"
 
 	"github.com/eachain/360-tuitui-robot/client"
 	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/reply"
 	"github.com/eachain/360-tuitui-robot/webhook"
 )
 
@@ -30,40 +30,85 @@ type Options struct {
 	OnlyAtMe bool
 
 	// 去掉question中的"@机器人"，比如"@机器人 你好"，去掉"@机器人"后为"你好"。
-	// 需要RobotName参数提供机器人名称。
-	// 适用于群聊和团队帖子。单聊机器人跳过该条件判断。
+	// 仅在消息@机器人（event.AtMe）时生效，适用于群聊和团队帖子。单聊机器人跳过该条件判断。
 	TrimAtMe bool
 
-	// 机器人名称，用于去掉question中的"@机器人"部分。
+	// 机器人名称，用于识别@列表中的机器人及去掉question中的"@机器人"部分。
+	// 默认为空，表示通过GetRobotProps获取并缓存，机器人改名后自动生效。
 	RobotName string
 
+	// GetRobotProps获取的机器人名称缓存时间，默认为10分钟。
+	RobotNameTTL time.Duration
+
 	// 回复时是否@提问人，适用于群聊和团队帖子。单聊机器人跳过该条件判断。
 	AtQuestioner bool
 
 	// 回复时是否引用原消息，适用于单/群聊。团队帖子跳过该条件判断。
 	Reference bool
 
+	// 每个会话（单聊、群聊、团队帖子主题）保留最近多少条消息作为Question.History。
+	// 默认为0，表示不保留历史消息。
+	HistorySize int
+
+	// 流式回复（NewStream）时，先发送的占位消息内容，默认为"思考中…"。
+	Placeholder string
+
+	// 流式回复（NewStream）时，两次修改消息的最短间隔，期间收到的内容合并为一次修改。默认为1秒。
+	StreamInterval time.Duration
+
 	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
 	// 默认为nil，表示不输出日志。
 	Errorf func(string, ...any)
 }
 
 type replier struct {
-	qa   QA
+	h    Handler
 	cli  *client.Client
 	opts *Options
+	hist *history
+	name *robotName
+	rp   *reply.Replier
+}
+
+func newReplier(cli *client.Client, opts *Options) *replier {
+	ttl := opts.RobotNameTTL
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &replier{
+		cli:  cli,
+		opts: opts,
+		name: &robotName{cli: cli, ttl: ttl},
+		rp: reply.New(cli, &reply.Options{
+			AtSender:  opts.AtQuestioner,
+			Reference: opts.Reference,
+			RawHTML:   true, // 团队帖子中答案按html原样发送
+		}),
+	}
 }
 
 // 自动回复。
 func New(qa QA, cli *client.Client, opts *Options) Callback {
+	return NewHandler(func(q *Question) client.Message {
+		if q.Text == "" {
+			return nil
+		}
+		answer := qa(q.Text)
+		if answer == "" {
+			return nil
+		}
+		return message.NewText(answer)
+	}, cli, opts)
+}
+
+// 自动回复，Handler可获取提问人、会话、引用消息、图片/文件及历史消息，并以任意消息类型回复。
+func NewHandler(h Handler, cli *client.Client, opts *Options) Callback {
 	if opts == nil {
 		opts = new(Options)
 	}
-	r := &replier{
-		qa:   qa,
-		cli:  cli,
-		opts: opts,
-	}
+	r := newReplier(cli, opts)
+	r.h = h
+	r.hist = newHistory(opts.HistorySize)
 	return Callback{
 		OnReceiveSingleMessage: r.OnReceiveSingleMessage,
 		OnReceiveGroupMessage:  r.OnReceiveGroupMessage,
@@ -72,113 +117,74 @@ func New(qa QA, cli *client.Client, opts *Options) Callback {
 }
 
 func (r *replier) OnReceiveSingleMessage(event webhook.SingleMessageEvent) {
-	if event.Text == "" {
-		return
-	}
-
-	answer := r.qa(event.Text)
-	if answer == "" {
-		return
-	}
-
-	msg := message.NewText(answer)
-	if r.opts.Reference {
-		msg = msg.WithReference(event.MsgId)
-	}
-
-	_, err := r.cli.SendMessageToUser(event.User.Account, msg)
-	if err != nil && r.opts.Errorf != nil {
-		r.opts.Errorf("reply single message %v question %q answer %q: %v",
-			event.MsgId, event.Text, answer, err)
-	}
+	r.handle(webhook.FromSingleMessage(event))
 }
 
 func (r *replier) OnReceiveGroupMessage(event webhook.GroupMessageEvent) {
-	if event.Text == "" {
-		return
-	}
-
 	if r.opts.OnlyAtMe {
 		if !event.AtMe {
 			return
 		}
 	}
-
-	answer := r.qa(r.trimAtMe(event.Text))
-	if answer == "" {
-		return
-	}
-
-	msg := message.NewText(answer)
-	if r.opts.Reference {
-		msg = msg.WithReference(event.MsgId)
-	}
-
-	var err error
-	if r.opts.AtQuestioner {
-		_, err = r.cli.SendMessageToGroupAt(event.GroupId, []string{event.User.Account}, msg)
-	} else {
-		_, err = r.cli.SendMessageToGroup(event.GroupId, msg)
-	}
-	if err != nil && r.opts.Errorf != nil {
-		r.opts.Errorf("reply group message %v question %q answer %q: %v",
-			event.MsgId, event.Text, answer, err)
-	}
+	r.handle(webhook.FromGroupMessage(event))
 }
 
 func (r *replier) OnCreateTeamsPost(event webhook.TeamsPostEvent) {
-	if event.Content == "" {
-		return
-	}
-
 	if r.opts.OnlyAtMe {
 		if !event.AtMe {
 			return
 		}
 	}
+	r.handle(webhook.FromTeamsPost(event))
+}
 
-	answer := r.qa(r.trimAtMe(event.Content))
-	if answer == "" {
+func (r *replier) handle(m *webhook.InboundMessage) {
+	if m.Text == "" && len(m.Images) == 0 && len(m.Files) == 0 {
 		return
 	}
 
-	answer = strings.ReplaceAll(answer, "\n", "<br/>")
-
-	parent := event.PostId
-	if event.IsReply {
-		parent = event.ParentId
+	text, mentions := r.mentions(m)
+	q := &Question{
+		Text:         text,
+		Sender:       m.Sender,
+		Conversation: m.Conversation,
+		MsgId:        m.MsgId,
+		Time:         time.Unix(m.Timestamp, 0),
+		Mentions:     mentions,
+		Ref:          m.Ref,
+		Images:       m.Images,
+		Files:        m.Files,
+		Message:      m,
 	}
 
-	var msg client.Message
-	if r.opts.AtQuestioner {
-		msg = message.NewRichTextHTML(
-			fmt.Sprintf("{{tuitui_at %q}}%v", event.User.Account, answer),
-		).WithDelims("{{", "}}")
-	} else {
-		msg = message.NewRichTextHTML(answer)
+	answer := r.ask(q)
+	if answer == nil {
+		return
 	}
 
-	_, err := r.cli.SendPostToTeam(client.TeamChannel{
-		TeamId:    event.TeamId,
-		ChannelId: event.ChannelId,
-		ParentId:  parent,
-	}, msg)
-	if err != nil && r.opts.Errorf != nil {
-		r.opts.Errorf("reply team post %v question %q answer %q: %v",
-			event.PostId, event.Content, answer, err)
+	_, err := r.rp.Reply(m, answer)
+	if err != nil {
+		r.errorf("reply %v message %v question %q answer %v: %v",
+			m.Conversation.Type, m.MsgId, m.Text, answer.Type(), err)
 	}
 }
 
-func (r *replier) trimAtMe(question string) string {
-	if !r.opts.TrimAtMe {
-		return question
-	}
-	if r.opts.RobotName == "" {
-		return question
-	}
+// ask填充历史消息后调用Handler，并记录本轮问答。
+func (r *replier) ask(q *Question) client.Message {
+	key := q.Conversation.Key()
+	q.History = r.hist.get(key)
+
+	answer := r.h(q)
 
-	at := fmt.Sprintf("@%v ", r.opts.RobotName)
-	question = strings.ReplaceAll(question, at, "")
+	r.hist.add(key, Turn{User: q.Sender, Text: q.Text, Time: q.Time})
+	if answer != nil {
+		r.hist.add(key, Turn{IsMe: true, Text: textOf(answer), Time: time.Now()})
+	}
+	return answer
+}
 
-	return question
+func (r *replier) errorf(format string, args ...any) {
+	if r.opts.Errorf != nil {
+		r.opts.Errorf(format, args...)
+	}
 }
@@ -0,0 +1,104 @@
+package qa
+
+import (
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// Handler根据结构化问题回答，answer可以为任意消息类型（如message.Page、message.Mixed）。
+// 返回nil表示不回答。
+//
+// answer为message.Text时，按Options.Reference引用原消息；团队帖子中转为富文本，按Options.AtQuestioner@提问人。
+type Handler func(q *Question) (answer client.Message)
+
+// 会话类型。
+const (
+	ConversationSingle = webhook.ConversationSingle
+	ConversationGroup  = webhook.ConversationGroup
+	ConversationTeam   = webhook.ConversationTeam
+)
+
+// Conversation为问题所在会话。
+type Conversation = webhook.Conversation
+
+// Question为结构化问题。
+type Question struct {
+	Text         string       // 问题文本，已按Options.TrimAtMe去掉"@机器人"
+	Sender       webhook.User // 提问人
+	Conversation Conversation // 所在会话
+	MsgId        string       // 消息id，团队帖子为帖子id
+	Time         time.Time    // 提问时间
+
+	Mentions []Mention // 除机器人外被@的用户、标签及@所有人，仅群聊/团队帖子有效
+
+	Ref    *webhook.RefMsg  // 引用的消息，仅单/群聊有效
+	Images []*webhook.Image // 消息中的图片
+	Files  []*webhook.File  // 消息中的文件
+
+	// 本会话最近的消息（不含本条），按时间先后排列，条数见Options.HistorySize。
+	History []Turn
+
+	// 收到的原始消息。
+	Message *webhook.InboundMessage
+}
+
+// Turn为一条历史消息。
+type Turn struct {
+	User webhook.User // 发送者，IsMe为true时为空
+	IsMe bool         // 是否机器人的回答
+	Text string       // 文本内容，非文本/富文本回答为"[消息类型]"
+	Time time.Time
+}
+
+// textOf返回消息的文本内容，用于记录历史消息。
+func textOf(msg client.Message) string {
+	switch m := msg.(type) {
+	case message.Text:
+		return m.Content
+	case message.RichText:
+		if m.Markdown != "" {
+			return m.Markdown
+		}
+		return m.HTML
+	default:
+		return "[" + msg.Type() + "]"
+	}
+}
+
+// history按会话保存最近size条消息。
+type history struct {
+	size int
+
+	mu    sync.Mutex
+	turns map[string][]Turn
+}
+
+func newHistory(size int) *history {
+	return &history{size: size, turns: make(map[string][]Turn)}
+}
+
+func (h *history) get(key string) []Turn {
+	if h.size <= 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Turn(nil), h.turns[key]...)
+}
+
+func (h *history) add(key string, turn Turn) {
+	if h.size <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	turns := append(h.turns[key], turn)
+	if len(turns) > h.size {
+		turns = append(turns[:0:0], turns[len(turns)-h.size:]...)
+	}
+	h.turns[key] = turns
+}
@@ -0,0 +1,43 @@
+package qa
+
+import (
+	"testing"
+
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+func TestHistory(t *testing.T) {
+	h := newHistory(2)
+	h.add("a", Turn{Text: "1"})
+	h.add("a", Turn{Text: "2"})
+	h.add("a", Turn{IsMe: true, Text: textOf(message.NewMixed().WithText("3"))})
+	h.add("b", Turn{Text: "x"})
+
+	turns := h.get("a")
+	if len(turns) != 2 || turns[0].Text != "2" || turns[1].Text != "[mixed]" || !turns[1].IsMe {
+		t.Fatalf("history a: %+v", turns)
+	}
+	if turns := h.get("b"); len(turns) != 1 {
+		t.Fatalf("history b: %+v", turns)
+	}
+
+	if turns := newHistory(0).get("a"); turns != nil {
+		t.Fatalf("disabled history: %+v", turns)
+	}
+}
+
+func TestStripMention(t *testing.T) {
+	cases := []struct{ text, want string }{
+		{"@机器人 你好", "你好"},
+		{"你好 @机器人", "你好 "},
+		{"@张三 @机器人 帮我看下", "@张三 帮我看下"},
+		{"@机器人，在吗", "，在吗"},
+		{"@机器人2 你好", "@机器人2 你好"},
+		{"@机器人 a @机器人 b", "a b"},
+	}
+	for _, c := range cases {
+		if got := stripMention(c.text, "机器人"); got != c.want {
+			t.Errorf("stripMention(%q): got %q, want %q", c.text, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package qa
+
+import (
+	"strings"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// StreamQA为流式问答，答案分片（增量）通过channel返回，回答完毕后关闭channel。
+// 返回nil表示不回答。
+type StreamQA func(question string) (chunks <-chan string)
+
+// 流式回复时，未回答完的消息末尾追加的光标。
+const streamCursor = "▌"
+
+// 回答为空时，占位消息最终修改为该内容。
+const emptyAnswer = "（无回答）"
+
+type streamReplier struct {
+	replier
+	qa StreamQA
+}
+
+// 流式自动回复：立即发送占位消息，之后将收到的答案分片按StreamInterval节流修改原消息，
+// 最终修改为完整答案。适用于单聊、群聊及团队帖子。
+func NewStream(qa StreamQA, cli *client.Client, opts *Options) Callback {
+	if opts == nil {
+		opts = new(Options)
+	}
+	r := &streamReplier{
+		replier: *newReplier(cli, opts),
+		qa:      qa,
+	}
+	return Callback{
+		OnReceiveSingleMessage: r.OnReceiveSingleMessage,
+		OnReceiveGroupMessage:  r.OnReceiveGroupMessage,
+		OnCreateTeamsPost:      r.OnCreateTeamsPost,
+	}
+}
+
+func (r *streamReplier) OnReceiveSingleMessage(event webhook.SingleMessageEvent) {
+	r.handle(webhook.FromSingleMessage(event))
+}
+
+func (r *streamReplier) OnReceiveGroupMessage(event webhook.GroupMessageEvent) {
+	if r.opts.OnlyAtMe {
+		if !event.AtMe {
+			return
+		}
+	}
+	r.handle(webhook.FromGroupMessage(event))
+}
+
+func (r *streamReplier) OnCreateTeamsPost(event webhook.TeamsPostEvent) {
+	if r.opts.OnlyAtMe {
+		if !event.AtMe {
+			return
+		}
+	}
+	r.handle(webhook.FromTeamsPost(event))
+}
+
+// handle发送占位消息，之后以收到的答案修改该消息。群聊修改消息时不再@提问人，避免重复提醒。
+func (r *streamReplier) handle(m *webhook.InboundMessage) {
+	if m.Text == "" {
+		return
+	}
+
+	question, _ := r.mentions(m)
+	chunks := r.qa(question)
+	if chunks == nil {
+		return
+	}
+
+	replyId, err := r.rp.Reply(m, message.NewText(r.placeholder()))
+	if err != nil {
+		go drain(chunks)
+		r.errorf("reply %v message %v question %q placeholder: %v", m.Conversation.Type, m.MsgId, m.Text, err)
+		return
+	}
+
+	noPush := &client.ModifyOptions{WithoutPush: true}
+	answer := r.stream(chunks, func(partial string) error {
+		return r.rp.Modify(m, replyId, message.NewText(partial), noPush)
+	})
+	if err = r.rp.Modify(m, replyId, message.NewText(answer), noPush); err != nil {
+		r.errorf("reply %v message %v question %q answer %q: %v", m.Conversation.Type, m.MsgId, m.Text, answer, err)
+	}
+}
+
+// stream收集答案分片，按StreamInterval调用modify更新未完成的答案，返回完整答案。
+func (r *streamReplier) stream(chunks <-chan string, modify func(partial string) error) string {
+	interval := r.opts.StreamInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	answer := fold(chunks, ticker.C, func(partial string) {
+		if err := modify(partial + streamCursor); err != nil {
+			r.errorf("modify streaming answer: %v", err)
+		}
+	})
+	if answer == "" {
+		answer = emptyAnswer
+	}
+	return answer
+}
+
+func (r *streamReplier) placeholder() string {
+	if r.opts.Placeholder != "" {
+		return r.opts.Placeholder
+	}
+	return "思考中…"
+}
+
+// fold拼接chunks中的分片直到channel关闭，期间每次收到tick时以当前内容调用update（内容无变化时不调用）。
+func fold(chunks <-chan string, tick <-chan time.Time, update func(partial string)) string {
+	var b strings.Builder
+	dirty := false
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return b.String()
+			}
+			if chunk != "" {
+				b.WriteString(chunk)
+				dirty = true
+			}
+		case <-tick:
+			if dirty {
+				update(b.String())
+				dirty = false
+			}
+		}
+	}
+}
+
+// drain丢弃剩余分片，避免生产者阻塞导致goroutine泄漏。
+func drain(chunks <-chan string) {
+	for range chunks {
+	}
+}
@@ -0,0 +1,42 @@
+package qa
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFold(t *testing.T) {
+	chunks := make(chan string)
+	tick := make(chan time.Time)
+	partials := make(chan string, 10)
+	done := make(chan string)
+	go func() {
+		done <- fold(chunks, tick, func(partial string) { partials <- partial })
+	}()
+
+	// chunks及tick均无缓冲，发送返回时fold已处理完上一个事件。
+	chunks <- "你"
+	chunks <- "好"
+	tick <- time.Time{}
+	if got := <-partials; got != "你好" {
+		t.Fatalf("partial: %q", got)
+	}
+
+	// 内容无变化时不调用update。
+	tick <- time.Time{}
+	chunks <- "，世界"
+	select {
+	case got := <-partials:
+		t.Fatalf("duplicate update: %q", got)
+	default:
+	}
+
+	tick <- time.Time{}
+	if got := <-partials; got != "你好，世界" {
+		t.Fatalf("partial: %q", got)
+	}
+	close(chunks)
+	if answer := <-done; answer != "你好，世界" {
+		t.Fatalf("answer: %q", answer)
+	}
+}
@@ -0,0 +1,169 @@
+package quiet
+
+import (
+	"fmt"
+	"time"
+)
+
+// 免打扰期间非放行消息的处理方式。
+const (
+	// 暂存消息，免打扰结束后合并为一条摘要发送。
+	ModeDigest = "digest"
+
+	// 静默发送：免打扰期间第一条消息正常发送（会推送一次），
+	// 之后的消息以ModifyOptions.WithoutPush修改该消息追加内容，不再推送。
+	ModeSilent = "silent"
+)
+
+// 每天的免打扰时段，如{"start": "22:00", "end": "08:00"}，End早于Start表示跨天。
+type Window struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type Policy struct {
+	TimeZone string   `json:"timezone,omitempty"` // 如"Asia/Shanghai"，默认为"Local"
+	Windows  []Window `json:"windows,omitempty"`  // 每天的免打扰时段
+	Weekends bool     `json:"weekends,omitempty"` // 周六、周日全天免打扰
+	Holidays []string `json:"holidays,omitempty"` // 节假日全天免打扰，格式如"2024-10-01"
+
+	// 免打扰期间仍然放行的消息级别，默认为["critical"]。
+	Exempt []string `json:"exempt,omitempty"`
+
+	// ModeDigest或ModeSilent，默认为ModeDigest。
+	Mode string `json:"mode,omitempty"`
+
+	loc      *time.Location
+	windows  [][2]int // 分钟数
+	holidays map[string]bool
+}
+
+func (p *Policy) compile() error {
+	p.loc = time.Local
+	if p.TimeZone != "" {
+		loc, err := time.LoadLocation(p.TimeZone)
+		if err != nil {
+			return err
+		}
+		p.loc = loc
+	}
+
+	p.windows = p.windows[:0]
+	for _, w := range p.Windows {
+		start, err := minutes(w.Start)
+		if err != nil {
+			return fmt.Errorf("window start: %w", err)
+		}
+		end, err := minutes(w.End)
+		if err != nil {
+			return fmt.Errorf("window end: %w", err)
+		}
+		p.windows = append(p.windows, [2]int{start, end})
+	}
+
+	p.holidays = make(map[string]bool, len(p.Holidays))
+	for _, day := range p.Holidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return fmt.Errorf("holiday: %w", err)
+		}
+		p.holidays[day] = true
+	}
+
+	if p.Exempt == nil {
+		p.Exempt = []string{SeverityCritical}
+	}
+	switch p.Mode {
+	case "":
+		p.Mode = ModeDigest
+	case ModeDigest, ModeSilent:
+	default:
+		return fmt.Errorf("unknown mode %q", p.Mode)
+	}
+	return nil
+}
+
+func minutes(hm string) (int, error) {
+	t, err := time.Parse("15:04", hm)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Quiet判断t时刻是否处于免打扰期间。
+func (p *Policy) Quiet(t time.Time) bool {
+	t = t.In(p.loc)
+	if p.holidays[t.Format("2006-01-02")] {
+		return true
+	}
+	if p.Weekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	for _, w := range p.windows {
+		if w[0] <= w[1] {
+			if w[0] <= m && m < w[1] {
+				return true
+			}
+		} else if m >= w[0] || m < w[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Exempted判断该级别消息在免打扰期间是否放行。
+func (p *Policy) Exempted(severity string) bool {
+	for _, s := range p.Exempt {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
+
+// Config为免打扰配置，Users、Groups未配置的接收方使用Default，Default为空表示不免打扰。
+type Config struct {
+	Default *Policy            `json:"default,omitempty"`
+	Users   map[string]*Policy `json:"users,omitempty"`
+	Groups  map[string]*Policy `json:"groups,omitempty"`
+}
+
+func (c *Config) compile() error {
+	if c.Default != nil {
+		if err := c.Default.compile(); err != nil {
+			return fmt.Errorf("default policy: %w", err)
+		}
+	}
+	for user, p := range c.Users {
+		if p == nil {
+			continue
+		}
+		if err := p.compile(); err != nil {
+			return fmt.Errorf("user %v policy: %w", user, err)
+		}
+	}
+	for group, p := range c.Groups {
+		if p == nil {
+			continue
+		}
+		if err := p.compile(); err != nil {
+			return fmt.Errorf("group %v policy: %w", group, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) user(user string) *Policy {
+	if p := c.Users[user]; p != nil {
+		return p
+	}
+	return c.Default
+}
+
+func (c *Config) group(group string) *Policy {
+	if p := c.Groups[group]; p != nil {
+		return p
+	}
+	return c.Default
+}
@@ -0,0 +1,347 @@
+package quiet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/internal/strutil"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Sender为免打扰中间件所用的接口，*client.Client实现了该接口。
+type Sender interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+}
+
+type Options struct {
+	// 未用WithSeverity标记级别的消息，默认为SeverityInfo。
+	DefaultSeverity string
+
+	// 检查免打扰是否结束的间隔，默认为1分钟。
+	Interval time.Duration
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// held为免打扰期间某接收方暂存/静默发送的消息。
+type held struct {
+	user    string
+	group   string
+	atUsers []string
+	mode    string
+	items   []string
+
+	// ModeSilent下同一接收方的消息依次发送，msgid为第一条消息id，由sending保护。
+	sending sync.Mutex
+	msgid   string
+}
+
+// Client为免打扰中间件，发消息方法与*client.Client一致：
+// 接收方处于免打扰期间时，放行级别（默认为critical）消息照常发送，其它消息按策略暂存为摘要或静默发送。
+//
+// 暂存/静默发送的消息均转为文本，且没有消息id，返回值中不包含这些接收方，Warning.Fails也不包含。
+// 摘要仅保存在内存中，进程退出前需调用Close发送。
+type Client struct {
+	cli  Sender
+	cfg  Config
+	opts Options
+
+	mu   sync.Mutex
+	held map[string]*held
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New新建免打扰中间件，并启动后台检查免打扰结束。*Options可以为空（详见Options定义/默认值）。
+func New(cli Sender, cfg Config, opts *Options) (*Client, error) {
+	if err := cfg.compile(); err != nil {
+		return nil, fmt.Errorf("quiet: %w", err)
+	}
+	c := &Client{
+		cli:  cli,
+		cfg:  cfg,
+		held: make(map[string]*held),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if opts != nil {
+		c.opts = *opts
+	}
+	if c.opts.DefaultSeverity == "" {
+		c.opts.DefaultSeverity = SeverityInfo
+	}
+	if c.opts.Interval <= 0 {
+		c.opts.Interval = time.Minute
+	}
+	if c.opts.Now == nil {
+		c.opts.Now = time.Now
+	}
+	go c.loop()
+	return c, nil
+}
+
+func (c *Client) errorf(format string, args ...any) {
+	if c.opts.Errorf != nil {
+		c.opts.Errorf(format, args...)
+	}
+}
+
+func (c *Client) severity(msg client.Message) string {
+	if s := SeverityOf(msg); s != "" {
+		return s
+	}
+	return c.opts.DefaultSeverity
+}
+
+// 判断接收方当前是否需要拦截该消息。
+func (c *Client) intercept(p *Policy, severity string, now time.Time) bool {
+	return p != nil && p.Quiet(now) && !p.Exempted(severity)
+}
+
+// 批量发送单聊消息，免打扰期间的接收方按策略处理。
+func (c *Client) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	severity := c.severity(msg)
+	msg = Unwrap(msg)
+	now := c.opts.Now()
+
+	var pass []string
+	for _, user := range users {
+		p := c.cfg.user(user)
+		if !c.intercept(p, severity, now) {
+			pass = append(pass, user)
+			continue
+		}
+		c.hold("user:"+user, &held{user: user, mode: p.Mode}, msg)
+	}
+	if len(pass) == 0 {
+		return nil, nil, nil
+	}
+	return c.cli.SendMessageToUsers(pass, msg)
+}
+
+// 发送单聊消息。免打扰期间被拦截时，返回空消息id。
+func (c *Client) SendMessageToUser(user string, msg client.Message) (string, error) {
+	pairs, warn, err := c.SendMessageToUsers([]string{user}, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", nil
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 批量发送群聊消息，免打扰期间的群按策略处理。
+func (c *Client) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	severity := c.severity(msg)
+	msg = Unwrap(msg)
+	now := c.opts.Now()
+
+	var pass []string
+	for _, group := range groupIds {
+		p := c.cfg.group(group)
+		if !c.intercept(p, severity, now) {
+			pass = append(pass, group)
+			continue
+		}
+		c.hold("group:"+group, &held{group: group, atUsers: strutil.AppendUnique(nil, atUsers...), mode: p.Mode}, msg)
+	}
+	if len(pass) == 0 {
+		return nil, nil, nil
+	}
+	return c.cli.SendMessageToGroups(pass, atUsers, msg)
+}
+
+// 发送群聊消息并@atUsers。免打扰期间被拦截时，返回空消息id。
+func (c *Client) SendMessageToGroupAt(groupId string, atUsers []string, msg client.Message) (string, error) {
+	pairs, warn, err := c.SendMessageToGroups([]string{groupId}, atUsers, msg)
+	if err != nil {
+		return "", err
+	}
+	if len(pairs) == 0 {
+		if warn != nil {
+			return "", warn.Explains
+		}
+		return "", nil
+	}
+	return pairs[0].MsgId, nil
+}
+
+// 发送群聊消息。免打扰期间被拦截时，返回空消息id。
+func (c *Client) SendMessageToGroup(groupId string, msg client.Message) (string, error) {
+	return c.SendMessageToGroupAt(groupId, nil, msg)
+}
+
+func (c *Client) hold(key string, h *held, msg client.Message) {
+	c.mu.Lock()
+
+	if old := c.held[key]; old != nil {
+		old.atUsers = strutil.AppendUnique(old.atUsers, h.atUsers...)
+		h = old
+	} else {
+		c.held[key] = h
+	}
+	h.items = append(h.items, summarize(msg))
+	c.mu.Unlock()
+
+	if h.mode == ModeSilent {
+		c.silent(h)
+	}
+}
+
+// silent静默发送：第一条正常发送，之后修改该消息追加内容且不推送。发送时不持有c.mu。
+func (c *Client) silent(h *held) {
+	h.sending.Lock()
+	defer h.sending.Unlock()
+
+	c.mu.Lock()
+	text := message.NewText(combine("免打扰期间消息", h.items))
+	c.mu.Unlock()
+	noPush := &client.ModifyOptions{WithoutPush: true}
+
+	var err error
+	switch {
+	case h.user != "" && h.msgid == "":
+		var pairs []client.UserMsgIdPair
+		var warn *client.Warning[string]
+		pairs, warn, err = c.cli.SendMessageToUsers([]string{h.user}, text)
+		if err == nil && len(pairs) > 0 {
+			h.msgid = pairs[0].MsgId
+		} else if err == nil && warn != nil {
+			err = warn.Explains
+		}
+	case h.user != "":
+		err = c.cli.ModifyUserMessage(client.UserMsgIdPair{User: h.user, MsgId: h.msgid}, text, noPush)
+	case h.msgid == "":
+		var pairs []client.GroupMsgIdPair
+		var warn *client.Warning[string]
+		pairs, warn, err = c.cli.SendMessageToGroups([]string{h.group}, nil, text)
+		if err == nil && len(pairs) > 0 {
+			h.msgid = pairs[0].MsgId
+		} else if err == nil && warn != nil {
+			err = warn.Explains
+		}
+	default:
+		err = c.cli.ModifyGroupMessage(client.GroupMsgIdPair{Group: h.group, MsgId: h.msgid}, text, noPush)
+	}
+	if err != nil {
+		c.errorf("quiet: silent delivery to %v%v: %v", h.user, h.group, err)
+	}
+}
+
+// Flush检查所有暂存消息，免打扰已结束的接收方发送摘要。后台每Options.Interval自动调用一次。
+func (c *Client) Flush() {
+	c.flush(false)
+}
+
+func (c *Client) flush(all bool) {
+	now := c.opts.Now()
+
+	c.mu.Lock()
+	var ready []*held
+	for key, h := range c.held {
+		var p *Policy
+		if h.user != "" {
+			p = c.cfg.user(h.user)
+		} else {
+			p = c.cfg.group(h.group)
+		}
+		if !all && p != nil && p.Quiet(now) {
+			continue
+		}
+		delete(c.held, key)
+		if h.mode == ModeDigest {
+			ready = append(ready, h)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, h := range ready {
+		text := message.NewText(combine("免打扰期间共收到"+fmt.Sprint(len(h.items))+"条消息", h.items))
+		var err error
+		var warn *client.Warning[string]
+		if h.user != "" {
+			_, warn, err = c.cli.SendMessageToUsers([]string{h.user}, text)
+		} else {
+			_, warn, err = c.cli.SendMessageToGroups([]string{h.group}, h.atUsers, text)
+		}
+		if err == nil && warn != nil {
+			err = warn.Explains
+		}
+		if err != nil {
+			c.errorf("quiet: send digest to %v%v: %v", h.user, h.group, err)
+		}
+	}
+}
+
+func (c *Client) loop() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.Flush()
+		}
+	}
+}
+
+// Close停止后台检查，并立即发送所有暂存摘要，避免丢失。
+func (c *Client) Close() {
+	close(c.stop)
+	<-c.done
+	c.flush(true)
+}
+
+func combine(title string, items []string) string {
+	if len(items) == 1 {
+		return title + "：\n" + items[0]
+	}
+	var b strings.Builder
+	b.WriteString(title)
+	b.WriteString("：")
+	for i, item := range items {
+		fmt.Fprintf(&b, "\n%v. %v", i+1, item)
+	}
+	return b.String()
+}
+
+// summarize将消息转为一行摘要：文本消息取内容，其它消息取title/content等字段，都没有时为"[类型]"。
+func summarize(msg client.Message) string {
+	if text, ok := msg.(message.Text); ok {
+		return text.Content
+	}
+	var fields map[string]any
+	p, _ := json.Marshal(msg)
+	if json.Unmarshal(p, &fields) == nil {
+		keys := []string{"title", "content", "text", "summary"}
+		var parts []string
+		for _, key := range keys {
+			if s, ok := fields[key].(string); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, " ")
+		}
+	}
+	return "[" + msg.Type() + "]"
+}
@@ -0,0 +1,134 @@
+package quiet
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type fakeSender struct {
+	sent     []string
+	modified []string
+	noPush   bool
+
+	c      *Client
+	locked bool // 发送时c.mu被持有
+}
+
+func (s *fakeSender) checkLock() {
+	if s.c == nil {
+		return
+	}
+	if !s.c.mu.TryLock() {
+		s.locked = true
+		return
+	}
+	s.c.mu.Unlock()
+}
+
+func (s *fakeSender) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	s.checkLock()
+	var pairs []client.UserMsgIdPair
+	for _, user := range users {
+		s.sent = append(s.sent, user+": "+msg.(message.Text).Content)
+		pairs = append(pairs, client.UserMsgIdPair{User: user, MsgId: "m-" + user})
+	}
+	return pairs, nil, nil
+}
+
+func (s *fakeSender) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	s.checkLock()
+	var pairs []client.GroupMsgIdPair
+	for _, group := range groupIds {
+		s.sent = append(s.sent, group+": "+msg.(message.Text).Content)
+		pairs = append(pairs, client.GroupMsgIdPair{Group: group, MsgId: "m-" + group})
+	}
+	return pairs, nil, nil
+}
+
+func (s *fakeSender) ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	s.checkLock()
+	s.modified = append(s.modified, msgid.MsgId+": "+msg.(message.Text).Content)
+	s.noPush = opt != nil && opt.WithoutPush
+	return nil
+}
+
+func (s *fakeSender) ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	s.checkLock()
+	s.modified = append(s.modified, msgid.MsgId+": "+msg.(message.Text).Content)
+	s.noPush = opt != nil && opt.WithoutPush
+	return nil
+}
+
+func newClient(t *testing.T, cfg Config, now *time.Time) (*Client, *fakeSender) {
+	sender := new(fakeSender)
+	c, err := New(sender, cfg, &Options{
+		Interval: time.Hour,
+		Now:      func() time.Time { return *now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, sender
+}
+
+func TestDigest(t *testing.T) {
+	cfg := Config{Users: map[string]*Policy{
+		"zhangsan": {TimeZone: "UTC", Windows: []Window{{Start: "22:00", End: "08:00"}}},
+	}}
+	now := time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)
+	c, sender := newClient(t, cfg, &now)
+	defer c.Close()
+
+	c.SendMessageToUsers([]string{"zhangsan", "lisi"}, message.NewText("disk 80%"))
+	c.SendMessageToUser("zhangsan", WithSeverity(message.NewText("disk 90%"), SeverityWarning))
+	c.SendMessageToUser("zhangsan", WithSeverity(message.NewText("disk 100%"), SeverityCritical))
+	want := "lisi: disk 80%|zhangsan: disk 100%"
+	if got := strings.Join(sender.sent, "|"); got != want {
+		t.Fatalf("sent during quiet: %v, want %v", got, want)
+	}
+
+	c.Flush()
+	if len(sender.sent) != 2 {
+		t.Fatalf("flushed during quiet: %v", sender.sent)
+	}
+
+	now = time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)
+	c.Flush()
+	if len(sender.sent) != 3 || !strings.Contains(sender.sent[2], "共收到2条消息") {
+		t.Fatalf("digest: %v", sender.sent)
+	}
+}
+
+func TestSilent(t *testing.T) {
+	cfg := Config{Default: &Policy{TimeZone: "UTC", Weekends: true, Mode: ModeSilent}}
+	now := time.Date(2024, 1, 6, 12, 0, 0, 0, time.UTC) // 周六
+	c, sender := newClient(t, cfg, &now)
+	defer c.Close()
+	sender.c = c
+
+	c.SendMessageToGroup("g1", message.NewText("deploy started"))
+	c.SendMessageToGroup("g1", message.NewText("deploy finished"))
+	if len(sender.sent) != 1 || len(sender.modified) != 1 || !sender.noPush {
+		t.Fatalf("sent: %v, modified: %v, without push: %v", sender.sent, sender.modified, sender.noPush)
+	}
+	if !strings.Contains(sender.modified[0], "1. deploy started\n2. deploy finished") {
+		t.Fatalf("modified: %v", sender.modified[0])
+	}
+	if sender.locked {
+		t.Fatal("silent delivery holds c.mu")
+	}
+}
+
+func TestHoliday(t *testing.T) {
+	p := &Policy{TimeZone: "UTC", Holidays: []string{"2024-10-01"}}
+	if err := p.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Quiet(time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)) || p.Quiet(time.Date(2024, 10, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Fatal("holiday")
+	}
+}
@@ -0,0 +1,49 @@
+package quiet
+
+import (
+	"encoding/json"
+
+	"github.com/eachain/360-tuitui-robot/client"
+)
+
+// 常用消息级别，也可以使用自定义级别。
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// severityMessage为带级别的消息，发送时与原消息完全一致。
+type severityMessage struct {
+	client.Message
+	severity string
+}
+
+func (m severityMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Message)
+}
+
+// WithSeverity为消息标记级别，供免打扰策略判断是否放行。
+// 返回的消息可直接用于*client.Client发送，内容与msg一致。
+func WithSeverity(msg client.Message, severity string) client.Message {
+	if m, ok := msg.(severityMessage); ok {
+		msg = m.Message
+	}
+	return severityMessage{Message: msg, severity: severity}
+}
+
+// SeverityOf返回消息级别，未标记时返回空字符串。
+func SeverityOf(msg client.Message) string {
+	if m, ok := msg.(severityMessage); ok {
+		return m.severity
+	}
+	return ""
+}
+
+// Unwrap返回WithSeverity包装前的原消息。
+func Unwrap(msg client.Message) client.Message {
+	if m, ok := msg.(severityMessage); ok {
+		return m.Message
+	}
+	return msg
+}
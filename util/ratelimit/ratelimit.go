@@ -0,0 +1,291 @@
+package ratelimit
+
+import (
+	"html"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// Sender为回复"请求太频繁"所用的接口，*client.Client实现了该接口。
+type Sender interface {
+	SendMessageToUser(user string, msg client.Message) (string, error)
+	SendMessageToGroupAt(groupId string, atUsers []string, msg client.Message) (string, error)
+	SendPostToTeam(team client.TeamChannel, msg client.Message) (string, error)
+}
+
+// 限流范围。
+const (
+	ScopeUser   = "user"   // 每个用户
+	ScopeGroup  = "group"  // 每个群或团队频道
+	ScopeGlobal = "global" // 全局
+)
+
+// Limit为令牌桶参数：每秒补充Rate个令牌，最多积攒Burst个。Rate为0表示不限流。
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Every返回每interval允许n次的Limit，允许突发n次。
+func Every(interval time.Duration, n int) Limit {
+	return Limit{Rate: float64(n) / interval.Seconds(), Burst: n}
+}
+
+type Options struct {
+	// 每个用户的限流，默认为每分钟20次。
+	User *Limit
+
+	// 每个群或团队频道的限流，默认为每分钟60次。
+	Group *Limit
+
+	// 全局限流，默认不限流。
+	Global *Limit
+
+	// 被限流时的回复，每个用户（群限流时为每个群）从被限流到恢复期间只回复一次。默认为"请求太频繁，请稍后再试"。
+	// 全局限流时不回复，避免过载时向每个用户各回复一次。
+	Reply string
+
+	// Metrics用于上报计数（如对接prometheus），event为回调名称，如"OnReceiveGroupMessage"；
+	// limited为限流范围（ScopeUser, ScopeGroup, ScopeGlobal），未被限流时为空。默认为nil。
+	Metrics func(event, limited string)
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill按limit补充令牌后，返回是否有可用令牌（不扣减）。
+func (b *bucket) refill(limit Limit, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(limit.Burst)
+	} else if d := now.Sub(b.last).Seconds(); d > 0 {
+		b.tokens += d * limit.Rate
+		if b.tokens > float64(limit.Burst) {
+			b.tokens = float64(limit.Burst)
+		}
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+// Limiter按用户、群及全局令牌桶限流收到的消息。
+type Limiter struct {
+	cli  Sender
+	opts Options
+
+	mu        sync.Mutex
+	users     map[string]*bucket
+	groups    map[string]*bucket
+	global    bucket
+	notified  map[string]bool
+	lastSweep time.Time
+}
+
+// New新建Limiter，*Options可以为空（详见Options定义/默认值）。cli为nil时被限流不回复。
+func New(cli Sender, opts *Options) *Limiter {
+	l := &Limiter{
+		cli:      cli,
+		users:    make(map[string]*bucket),
+		groups:   make(map[string]*bucket),
+		notified: make(map[string]bool),
+	}
+	if opts != nil {
+		l.opts = *opts
+	}
+	if l.opts.User == nil {
+		limit := Every(time.Minute, 20)
+		l.opts.User = &limit
+	}
+	if l.opts.Group == nil {
+		limit := Every(time.Minute, 60)
+		l.opts.Group = &limit
+	}
+	if l.opts.Global == nil {
+		l.opts.Global = new(Limit)
+	}
+	if l.opts.Reply == "" {
+		l.opts.Reply = "请求太频繁，请稍后再试"
+	}
+	if l.opts.Now == nil {
+		l.opts.Now = time.Now
+	}
+	return l
+}
+
+func (l *Limiter) errorf(format string, args ...any) {
+	if l.opts.Errorf != nil {
+		l.opts.Errorf(format, args...)
+	}
+}
+
+// Allow消耗user、group（可以为空）及全局令牌桶各一个令牌。
+// 返回空字符串表示允许；否则返回限流范围，且不消耗任何令牌。
+func (l *Limiter) Allow(user, group string) (limited string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.opts.Now()
+	l.sweep(now)
+
+	type check struct {
+		scope string
+		limit Limit
+		b     *bucket
+	}
+	var checks []check
+	if user != "" && l.opts.User.Rate > 0 {
+		checks = append(checks, check{ScopeUser, *l.opts.User, getBucket(l.users, user)})
+	}
+	if group != "" && l.opts.Group.Rate > 0 {
+		checks = append(checks, check{ScopeGroup, *l.opts.Group, getBucket(l.groups, group)})
+	}
+	if l.opts.Global.Rate > 0 {
+		checks = append(checks, check{ScopeGlobal, *l.opts.Global, &l.global})
+	}
+
+	for _, c := range checks {
+		if !c.b.refill(c.limit, now) {
+			return c.scope
+		}
+	}
+	for _, c := range checks {
+		c.b.tokens--
+	}
+	return ""
+}
+
+func getBucket(m map[string]*bucket, key string) *bucket {
+	b := m[key]
+	if b == nil {
+		b = new(bucket)
+		m[key] = b
+	}
+	return b
+}
+
+// sweep每分钟清理一次已补满的令牌桶，避免内存无限增长。调用方需持有l.mu。
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	clean := func(m map[string]*bucket, limit Limit) {
+		for key, b := range m {
+			if limit.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= float64(limit.Burst) {
+				delete(m, key)
+			}
+		}
+	}
+	clean(l.users, *l.opts.User)
+	clean(l.groups, *l.opts.Group)
+	// 令牌桶已补满的用户或群视为已恢复，再次被限流时重新回复
+	for key := range l.notified {
+		if l.users[key] == nil && l.groups[key] == nil {
+			delete(l.notified, key)
+		}
+	}
+}
+
+// limit判断事件是否被限流，并上报计数。被限流时返回是否需要回复：
+// 用户限流时每个用户只回复一次，群限流时每个群只回复一次，全局限流不回复。
+func (l *Limiter) limit(event, user, group string) (limited, reply bool) {
+	scope := l.Allow(user, group)
+	if l.opts.Metrics != nil {
+		l.opts.Metrics(event, scope)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if scope == "" {
+		delete(l.notified, user)
+		if group != "" {
+			delete(l.notified, group)
+		}
+		return false, false
+	}
+	// 群被限流时每个群只回复一次，避免群内每个用户各回复一次
+	key := user
+	if scope == ScopeGroup {
+		key = group
+	}
+	if scope == ScopeGlobal || l.notified[key] {
+		return true, false
+	}
+	l.notified[key] = true
+	return true, true
+}
+
+// Wrap返回限流后的cb：单聊、群聊消息及团队帖子被限流时不再调用cb，其它事件原样透传。
+func (l *Limiter) Wrap(cb webhook.Callback) webhook.Callback {
+	if next := cb.OnReceiveSingleMessage; next != nil {
+		cb.OnReceiveSingleMessage = func(event webhook.SingleMessageEvent) {
+			limited, reply := l.limit("OnReceiveSingleMessage", event.User.Account, "")
+			if !limited {
+				next(event)
+				return
+			}
+			if reply && l.cli != nil {
+				_, err := l.cli.SendMessageToUser(event.User.Account, message.NewText(l.opts.Reply).WithReference(event.MsgId))
+				if err != nil {
+					l.errorf("ratelimit: reply user %v: %v", event.User.Account, err)
+				}
+			}
+		}
+	}
+
+	if next := cb.OnReceiveGroupMessage; next != nil {
+		cb.OnReceiveGroupMessage = func(event webhook.GroupMessageEvent) {
+			limited, reply := l.limit("OnReceiveGroupMessage", event.User.Account, "group:"+event.GroupId)
+			if !limited {
+				next(event)
+				return
+			}
+			if reply && l.cli != nil {
+				_, err := l.cli.SendMessageToGroupAt(event.GroupId, []string{event.User.Account},
+					message.NewText(l.opts.Reply).WithReference(event.MsgId))
+				if err != nil {
+					l.errorf("ratelimit: reply group %v user %v: %v", event.GroupId, event.User.Account, err)
+				}
+			}
+		}
+	}
+
+	if next := cb.OnCreateTeamsPost; next != nil {
+		cb.OnCreateTeamsPost = func(event webhook.TeamsPostEvent) {
+			limited, reply := l.limit("OnCreateTeamsPost", event.User.Account, "team:"+event.TeamId+":"+event.ChannelId)
+			if !limited {
+				next(event)
+				return
+			}
+			if reply && l.cli != nil {
+				parent := event.PostId
+				if event.IsReply {
+					parent = event.ParentId
+				}
+				_, err := l.cli.SendPostToTeam(client.TeamChannel{
+					TeamId:    event.TeamId,
+					ChannelId: event.ChannelId,
+					ParentId:  parent,
+				}, message.NewRichTextHTML(html.EscapeString(l.opts.Reply)))
+				if err != nil {
+					l.errorf("ratelimit: reply team %v channel %v user %v: %v",
+						event.TeamId, event.ChannelId, event.User.Account, err)
+				}
+			}
+		}
+	}
+
+	return cb
+}
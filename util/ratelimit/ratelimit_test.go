@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+type fakeSender struct {
+	replies []string
+}
+
+func (fs *fakeSender) SendMessageToUser(user string, msg client.Message) (string, error) {
+	fs.replies = append(fs.replies, "user:"+user)
+	return "1", nil
+}
+
+func (fs *fakeSender) SendMessageToGroupAt(groupId string, atUsers []string, msg client.Message) (string, error) {
+	fs.replies = append(fs.replies, "group:"+groupId)
+	return "1", nil
+}
+
+func (fs *fakeSender) SendPostToTeam(team client.TeamChannel, msg client.Message) (string, error) {
+	fs.replies = append(fs.replies, "team:"+team.TeamId)
+	return "1", nil
+}
+
+func TestAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	global := Every(time.Second, 5)
+	l := New(nil, &Options{
+		User:   &Limit{Rate: 1, Burst: 2},
+		Global: &global,
+		Now:    func() time.Time { return now },
+	})
+
+	if l.Allow("a", "") != "" || l.Allow("a", "") != "" {
+		t.Fatal("burst not allowed")
+	}
+	if got := l.Allow("a", ""); got != ScopeUser {
+		t.Fatalf("expected user limited, got %q", got)
+	}
+	for i := 0; i < 3; i++ {
+		if got := l.Allow("b", ""); i < 2 && got != "" || i == 2 && got != ScopeUser {
+			t.Fatalf("b #%v: %q", i, got)
+		}
+	}
+	if got := l.Allow("c", ""); got != "" {
+		t.Fatalf("c: %q", got)
+	}
+	if got := l.Allow("d", ""); got != ScopeGlobal {
+		t.Fatalf("expected global limited, got %q", got)
+	}
+
+	now = now.Add(time.Second)
+	if got := l.Allow("a", ""); got != "" {
+		t.Fatalf("after refill: %q", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fs := new(fakeSender)
+	metrics := make(map[string]int)
+	l := New(fs, &Options{
+		User:    &Limit{Rate: 1, Burst: 1},
+		Now:     func() time.Time { return now },
+		Metrics: func(event, limited string) { metrics[event+"/"+limited]++ },
+	})
+
+	handled := 0
+	cb := l.Wrap(webhook.Callback{
+		OnReceiveSingleMessage: func(webhook.SingleMessageEvent) { handled++ },
+	})
+	if cb.OnReceiveGroupMessage != nil {
+		t.Fatal("unregistered callback wrapped")
+	}
+
+	event := webhook.SingleMessageEvent{User: webhook.User{Account: "a"}}
+	for i := 0; i < 4; i++ {
+		cb.OnReceiveSingleMessage(event)
+	}
+	if handled != 1 || len(fs.replies) != 1 {
+		t.Fatalf("handled %v, replies %v", handled, fs.replies)
+	}
+
+	now = now.Add(time.Second)
+	cb.OnReceiveSingleMessage(event)
+	cb.OnReceiveSingleMessage(event)
+	if handled != 2 || len(fs.replies) != 2 {
+		t.Fatalf("after refill: handled %v, replies %v", handled, fs.replies)
+	}
+	if metrics["OnReceiveSingleMessage/"] != 2 || metrics["OnReceiveSingleMessage/user"] != 4 {
+		t.Fatalf("metrics: %v", metrics)
+	}
+}
+
+func TestGlobalNoReply(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fs := new(fakeSender)
+	l := New(fs, &Options{
+		User:   &Limit{Rate: 1, Burst: 1},
+		Global: &Limit{Rate: 1, Burst: 1},
+		Now:    func() time.Time { return now },
+	})
+	cb := l.Wrap(webhook.Callback{
+		OnReceiveSingleMessage: func(webhook.SingleMessageEvent) {},
+	})
+
+	for _, user := range []string{"a", "b", "c", "d"} {
+		cb.OnReceiveSingleMessage(webhook.SingleMessageEvent{User: webhook.User{Account: user}})
+	}
+	if len(fs.replies) != 0 {
+		t.Fatalf("global limited replies: %v", fs.replies)
+	}
+}
+
+func TestSweepNotified(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fs := new(fakeSender)
+	l := New(fs, &Options{
+		User: &Limit{Rate: 1, Burst: 1},
+		Now:  func() time.Time { return now },
+	})
+	cb := l.Wrap(webhook.Callback{
+		OnReceiveSingleMessage: func(webhook.SingleMessageEvent) {},
+	})
+
+	a := webhook.SingleMessageEvent{User: webhook.User{Account: "a"}}
+	cb.OnReceiveSingleMessage(a)
+	cb.OnReceiveSingleMessage(a)
+	if len(fs.replies) != 1 || !l.notified["a"] {
+		t.Fatalf("replies %v, notified %v", fs.replies, l.notified)
+	}
+
+	// a不再发消息，令牌桶补满后清理。
+	now = now.Add(2 * time.Minute)
+	cb.OnReceiveSingleMessage(webhook.SingleMessageEvent{User: webhook.User{Account: "b"}})
+	if len(l.notified) != 0 {
+		t.Fatalf("notified not swept: %v", l.notified)
+	}
+}
+
+func TestGroupReplyOnce(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fs := new(fakeSender)
+	l := New(fs, &Options{
+		Group: &Limit{Rate: 1, Burst: 1},
+		Now:   func() time.Time { return now },
+	})
+	cb := l.Wrap(webhook.Callback{
+		OnReceiveGroupMessage: func(webhook.GroupMessageEvent) {},
+	})
+
+	post := func(user string) {
+		cb.OnReceiveGroupMessage(webhook.GroupMessageEvent{User: webhook.User{Account: user}, GroupId: "g1"})
+	}
+	post("a")
+	post("a")
+	post("b")
+	post("b")
+	if len(fs.replies) != 1 || fs.replies[0] != "group:g1" {
+		t.Fatalf("replies: %v", fs.replies)
+	}
+
+	// 群恢复后再次被限流，重新回复一次。
+	now = now.Add(2 * time.Minute)
+	post("c")
+	post("c")
+	post("a")
+	if len(fs.replies) != 2 {
+		t.Fatalf("after recovery: %v", fs.replies)
+	}
+}
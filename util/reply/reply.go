@@ -0,0 +1,188 @@
+package reply
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/markdown"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+// Client为回复所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUser(user string, msg client.Message) (string, error)
+	SendMessageToGroupAt(groupId string, atUsers []string, msg client.Message) (string, error)
+	SendPostToTeam(team client.TeamChannel, msg client.Message) (string, error)
+	ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error
+	ModifyTeamPost(post client.ModifyTeamPostRequest, msg client.Message) error
+}
+
+type Options struct {
+	// 回复时是否@消息发送者，适用于群聊和团队帖子。单聊跳过该条件判断。
+	AtSender bool
+
+	// 回复时是否引用原消息，仅对单/群聊的文本消息（message.Text）有效。
+	Reference bool
+
+	// 团队帖子中message.Text是否按html原样使用（只将换行转为<br/>）。
+	// 默认为false，即转义html特殊字符；文本来自可信来源且含html时可设为true。
+	RawHTML bool
+}
+
+// Replier根据收到的事件回复到原会话：单聊回复发送者，群聊回复到群，团队帖子回复到主帖下。
+//
+// 团队帖子不支持文本消息，message.Text将转为富文本（转义html，换行转为<br/>，见Options.RawHTML）。
+// AtSender时在富文本开头@发送者。
+type Replier struct {
+	cli  Client
+	opts Options
+}
+
+// New新建Replier，*Options可以为空（详见Options定义/默认值）。
+func New(cli Client, opts *Options) *Replier {
+	r := &Replier{cli: cli}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r
+}
+
+// Reply回复event，event可以为webhook.SingleMessageEvent、webhook.GroupMessageEvent、webhook.TeamsPostEvent、
+// webhook.InboundMessage或其指针。返回回复的消息id，团队帖子为帖子id。
+func (r *Replier) Reply(event any, msg client.Message) (string, error) {
+	switch e := event.(type) {
+	case webhook.InboundMessage:
+		return r.Reply(e.Event, msg)
+	case *webhook.InboundMessage:
+		return r.Reply(e.Event, msg)
+	case webhook.SingleMessageEvent:
+		return r.ReplySingle(e, msg)
+	case *webhook.SingleMessageEvent:
+		return r.ReplySingle(*e, msg)
+	case webhook.GroupMessageEvent:
+		return r.ReplyGroup(e, msg)
+	case *webhook.GroupMessageEvent:
+		return r.ReplyGroup(*e, msg)
+	case webhook.TeamsPostEvent:
+		return r.ReplyTeam(e, msg)
+	case *webhook.TeamsPostEvent:
+		return r.ReplyTeam(*e, msg)
+	default:
+		return "", fmt.Errorf("reply: unsupported event type %T", event)
+	}
+}
+
+// ReplyText以文本回复event，详见Reply。
+func (r *Replier) ReplyText(event any, text string) (string, error) {
+	return r.Reply(event, message.NewText(text))
+}
+
+// ReplySingle回复单聊消息。
+func (r *Replier) ReplySingle(event webhook.SingleMessageEvent, msg client.Message) (string, error) {
+	return r.cli.SendMessageToUser(event.User.Account, r.reference(msg, event.MsgId))
+}
+
+// ReplyGroup回复群聊消息。
+func (r *Replier) ReplyGroup(event webhook.GroupMessageEvent, msg client.Message) (string, error) {
+	var atUsers []string
+	if r.opts.AtSender {
+		atUsers = []string{event.User.Account}
+	}
+	return r.cli.SendMessageToGroupAt(event.GroupId, atUsers, r.reference(msg, event.MsgId))
+}
+
+// ReplyTeam在帖子所在主帖下回帖，返回回帖id。
+func (r *Replier) ReplyTeam(event webhook.TeamsPostEvent, msg client.Message) (string, error) {
+	return r.cli.SendPostToTeam(Thread(event), r.teamMessage(event, msg))
+}
+
+// Modify修改之前回复event的消息msgid，与Reply的转换规则相同，但群聊不再@发送者。
+// opt仅对单/群聊有效。
+func (r *Replier) Modify(event any, msgid string, msg client.Message, opt *client.ModifyOptions) error {
+	switch e := event.(type) {
+	case webhook.InboundMessage:
+		return r.Modify(e.Event, msgid, msg, opt)
+	case *webhook.InboundMessage:
+		return r.Modify(e.Event, msgid, msg, opt)
+	case *webhook.SingleMessageEvent:
+		return r.Modify(*e, msgid, msg, opt)
+	case *webhook.GroupMessageEvent:
+		return r.Modify(*e, msgid, msg, opt)
+	case *webhook.TeamsPostEvent:
+		return r.Modify(*e, msgid, msg, opt)
+
+	case webhook.SingleMessageEvent:
+		pair := client.UserMsgIdPair{User: e.User.Account, MsgId: msgid}
+		return r.cli.ModifyUserMessage(pair, r.reference(msg, e.MsgId), opt)
+	case webhook.GroupMessageEvent:
+		pair := client.GroupMsgIdPair{Group: e.GroupId, MsgId: msgid}
+		return r.cli.ModifyGroupMessage(pair, r.reference(msg, e.MsgId), opt)
+	case webhook.TeamsPostEvent:
+		return r.cli.ModifyTeamPost(client.ModifyTeamPostRequest{
+			TeamId:    e.TeamId,
+			ChannelId: e.ChannelId,
+			PostId:    msgid,
+		}, r.teamMessage(e, msg))
+	default:
+		return fmt.Errorf("reply: unsupported event type %T", event)
+	}
+}
+
+func (r *Replier) reference(msg client.Message, msgid string) client.Message {
+	if text, ok := msg.(message.Text); ok && r.opts.Reference && text.Reference == "" {
+		return text.WithReference(msgid)
+	}
+	return msg
+}
+
+// teamMessage将文本转为富文本，并按需@发送者。
+func (r *Replier) teamMessage(event webhook.TeamsPostEvent, msg client.Message) client.Message {
+	rt, ok := msg.(message.RichText)
+	if text, isText := msg.(message.Text); isText {
+		if r.opts.RawHTML {
+			rt = message.NewRichTextHTML(strings.ReplaceAll(text.Content, "\n", "<br/>"))
+		} else {
+			rt = TextToHTML(text.Content)
+		}
+		ok = true
+	}
+	if !ok {
+		return msg
+	}
+	if !r.opts.AtSender || event.User.Account == "" {
+		return rt
+	}
+
+	if rt.DelimsLeft == "" || rt.DelimsRight == "" {
+		rt = rt.WithDelims(markdown.Delims(rt.HTML, rt.Markdown))
+	}
+	at := markdown.AtUser(event.User.Account).Template(rt.DelimsLeft, rt.DelimsRight)
+	if rt.Markdown != "" {
+		rt.Markdown = at + " " + rt.Markdown
+	} else {
+		rt.HTML = at + rt.HTML
+	}
+	return rt
+}
+
+// TextToHTML将纯文本转为html富文本：转义html特殊字符，换行转为<br/>。
+func TextToHTML(text string) message.RichText {
+	return message.NewRichTextHTML(strings.ReplaceAll(html.EscapeString(text), "\n", "<br/>"))
+}
+
+// Thread返回回复event所用的频道：回复主帖，而不是回复的回复。
+func Thread(event webhook.TeamsPostEvent) client.TeamChannel {
+	parent := event.PostId
+	if event.IsReply {
+		parent = event.ParentId
+	}
+	return client.TeamChannel{
+		TeamId:    event.TeamId,
+		ChannelId: event.ChannelId,
+		ParentId:  parent,
+	}
+}
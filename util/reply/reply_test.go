@@ -0,0 +1,104 @@
+package reply
+
+import (
+	"testing"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/webhook"
+)
+
+var _ Client = (*client.Client)(nil)
+
+type call struct {
+	to      string
+	atUsers []string
+	team    client.TeamChannel
+	msg     client.Message
+}
+
+type fakeClient struct {
+	calls []call
+}
+
+func (fc *fakeClient) SendMessageToUser(user string, msg client.Message) (string, error) {
+	fc.calls = append(fc.calls, call{to: user, msg: msg})
+	return "1", nil
+}
+
+func (fc *fakeClient) SendMessageToGroupAt(groupId string, atUsers []string, msg client.Message) (string, error) {
+	fc.calls = append(fc.calls, call{to: groupId, atUsers: atUsers, msg: msg})
+	return "2", nil
+}
+
+func (fc *fakeClient) SendPostToTeam(team client.TeamChannel, msg client.Message) (string, error) {
+	fc.calls = append(fc.calls, call{team: team, msg: msg})
+	return "3", nil
+}
+
+func (fc *fakeClient) ModifyUserMessage(msgid client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	fc.calls = append(fc.calls, call{to: msgid.User + "/" + msgid.MsgId, msg: msg})
+	return nil
+}
+
+func (fc *fakeClient) ModifyGroupMessage(msgid client.GroupMsgIdPair, msg client.Message, opt *client.ModifyOptions) error {
+	fc.calls = append(fc.calls, call{to: msgid.Group + "/" + msgid.MsgId, msg: msg})
+	return nil
+}
+
+func (fc *fakeClient) ModifyTeamPost(post client.ModifyTeamPostRequest, msg client.Message) error {
+	fc.calls = append(fc.calls, call{to: post.TeamId + "/" + post.PostId, msg: msg})
+	return nil
+}
+
+func TestReply(t *testing.T) {
+	fc := new(fakeClient)
+	r := New(fc, &Options{AtSender: true, Reference: true})
+	sender := webhook.User{Account: "zhangsan"}
+
+	single := webhook.SingleMessageEvent{User: sender, Message: webhook.Message{MsgId: "m1"}}
+	if _, err := r.ReplyText(&single, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if c := fc.calls[0]; c.to != "zhangsan" || c.msg.(message.Text).Reference != "m1" {
+		t.Fatalf("single: %+v", c)
+	}
+
+	group := webhook.GroupMessageEvent{User: sender, GroupId: "g1", Message: webhook.Message{MsgId: "m2"}}
+	r.Reply(group, message.NewMixed().WithText("hi"))
+	if c := fc.calls[1]; c.to != "g1" || len(c.atUsers) != 1 || c.atUsers[0] != "zhangsan" {
+		t.Fatalf("group: %+v", c)
+	}
+
+	post := webhook.TeamsPostEvent{User: sender, TeamId: "t1", ChannelId: "c1", IsReply: true, ParentId: "p0", PostId: "p1"}
+	r.ReplyText(post, "a<b\nc")
+	c := fc.calls[2]
+	if c.team.ParentId != "p0" {
+		t.Fatalf("team thread: %+v", c.team)
+	}
+	rt := c.msg.(message.RichText)
+	if rt.HTML != `{{tuitui_at "zhangsan"}}a&lt;b<br/>c` || rt.DelimsLeft != "{{" {
+		t.Fatalf("team message: %+v", rt)
+	}
+
+	if err := r.Modify(post, "p2", message.NewText("done"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if c := fc.calls[3]; c.to != "t1/p2" {
+		t.Fatalf("modify team: %+v", c)
+	}
+
+	New(fc, nil).Reply(webhook.FromTeamsPost(post), message.NewText("x"))
+	if rt, ok := fc.calls[4].msg.(message.RichText); !ok || rt.HTML != "x" {
+		t.Fatalf("team text without at: %#v", fc.calls[4].msg)
+	}
+
+	New(fc, &Options{RawHTML: true}).Reply(post, message.NewText("<b>a</b>\nc"))
+	if rt, ok := fc.calls[5].msg.(message.RichText); !ok || rt.HTML != "<b>a</b><br/>c" {
+		t.Fatalf("team raw html: %#v", fc.calls[5].msg)
+	}
+
+	if _, err := r.Reply(webhook.OpenSingleChatEvent{}, message.NewText("hi")); err == nil {
+		t.Fatal("expected error for unsupported event")
+	}
+}
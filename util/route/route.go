@@ -0,0 +1,246 @@
+package route
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Client为分发通知所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error)
+	SendSingleStrongNotice(touser, content string, opts ...client.StrongNoticeOption) error
+	SendVoiceToUsers(accounts []string, msg client.Message) ([]client.UserVoiceResult, error)
+}
+
+// Event为待通知事件。
+type Event struct {
+	// 匹配路由用的标签。Service、Severity不为空时，分别作为标签"service"、"severity"参与匹配。
+	Labels   map[string]string
+	Service  string
+	Severity string
+
+	// 文本内容，用于强通知及电话报警，Msg为空时也用于单聊、群及团队帖子。
+	// 团队帖子不支持文本消息，Text转为富文本（转义html，换行转为<br/>）。
+	Text string
+
+	// 单聊、群及团队帖子消息，为空时发送文本消息Text。
+	Msg client.Message
+}
+
+func (ev Event) labels() map[string]string {
+	labels := make(map[string]string, len(ev.Labels)+2)
+	for k, v := range ev.Labels {
+		labels[k] = v
+	}
+	if ev.Service != "" {
+		labels["service"] = ev.Service
+	}
+	if ev.Severity != "" {
+		labels["severity"] = ev.Severity
+	}
+	return labels
+}
+
+type Options struct {
+	// 测试模式，仅输出路由路径及将要发送的渠道、接收方，不实际发送。
+	DryRun bool
+
+	// 测试模式输出，默认为nil，表示通过Errorf输出。
+	Output io.Writer
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Router按路由树分发通知。
+type Router struct {
+	tree *Tree
+	cli  Client
+	opts Options
+}
+
+// NewRouter新建Router。*Options可以为空（详见Options定义/默认值）。
+func NewRouter(tree *Tree, cli Client, opts *Options) *Router {
+	r := &Router{tree: tree, cli: cli}
+	if opts != nil {
+		r.opts = *opts
+	}
+	return r
+}
+
+func (r *Router) errorf(format string, args ...any) {
+	if r.opts.Errorf != nil {
+		r.opts.Errorf(format, args...)
+	}
+}
+
+// Dispatch按路由结果分发事件，同一渠道同一接收方只发送一次。返回所有渠道的错误。
+func (r *Router) Dispatch(ev Event) error {
+	results := r.tree.Match(ev.labels())
+	if r.opts.DryRun {
+		if r.opts.Output != nil {
+			io.WriteString(r.opts.Output, explain(ev, results))
+		} else {
+			r.errorf("route: dry run %v", explain(ev, results))
+		}
+		return nil
+	}
+
+	msg, post := ev.Msg, ev.Msg
+	if msg == nil {
+		msg = message.NewText(ev.Text)
+		post = message.NewRichTextHTML(strings.ReplaceAll(html.EscapeString(ev.Text), "\n", "<br/>"))
+	}
+
+	var errs []error
+	sent := make(map[string]bool)
+	once := func(key string) bool {
+		if sent[key] {
+			return false
+		}
+		sent[key] = true
+		return true
+	}
+	report := func(path []string, ch string, err error) {
+		if err != nil {
+			err = fmt.Errorf("route %v: %v: %w", strings.Join(path, " > "), ch, err)
+			r.errorf("%v", err)
+			errs = append(errs, err)
+		}
+	}
+
+	for _, res := range results {
+		recv := res.Receiver
+		for _, ch := range recv.Channels {
+			switch ch {
+			case ChannelText:
+				users := filter(recv.Users, func(u string) bool { return once(ch + ":" + u) })
+				if len(users) > 0 {
+					_, warn, err := r.cli.SendMessageToUsers(users, msg)
+					if err == nil && warn != nil {
+						err = warn.Explains
+					}
+					report(res.Path, ch, err)
+				}
+			case ChannelStrongNotice:
+				opts := noticeOptions(recv.Notice)
+				for _, user := range recv.Users {
+					if once(ch + ":" + user) {
+						report(res.Path, ch, r.cli.SendSingleStrongNotice(user, ev.Text, opts...))
+					}
+				}
+			case ChannelVoice:
+				users := filter(recv.Users, func(u string) bool { return once(ch + ":" + u) })
+				if len(users) > 0 {
+					calls, err := r.cli.SendVoiceToUsers(users, message.NewVoice(ev.Text))
+					for _, vr := range calls {
+						if err == nil && !vr.Success {
+							err = fmt.Errorf("call %v: %v", vr.Mobile, vr.Error)
+						}
+					}
+					report(res.Path, ch, err)
+				}
+			case ChannelGroup:
+				groups := filter(recv.Groups, func(g string) bool { return once(ch + ":" + g) })
+				if len(groups) > 0 {
+					_, warn, err := r.cli.SendMessageToGroups(groups, recv.AtUsers, msg)
+					if err == nil && warn != nil {
+						err = warn.Explains
+					}
+					report(res.Path, ch, err)
+				}
+			case ChannelTeam:
+				var teams []client.TeamChannel
+				for _, team := range recv.Teams {
+					if once(ch + ":" + team.TeamId + "/" + team.ChannelId) {
+						teams = append(teams, team)
+					}
+				}
+				if len(teams) > 0 {
+					_, warn, err := r.cli.SendPostToTeams(teams, post)
+					if err == nil && warn != nil {
+						err = warn.Explains
+					}
+					report(res.Path, ch, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Explain返回事件的路由路径及将要发送的渠道、接收方，用于测试路由配置。
+func (t *Tree) Explain(ev Event) string {
+	return explain(ev, t.Match(ev.labels()))
+}
+
+func explain(ev Event, results []Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "event %v\n", formatLabels(ev.labels()))
+	for _, res := range results {
+		fmt.Fprintf(&b, "  route: %v\n", strings.Join(res.Path, " > "))
+		recv := res.Receiver
+		if len(recv.Channels) == 0 {
+			b.WriteString("    (no channels)\n")
+		}
+		for _, ch := range recv.Channels {
+			switch ch {
+			case ChannelText, ChannelVoice:
+				fmt.Fprintf(&b, "    %v -> users %v\n", ch, recv.Users)
+			case ChannelStrongNotice:
+				fmt.Fprintf(&b, "    %v -> users %v notice %v\n", ch, recv.Users, recv.Notice)
+			case ChannelGroup:
+				fmt.Fprintf(&b, "    %v -> groups %v at %v\n", ch, recv.Groups, recv.AtUsers)
+			case ChannelTeam:
+				fmt.Fprintf(&b, "    %v -> teams %v\n", ch, recv.Teams)
+			}
+		}
+	}
+	return b.String()
+}
+
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%v=%q", k, labels[k]))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func noticeOptions(notice []string) []client.StrongNoticeOption {
+	var opts []client.StrongNoticeOption
+	for _, n := range notice {
+		switch n {
+		case NoticeSMS:
+			opts = append(opts, client.WithSMSNotice())
+		case NoticeCall:
+			opts = append(opts, client.WithCallNotice())
+		}
+	}
+	return opts
+}
+
+func filter(s []string, keep func(string) bool) []string {
+	var r []string
+	for _, e := range s {
+		if keep(e) {
+			r = append(r, e)
+		}
+	}
+	return r
+}
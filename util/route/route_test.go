@@ -0,0 +1,157 @@
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+const config = `{
+  "channels": ["group"],
+  "groups": ["ops"],
+  "routes": [
+    {"name": "critical", "match": {"severity": "critical"}, "channels": ["voice", "group"], "users": ["oncall"], "continue": true},
+    {"name": "db", "match_re": {"service": "mysql|redis"}, "groups": ["dba"],
+     "routes": [{"name": "db-warning", "match": {"severity": "warning"}, "channels": ["strong_notice"], "users": ["dba-lead"], "notice": ["sms"]}]}
+  ]
+}`
+
+func tree(t *testing.T) *Tree {
+	root := new(Node)
+	if err := json.Unmarshal([]byte(config), root); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestMatch(t *testing.T) {
+	tr := tree(t)
+
+	cases := []struct {
+		labels map[string]string
+		paths  []string
+	}{
+		{map[string]string{"service": "web"}, []string{"root"}},
+		{map[string]string{"service": "redis", "severity": "critical"}, []string{"root > critical", "root > db"}},
+		{map[string]string{"service": "mysql", "severity": "warning"}, []string{"root > db > db-warning"}},
+	}
+	for _, c := range cases {
+		var paths []string
+		for _, res := range tr.Match(c.labels) {
+			paths = append(paths, strings.Join(res.Path, " > "))
+		}
+		if strings.Join(paths, "|") != strings.Join(c.paths, "|") {
+			t.Errorf("%v: got %v, want %v", c.labels, paths, c.paths)
+		}
+	}
+
+	// 继承：db-warning的groups继承自db。
+	res := tr.Match(map[string]string{"service": "mysql", "severity": "warning"})[0]
+	if len(res.Receiver.Groups) != 1 || res.Receiver.Groups[0] != "dba" {
+		t.Fatalf("inherited groups: %v", res.Receiver.Groups)
+	}
+}
+
+type fakeClient struct {
+	sent  []string
+	posts []client.Message
+}
+
+func (c *fakeClient) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	c.sent = append(c.sent, "text:"+strings.Join(users, ","))
+	return nil, nil, nil
+}
+
+func (c *fakeClient) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	c.sent = append(c.sent, "group:"+strings.Join(groupIds, ","))
+	return nil, nil, nil
+}
+
+func (c *fakeClient) SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+	c.sent = append(c.sent, "team")
+	c.posts = append(c.posts, msg)
+	return nil, nil, nil
+}
+
+func (c *fakeClient) SendSingleStrongNotice(touser, content string, opts ...client.StrongNoticeOption) error {
+	c.sent = append(c.sent, "strong_notice:"+touser)
+	return nil
+}
+
+func (c *fakeClient) SendVoiceToUsers(accounts []string, msg client.Message) ([]client.UserVoiceResult, error) {
+	c.sent = append(c.sent, "voice:"+strings.Join(accounts, ","))
+	return nil, nil
+}
+
+func TestDispatch(t *testing.T) {
+	cli := new(fakeClient)
+	r := NewRouter(tree(t), cli, nil)
+	err := r.Dispatch(Event{Service: "redis", Severity: "critical", Text: "redis down"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "voice:oncall,group:ops,group:dba"
+	if got := strings.Join(cli.sent, ","); got != want {
+		t.Fatalf("sent: %v, want %v", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cli := new(fakeClient)
+	var out strings.Builder
+	r := NewRouter(tree(t), cli, &Options{DryRun: true, Output: &out})
+	r.Dispatch(Event{Service: "mysql", Severity: "warning"})
+	if len(cli.sent) != 0 {
+		t.Fatalf("dry run sent: %v", cli.sent)
+	}
+	if !strings.Contains(out.String(), "route: root > db > db-warning") ||
+		!strings.Contains(out.String(), "strong_notice -> users [dba-lead] notice [sms]") {
+		t.Fatalf("dry run output:\n%v", out.String())
+	}
+}
+
+func TestDryRunErrorf(t *testing.T) {
+	var logs []string
+	r := NewRouter(tree(t), new(fakeClient), &Options{
+		DryRun: true,
+		Errorf: func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) },
+	})
+	r.Dispatch(Event{Service: "web"})
+	if len(logs) != 1 || !strings.Contains(logs[0], "route: root") {
+		t.Fatalf("dry run logs: %v", logs)
+	}
+
+	// 没有Output及Errorf时不输出。
+	NewRouter(tree(t), new(fakeClient), &Options{DryRun: true}).Dispatch(Event{Service: "web"})
+}
+
+func TestDispatchTeamText(t *testing.T) {
+	root := &Node{Receiver: Receiver{
+		Channels: []string{ChannelText, ChannelTeam},
+		Users:    []string{"zhangsan"},
+		Teams:    []client.TeamChannel{{TeamId: "t1", ChannelId: "c1"}},
+	}}
+	tr, err := New(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := new(fakeClient)
+	if err = NewRouter(tr, cli, nil).Dispatch(Event{Text: "a<b\nc"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(cli.posts) != 1 {
+		t.Fatalf("posts: %v", cli.posts)
+	}
+	rt, ok := cli.posts[0].(message.RichText)
+	if !ok || rt.HTML != "a&lt;b<br/>c" {
+		t.Fatalf("team post: %#v", cli.posts[0])
+	}
+}
@@ -0,0 +1,169 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/util/alertmanager"
+)
+
+// 通知渠道。
+const (
+	ChannelText         = "text"          // 单聊消息，发给Users
+	ChannelStrongNotice = "strong_notice" // 单聊强通知，发给Users，Notice指定短信/电话提醒
+	ChannelVoice        = "voice"         // 电话报警，打给Users
+	ChannelGroup        = "group"         // 群消息，发到Groups并@AtUsers
+	ChannelTeam         = "team"          // 团队帖子，发到Teams
+)
+
+// 强通知提醒方式。
+const (
+	NoticeSMS  = "sms"  // 超过1分钟未接收时发短信提醒
+	NoticeCall = "call" // 超过1分钟未接收时打电话提醒
+)
+
+// Receiver为通知渠道及接收方。子节点未设置的字段继承父节点。
+type Receiver struct {
+	Channels []string             `json:"channels,omitempty"`
+	Users    []string             `json:"users,omitempty"`
+	Groups   []string             `json:"groups,omitempty"`
+	AtUsers  []string             `json:"at_users,omitempty"` // 如果需要@所有人，传["@all"]
+	Teams    []client.TeamChannel `json:"teams,omitempty"`
+	Notice   []string             `json:"notice,omitempty"` // NoticeSMS, NoticeCall
+}
+
+func (r Receiver) inherit(parent Receiver) Receiver {
+	if r.Channels == nil {
+		r.Channels = parent.Channels
+	}
+	if r.Users == nil {
+		r.Users = parent.Users
+	}
+	if r.Groups == nil {
+		r.Groups = parent.Groups
+	}
+	if r.AtUsers == nil {
+		r.AtUsers = parent.AtUsers
+	}
+	if r.Teams == nil {
+		r.Teams = parent.Teams
+	}
+	if r.Notice == nil {
+		r.Notice = parent.Notice
+	}
+	return r
+}
+
+// Node为路由树节点，与Alertmanager route语义一致：
+// 事件匹配节点后，依次匹配子节点，匹配到第一个子节点后停止，除非该子节点Continue为true；
+// 没有子节点匹配时，由该节点接收。根节点匹配所有事件。
+// Match、MatchRE的匹配规则同alertmanager.Route。
+type Node struct {
+	Name    string            `json:"name,omitempty"`     // 用于展示路由路径，默认为在父节点中的下标
+	Match   map[string]string `json:"match,omitempty"`    // 标签值完全相等
+	MatchRE map[string]string `json:"match_re,omitempty"` // 标签值完全匹配正则表达式
+
+	Receiver
+
+	Continue bool    `json:"continue,omitempty"`
+	Routes   []*Node `json:"routes,omitempty"`
+
+	route alertmanager.Route
+}
+
+func (n *Node) compile(name string) error {
+	if n.Name == "" {
+		n.Name = name
+	}
+	n.route = alertmanager.Route{Match: n.Match, MatchRE: n.MatchRE}
+	if err := n.route.Compile(); err != nil {
+		return fmt.Errorf("route %v: %w", n.Name, err)
+	}
+	for _, ch := range n.Channels {
+		switch ch {
+		case ChannelText, ChannelStrongNotice, ChannelVoice, ChannelGroup, ChannelTeam:
+		default:
+			return fmt.Errorf("route %v: unknown channel %q", n.Name, ch)
+		}
+	}
+	for _, notice := range n.Notice {
+		if notice != NoticeSMS && notice != NoticeCall {
+			return fmt.Errorf("route %v: unknown notice %q", n.Name, notice)
+		}
+	}
+	for i, child := range n.Routes {
+		if child == nil {
+			return fmt.Errorf("route %v: routes[%v] is null", n.Name, i)
+		}
+		if err := child.compile(strconv.Itoa(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Result为一条路由结果。
+type Result struct {
+	Path     []string // 从根节点到接收节点的名称
+	Receiver Receiver // 已合并继承字段
+}
+
+// Tree为编译后的路由树，只读，可并发使用。
+type Tree struct {
+	root *Node
+}
+
+// Load从JSON配置文件加载路由树，文件内容为根节点。
+func Load(path string) (*Tree, error) {
+	p, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("route: read config: %w", err)
+	}
+	root := new(Node)
+	if err = json.Unmarshal(p, root); err != nil {
+		return nil, fmt.Errorf("route: parse config %v: %w", path, err)
+	}
+	return New(root)
+}
+
+// New编译路由树。根节点的Match、MatchRE及Continue被忽略。
+func New(root *Node) (*Tree, error) {
+	if root == nil {
+		return nil, errors.New("route: root is nil")
+	}
+	if err := root.compile("root"); err != nil {
+		return nil, fmt.Errorf("route: %w", err)
+	}
+	return &Tree{root: root}, nil
+}
+
+// Match返回labels匹配到的所有接收节点。
+func (t *Tree) Match(labels map[string]string) []Result {
+	return walk(t.root, labels, nil, Receiver{})
+}
+
+func walk(n *Node, labels map[string]string, path []string, parent Receiver) []Result {
+	path = append(path[:len(path):len(path)], n.Name)
+	recv := n.Receiver.inherit(parent)
+
+	var results []Result
+	matched := false
+	for _, child := range n.Routes {
+		if !child.route.Matches(labels) {
+			continue
+		}
+		matched = true
+		results = append(results, walk(child, labels, path, recv)...)
+		if !child.Continue {
+			break
+		}
+	}
+	if !matched {
+		results = append(results, Result{Path: path, Receiver: recv})
+	}
+	return results
+}
@@ -0,0 +1,140 @@
+package sanitize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 深色模式下可读的亮度范围（HSL亮度，0-1）。
+const (
+	minLightness = 0.3
+	maxLightness = 0.7
+)
+
+var namedColors = map[string]rgb{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "gray": {128, 128, 128}, "grey": {128, 128, 128},
+	"silver": {192, 192, 192}, "red": {255, 0, 0}, "maroon": {128, 0, 0}, "orange": {255, 165, 0},
+	"yellow": {255, 255, 0}, "olive": {128, 128, 0}, "lime": {0, 255, 0}, "green": {0, 128, 0},
+	"aqua": {0, 255, 255}, "cyan": {0, 255, 255}, "teal": {0, 128, 128}, "blue": {0, 0, 255},
+	"navy": {0, 0, 128}, "fuchsia": {255, 0, 255}, "magenta": {255, 0, 255}, "purple": {128, 0, 128},
+}
+
+type rgb struct{ r, g, b uint8 }
+
+func (c rgb) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+}
+
+// parseColor解析#rgb、#rrggbb、rgb()、rgba()及基本颜色名。
+func parseColor(s string) (rgb, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if len(hex) != 6 || err != nil {
+			return rgb{}, false
+		}
+		return rgb{uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+	}
+
+	args, ok := strings.CutPrefix(s, "rgba(")
+	if !ok {
+		args, ok = strings.CutPrefix(s, "rgb(")
+	}
+	args, closed := strings.CutSuffix(args, ")")
+	if !ok || !closed {
+		return rgb{}, false
+	}
+	parts := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+	if len(parts) < 3 {
+		return rgb{}, false
+	}
+	var v [3]uint8
+	for i := range v {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || n > 255 {
+			return rgb{}, false
+		}
+		v[i] = uint8(n)
+	}
+	return rgb{v[0], v[1], v[2]}, true
+}
+
+// hsl返回色相（0-360）、饱和度及亮度（0-1）。
+func (c rgb) hsl() (h, s, l float64) {
+	r, g, b := float64(c.r)/255, float64(c.g)/255, float64(c.b)/255
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h * 60, s, l
+}
+
+func fromHSL(h, s, l float64) rgb {
+	if s == 0 {
+		v := uint8(math.Round(l * 255))
+		return rgb{v, v, v}
+	}
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	channel := func(t float64) uint8 {
+		t = math.Mod(t+1, 1)
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 1.0/2:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(math.Round(v * 255))
+	}
+	h /= 360
+	return rgb{channel(h + 1.0/3), channel(h), channel(h - 1.0/3)}
+}
+
+// darkModeSafe判断字体颜色在浅色及深色背景下是否均可读。
+func (c rgb) darkModeSafe() bool {
+	_, _, l := c.hsl()
+	return minLightness <= l && l <= maxLightness
+}
+
+// rewrite将亮度调整到可读范围，保留色相及饱和度。
+func (c rgb) rewrite() rgb {
+	h, s, l := c.hsl()
+	l = math.Max(minLightness, math.Min(maxLightness, l))
+	return fromHSL(h, s, l)
+}
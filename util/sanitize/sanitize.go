@@ -0,0 +1,295 @@
+// Package sanitize按白名单清理嵌入页面消息（message.Page）及团队帖子（message.RichText）的html，
+// 去掉脚本、事件属性、危险链接，补全未闭合标签，并按深色模式规则处理颜色。
+package sanitize
+
+import (
+	"html"
+	"strings"
+
+	"github.com/eachain/360-tuitui-robot/internal/htmltoken"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// 颜色处理方式，见Options.Colors。
+const (
+	ColorRewrite = "rewrite" // 过暗或过亮的字体颜色调整为中等亮度，保留色相
+	ColorStrip   = "strip"   // 删除过暗或过亮的字体颜色
+	ColorKeep    = "keep"    // 保留所有颜色
+)
+
+// DefaultTags为默认允许的标签及其属性，所有标签均允许style及title属性。
+var DefaultTags = map[string][]string{
+	"p": nil, "div": nil, "br": nil, "hr": nil,
+	"span": {"data-type", "data-id", "data-label"},
+	"b":    nil, "strong": nil, "i": nil, "em": nil, "u": nil, "s": nil, "del": nil, "strike": nil,
+	"sub": nil, "sup": nil, "small": nil,
+	"font": {"color"},
+	"a":    {"href", "target"},
+	"img":  {"src", "alt", "width", "height"},
+	"h1":   nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"ul": nil, "ol": nil, "li": nil, "blockquote": nil, "pre": nil, "code": {"class"},
+	"table": {"border"}, "thead": nil, "tbody": nil, "tr": nil,
+	"th": {"colspan", "rowspan", "align"}, "td": {"colspan", "rowspan", "align"},
+}
+
+// DefaultStyles为默认允许的css属性。背景色在深色模式下不可读，默认不允许。
+var DefaultStyles = []string{
+	"color", "font-weight", "font-style", "font-size", "text-decoration", "text-align",
+}
+
+// 内容一并删除的标签。
+var dropTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true, "template": true,
+	"head": true, "title": true, "noscript": true, "textarea": true, "select": true, "svg": true, "math": true,
+}
+
+// 文本只转义&<>，保留引号，以免破坏{{tuitui_at "xxx"}}等模板指令。
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// 允许的链接协议，无协议的相对地址同样允许。
+var safeSchemes = []string{"http:", "https:", "mailto:", "tel:"}
+
+type Options struct {
+	// 允许的标签及属性，默认为DefaultTags。不在白名单中的标签被删除，但保留其内容。
+	Tags map[string][]string
+
+	// 允许的css属性，默认为DefaultStyles。
+	// NoStyle为true时删除所有style属性。
+	Styles  []string
+	NoStyle bool
+
+	// 颜色处理方式：ColorRewrite、ColorStrip、ColorKeep，默认为ColorRewrite。
+	// 适用于style中的color及<font color>。ColorKeep以外，无法识别的颜色均被删除。
+	Colors string
+}
+
+// Sanitizer为html清理器，可以并发使用。
+type Sanitizer struct {
+	tags   map[string]map[string]bool
+	styles map[string]bool
+	colors string
+}
+
+// New新建Sanitizer，*Options可以为空（详见Options定义/默认值）。
+func New(opts *Options) *Sanitizer {
+	if opts == nil {
+		opts = new(Options)
+	}
+	tags := opts.Tags
+	if tags == nil {
+		tags = DefaultTags
+	}
+	styles := opts.Styles
+	if styles == nil {
+		styles = DefaultStyles
+	}
+
+	s := &Sanitizer{
+		tags:   make(map[string]map[string]bool, len(tags)),
+		styles: make(map[string]bool, len(styles)),
+		colors: opts.Colors,
+	}
+	for tag, attrs := range tags {
+		allowed := map[string]bool{"title": true, "style": !opts.NoStyle}
+		for _, a := range attrs {
+			allowed[strings.ToLower(a)] = true
+		}
+		s.tags[strings.ToLower(tag)] = allowed
+	}
+	for _, p := range styles {
+		s.styles[strings.ToLower(p)] = true
+	}
+	if s.colors == "" {
+		s.colors = ColorRewrite
+	}
+	return s
+}
+
+var std = New(nil)
+
+// HTML以默认配置清理content。
+func HTML(content string) string {
+	return std.HTML(content)
+}
+
+// Text将纯文本转为html：转义特殊字符，换行转为<br/>。
+func Text(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br/>")
+}
+
+// HTML清理content，返回的html标签均已闭合。
+func (s *Sanitizer) HTML(content string) string {
+	var b strings.Builder
+	var open []string    // 已输出、未闭合的标签
+	drop, depth := "", 0 // 正在删除内容的标签及其嵌套层数，如<svg><svg></svg></svg>
+
+	for _, tok := range htmltoken.Tokenize(content) {
+		if drop != "" {
+			switch {
+			case tok.Type == htmltoken.StartTag && tok.Data == drop:
+				depth++
+			case tok.Type == htmltoken.EndTag && tok.Data == drop:
+				if depth--; depth == 0 {
+					drop = ""
+				}
+			}
+			continue
+		}
+
+		switch tok.Type {
+		case htmltoken.Text:
+			b.WriteString(textEscaper.Replace(tok.Data))
+
+		case htmltoken.StartTag, htmltoken.SelfClosing:
+			if dropTags[tok.Data] {
+				if tok.Type == htmltoken.StartTag {
+					drop, depth = tok.Data, 1
+				}
+				continue
+			}
+			attrs, ok := s.tags[tok.Data]
+			if !ok {
+				continue
+			}
+			b.WriteByte('<')
+			b.WriteString(tok.Data)
+			for _, a := range tok.Attrs {
+				if val, ok := s.attr(a, attrs); ok {
+					b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+				}
+			}
+			if voidTags[tok.Data] {
+				b.WriteString("/>")
+				continue
+			}
+			b.WriteByte('>')
+			if tok.Type == htmltoken.SelfClosing {
+				b.WriteString("</" + tok.Data + ">")
+				continue
+			}
+			open = append(open, tok.Data)
+
+		case htmltoken.EndTag:
+			// 闭合到最近的同名标签，中间未闭合的标签一并闭合；没有同名标签时忽略
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tok.Data {
+					for j := len(open) - 1; j >= i; j-- {
+						b.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+// attr返回清理后的属性值，不允许的属性ok为false。
+func (s *Sanitizer) attr(a htmltoken.Attr, allowed map[string]bool) (string, bool) {
+	if !allowed[a.Key] {
+		return "", false
+	}
+	switch a.Key {
+	case "style":
+		style := s.style(a.Val)
+		return style, style != ""
+	case "href", "src":
+		return a.Val, safeURL(a.Val)
+	case "color":
+		return s.color(a.Val)
+	}
+	return a.Val, true
+}
+
+// style保留允许的css属性，删除含url()、expression()等的值。
+func (s *Sanitizer) style(style string) string {
+	var out []string
+	for _, decl := range strings.Split(style, ";") {
+		prop, val, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		val = strings.TrimSpace(val)
+		if !s.styles[prop] || val == "" || unsafeCSS(val) {
+			continue
+		}
+		if prop == "color" {
+			if val, ok = s.color(val); !ok {
+				continue
+			}
+		}
+		out = append(out, prop+": "+val)
+	}
+	return strings.Join(out, "; ")
+}
+
+// color按Options.Colors处理字体颜色，ok为false时删除该颜色。
+func (s *Sanitizer) color(val string) (string, bool) {
+	if s.colors == ColorKeep {
+		return val, !unsafeCSS(val)
+	}
+	c, ok := parseColor(val)
+	if !ok {
+		return "", false
+	}
+	if c.darkModeSafe() {
+		return val, true
+	}
+	if s.colors == ColorStrip {
+		return "", false
+	}
+	return c.rewrite().String(), true
+}
+
+func unsafeCSS(val string) bool {
+	v := strings.ToLower(val)
+	for _, bad := range []string{"url(", "expression(", "javascript:", "import", "\\", "<", ">", "\"", "'"} {
+		if strings.Contains(v, bad) {
+			return true
+		}
+	}
+	return false
+}
+
+func safeURL(u string) bool {
+	// 浏览器会忽略地址中的空白及控制字符，如"java\tscript:"
+	u = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, u))
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return true // 相对地址
+	}
+	for _, scheme := range safeSchemes {
+		if strings.HasPrefix(u, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// Page清理html格式页面消息的Content，Markdown格式保持不变。
+func (s *Sanitizer) Page(page message.Page) message.Page {
+	if page.Format == "" || page.Format == "html" {
+		page.Content = s.HTML(page.Content)
+	}
+	return page
+}
+
+// RichText清理html格式的团队帖子，Markdown格式保持不变。
+func (s *Sanitizer) RichText(rt message.RichText) message.RichText {
+	if rt.Markdown == "" {
+		rt.HTML = s.HTML(rt.HTML)
+	}
+	return rt
+}
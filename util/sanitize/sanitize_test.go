@@ -0,0 +1,68 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	cases := []struct {
+		in, out string
+	}{
+		{`<p onclick="x()">a<script>alert(1)</script>b`, `<p>ab</p>`},
+		{`<b>x<i>y</b>z`, `<b>x<i>y</i></b>z`},
+		{`</div>1 < 2 & {{tuitui_at "a"}}`, `1 &lt; 2 &amp; {{tuitui_at "a"}}`},
+		{`<a href="java&#x09;script:alert(1)">x</a><a href="/p?q=1">y</a>`, `<a>x</a><a href="/p?q=1">y</a>`},
+		{`<img src="https://a.com/x.png" onerror="x()">`, `<img src="https://a.com/x.png"/>`},
+		{`<unknown>kept</unknown><iframe src="x">dropped</iframe>`, `kept`},
+		{`<span style="background: #000; color: #000; font-weight: bold; font-size: url(x)">s</span>`,
+			`<span style="color: #4d4d4d; font-weight: bold">s</span>`},
+		{`<font color="red">r</font><font color="white">w</font>`, `<font color="red">r</font><font color="#b3b3b3">w</font>`},
+	}
+	for _, c := range cases {
+		if got := HTML(c.in); got != c.out {
+			t.Errorf("HTML(%q):\n got: %s\nwant: %s", c.in, got, c.out)
+		}
+	}
+}
+
+func TestColors(t *testing.T) {
+	in := `<span style="color: black">a</span><span style="color: rgb(0, 102, 204)">b</span>`
+
+	strip := New(&Options{Colors: ColorStrip})
+	if got, want := strip.HTML(in), `<span>a</span><span style="color: rgb(0, 102, 204)">b</span>`; got != want {
+		t.Errorf("strip:\n got: %s\nwant: %s", got, want)
+	}
+
+	keep := New(&Options{Colors: ColorKeep})
+	if got, want := keep.HTML(in), in; got != want {
+		t.Errorf("keep:\n got: %s\nwant: %s", got, want)
+	}
+
+	nostyle := New(&Options{NoStyle: true})
+	if got, want := nostyle.HTML(in), `<span>a</span><span>b</span>`; got != want {
+		t.Errorf("no style:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestHostile(t *testing.T) {
+	long := strings.Repeat("Ⱥ", 20)
+	cases := []struct {
+		in, out string
+	}{
+		{"<script>" + long + "</script>ok", "ok"},
+		{"<TITLE>" + long + "</title>ok", "ok"},
+		{"<p>" + long, "<p>" + long + "</p>"},
+		{"<b>x<!-- unterminated <script>", "<b>x</b>"},
+		{`<a href="x`, `&lt;a href="x`},
+		{"<p <b>", "<p></p>"},
+		{"<svg><svg></svg><a href='javascript:x'>x</a></svg>ok", "ok"},
+		{"<style>a{}", ""},
+		{"\x00<i>\u202e</i>", "\x00<i>\u202e</i>"},
+	}
+	for _, c := range cases {
+		if got := HTML(c.in); got != c.out {
+			t.Errorf("HTML(%q):\n got: %s\nwant: %s", c.in, got, c.out)
+		}
+	}
+}
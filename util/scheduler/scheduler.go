@@ -0,0 +1,363 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/cache"
+	"github.com/eachain/360-tuitui-robot/util/outbox"
+)
+
+// Sender为定时发消息所用的接口，*client.Client实现了该接口。
+type Sender interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error)
+}
+
+// 停机期间错过的触发时间的处理方式。
+const (
+	MissedSkip    = "skip"     // 跳过，等待下一次触发
+	MissedRunOnce = "run_once" // 立即补发一次
+	MissedRunAll  = "run_all"  // 每个错过的触发时间各补发一次，最多补发100次
+)
+
+// Job为定时任务，Cron与At二选一。
+type Job struct {
+	Id     string        `json:"id"`
+	Target outbox.Target `json:"target"`
+
+	MsgType  string          `json:"msgtype"`   // client.Message.Type()
+	MsgIndex string          `json:"msg_index"` // client.Message.Index()
+	Msg      json.RawMessage `json:"msg"`       // json格式消息内容
+
+	Cron     string    `json:"cron,omitempty"`     // 周期任务cron表达式，详见Cron
+	At       time.Time `json:"at,omitempty"`       // 一次性任务触发时间，触发后删除任务
+	TimeZone string    `json:"timezone,omitempty"` // cron所用时区，如"Asia/Shanghai"，默认为"Local"
+	Missed   string    `json:"missed,omitempty"`   // MissedSkip, MissedRunOnce, MissedRunAll，默认为MissedSkip
+
+	Next    time.Time `json:"next"`               // 下次触发时间
+	LastRun time.Time `json:"last_run,omitempty"` // 上次触发时间
+	Created time.Time `json:"created"`
+}
+
+// Message返回任务消息。
+func (job *Job) Message() message.Raw {
+	return message.NewRaw(job.MsgType, job.MsgIndex, job.Msg)
+}
+
+// next返回t之后下一次触发时间，一次性任务返回零值。
+func (job *Job) next(t time.Time) (time.Time, error) {
+	if job.Cron == "" {
+		return time.Time{}, nil
+	}
+	c, err := ParseCron(job.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.Local
+	if job.TimeZone != "" {
+		if loc, err = time.LoadLocation(job.TimeZone); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return c.Next(t.In(loc)), nil
+}
+
+type Options struct {
+	// 分布式锁，多副本部署时保证同一任务的同一触发时间只有一个副本发送，可直接使用*redis.Client。
+	// 为空时不加锁，适用于单副本部署。
+	Locker cache.Cache
+
+	// 锁的key前缀，默认为"tuitui:robot:scheduler:"。
+	LockPrefix string
+
+	// 锁过期时间，默认为1天。
+	LockExpire time.Duration
+
+	// 触发时间已过去多久视为错过（按Job.Missed处理），默认为1分钟。
+	Grace time.Duration
+
+	// 最长检查间隔，其它副本新增的任务最迟在该间隔后被感知。默认为30秒。
+	Interval time.Duration
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Scheduler在指定时间或按cron周期发送消息。
+type Scheduler struct {
+	sender Sender
+	store  Store
+	opts   Options
+
+	mu   sync.Mutex // 串行化检查，避免Add与后台检查同时更新同一任务
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New新建Scheduler并启动后台检查。*Options可以为空（详见Options定义/默认值）。
+func New(sender Sender, store Store, opts *Options) *Scheduler {
+	s := &Scheduler{
+		sender: sender,
+		store:  store,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.LockPrefix == "" {
+		s.opts.LockPrefix = "tuitui:robot:scheduler:"
+	}
+	if s.opts.LockExpire <= 0 {
+		s.opts.LockExpire = 24 * time.Hour
+	}
+	if s.opts.Grace <= 0 {
+		s.opts.Grace = time.Minute
+	}
+	if s.opts.Interval <= 0 {
+		s.opts.Interval = 30 * time.Second
+	}
+	if s.opts.Now == nil {
+		s.opts.Now = time.Now
+	}
+	go s.loop()
+	return s
+}
+
+func (s *Scheduler) errorf(format string, args ...any) {
+	if s.opts.Errorf != nil {
+		s.opts.Errorf(format, args...)
+	}
+}
+
+// AddCron新增周期任务。id为空时自动生成，id已存在时覆盖原任务。
+func (s *Scheduler) AddCron(id, spec string, target outbox.Target, msg client.Message) (*Job, error) {
+	if _, err := ParseCron(spec); err != nil {
+		return nil, fmt.Errorf("scheduler: %w", err)
+	}
+	return s.Add(&Job{Id: id, Cron: spec, Target: target}, msg)
+}
+
+// AddAt新增一次性任务。id为空时自动生成，id已存在时覆盖原任务。
+func (s *Scheduler) AddAt(id string, at time.Time, target outbox.Target, msg client.Message) (*Job, error) {
+	return s.Add(&Job{Id: id, At: at, Target: target}, msg)
+}
+
+// Add新增任务，可设置TimeZone、Missed等字段。job.Id为空时自动生成。
+func (s *Scheduler) Add(job *Job, msg client.Message) (*Job, error) {
+	if len(job.Target.Users) == 0 && len(job.Target.Groups) == 0 && len(job.Target.Teams) == 0 {
+		return nil, errors.New("scheduler: job target is empty")
+	}
+	if (job.Cron == "") == job.At.IsZero() {
+		return nil, errors.New("scheduler: exactly one of cron and at must be set")
+	}
+	raw, err := message.RawOf(msg)
+	if err != nil {
+		return nil, fmt.Errorf("scheduler: json encode message: %w", err)
+	}
+
+	cp := *job
+	job = &cp
+	if job.Id == "" {
+		var b [8]byte
+		rand.Read(b[:])
+		job.Id = hex.EncodeToString(b[:])
+	}
+	job.MsgType, job.MsgIndex, job.Msg = raw.Type(), raw.Index(), raw.Content
+	now := s.opts.Now()
+	job.Created = now
+	if job.Cron != "" {
+		if job.Next, err = job.next(now); err != nil {
+			return nil, fmt.Errorf("scheduler: job %v: %w", job.Id, err)
+		}
+	} else {
+		job.Next = job.At
+	}
+
+	s.mu.Lock()
+	err = s.store.Put(job)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("scheduler: put job %v: %w", job.Id, err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Remove删除任务。
+func (s *Scheduler) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Delete(id)
+}
+
+// Jobs返回所有任务。
+func (s *Scheduler) Jobs() ([]*Job, error) {
+	return s.store.List()
+}
+
+// Close停止后台检查。
+func (s *Scheduler) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	for {
+		next := s.Tick()
+
+		wait := s.opts.Interval
+		if !next.IsZero() {
+			if d := next.Sub(s.opts.Now()); d < wait {
+				wait = d
+			}
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Tick检查并触发所有到期任务，返回最近一次触发时间。后台自动调用，一般无需手动调用。
+func (s *Scheduler) Tick() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.store.List()
+	if err != nil {
+		s.errorf("scheduler: list jobs: %v", err)
+		return time.Time{}
+	}
+
+	now := s.opts.Now()
+	var earliest time.Time
+	for _, job := range jobs {
+		if !job.Next.After(now) {
+			s.run(job, now)
+		}
+		if job.Next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || job.Next.Before(earliest) {
+			earliest = job.Next
+		}
+	}
+	return earliest
+}
+
+// run处理到期任务：按错过策略触发，计算下次触发时间并保存。
+func (s *Scheduler) run(job *Job, now time.Time) {
+	missed := now.Sub(job.Next) > s.opts.Grace
+
+	var err error
+	switch {
+	case !missed:
+		s.fire(job, job.Next)
+		job.Next, err = job.next(job.Next)
+	case job.Missed == MissedRunOnce:
+		s.fire(job, job.Next)
+		job.Next, err = job.next(now)
+	case job.Missed == MissedRunAll:
+		for i := 0; i < 100 && !job.Next.IsZero() && !job.Next.After(now); i++ {
+			s.fire(job, job.Next)
+			if job.Next, err = job.next(job.Next); err != nil {
+				break
+			}
+		}
+		if err == nil && !job.Next.IsZero() && !job.Next.After(now) {
+			job.Next, err = job.next(now)
+		}
+	default:
+		s.errorf("scheduler: job %v: skip missed run at %v", job.Id, job.Next)
+		job.Next, err = job.next(now)
+	}
+	if err != nil {
+		s.errorf("scheduler: job %v: next run: %v", job.Id, err)
+		job.Next = time.Time{}
+	}
+
+	if job.Next.IsZero() {
+		err = s.store.Delete(job.Id)
+	} else {
+		err = s.store.Put(job)
+	}
+	if err != nil {
+		s.errorf("scheduler: job %v: save: %v", job.Id, err)
+	}
+}
+
+// fire在获得at对应的锁后发送消息。
+func (s *Scheduler) fire(job *Job, at time.Time) {
+	job.LastRun = at
+	if s.opts.Locker != nil {
+		key := s.opts.LockPrefix + job.Id + ":" + strconv.FormatInt(at.Unix(), 10)
+		ok, err := s.opts.Locker.SetNX(context.Background(), key, "1", int64(s.opts.LockExpire/time.Second))
+		// 加锁报错时仍然发送，重复发送好过不发送。
+		if err != nil {
+			s.errorf("scheduler: job %v: lock %v: %v", job.Id, key, err)
+		} else if !ok {
+			return
+		}
+	}
+
+	msg := job.Message()
+	t := job.Target
+	if len(t.Users) > 0 {
+		_, warn, err := s.sender.SendMessageToUsers(t.Users, msg)
+		s.report(job, "users", warnErr(err, warn))
+	}
+	if len(t.Groups) > 0 {
+		_, warn, err := s.sender.SendMessageToGroups(t.Groups, t.AtUsers, msg)
+		s.report(job, "groups", warnErr(err, warn))
+	}
+	if len(t.Teams) > 0 {
+		_, warn, err := s.sender.SendPostToTeams(t.Teams, msg)
+		if err == nil && warn != nil {
+			err = warn.Explains
+		}
+		s.report(job, "teams", err)
+	}
+}
+
+func warnErr(err error, warn *client.Warning[string]) error {
+	if err == nil && warn != nil {
+		return fmt.Errorf("%v: %w", warn.Fails, warn.Explains)
+	}
+	return err
+}
+
+func (s *Scheduler) report(job *Job, to string, err error) {
+	if err != nil {
+		s.errorf("scheduler: job %v: send to %v: %v", job.Id, to, err)
+	}
+}
@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+	"github.com/eachain/360-tuitui-robot/util/outbox"
+)
+
+func TestCronNext(t *testing.T) {
+	cases := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"*/15 9-17 * * MON-FRI", "2024-01-05T17:50:00Z", "2024-01-08T09:00:00Z"}, // 周五 -> 周一
+		{"30 10 * * *", "2024-01-01T10:30:00Z", "2024-01-02T10:30:00Z"},
+		{"0 0 1,15 * 1", "2024-01-02T00:00:00Z", "2024-01-08T00:00:00Z"}, // 日与周满足其一即可
+		{"@weekly", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},
+		{"0 12 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T12:00:00Z"},
+		{"5/20 * * * *", "2024-01-01T00:06:00Z", "2024-01-01T00:25:00Z"},
+	}
+	for _, c := range cases {
+		cron, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%v: %v", c.expr, err)
+		}
+		from, _ := time.Parse(time.RFC3339, c.from)
+		if got := cron.Next(from).Format(time.RFC3339); got != c.want {
+			t.Errorf("%v next of %v: got %v, want %v", c.expr, c.from, got, c.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%v: expected error", expr)
+		}
+	}
+}
+
+type fakeSender struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (s *fakeSender) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	return nil, nil, nil
+}
+
+func (s *fakeSender) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent++
+	return nil, nil, nil
+}
+
+func (s *fakeSender) SendPostToTeams(teams []client.TeamChannel, msg client.Message) ([]client.TeamPost, *client.Warning[client.TeamChannel], error) {
+	return nil, nil, nil
+}
+
+func (s *fakeSender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sent
+}
+
+func TestMissed(t *testing.T) {
+	now := time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC)
+	cases := map[string]int{MissedSkip: 0, MissedRunOnce: 1, MissedRunAll: 3}
+	for policy, want := range cases {
+		store := NewMemStore()
+		raw, _ := message.RawOf(message.NewText("standup"))
+		store.Put(&Job{
+			Id:       "standup",
+			Target:   outbox.Target{Groups: []string{"g1"}},
+			MsgType:  raw.MsgType,
+			MsgIndex: raw.MsgIndex,
+			Msg:      raw.Content,
+			Cron:     "0 10 * * *",
+			TimeZone: "UTC",
+			Missed:   policy,
+			Next:     time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+		})
+
+		sender := new(fakeSender)
+		s := New(sender, store, &Options{Now: func() time.Time { return now }})
+		s.Tick()
+		s.Close()
+
+		if got := sender.count(); got != want {
+			t.Errorf("%v: sent %v, want %v", policy, got, want)
+		}
+		jobs, _ := store.List()
+		if len(jobs) != 1 || !jobs[0].Next.Equal(time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("%v: next run: %+v", policy, jobs)
+		}
+	}
+}
+
+type fakeLocker struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (l *fakeLocker) SetNX(ctx context.Context, key string, value string, expireSeconds int64) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.keys[key] {
+		return false, nil
+	}
+	l.keys[key] = true
+	return true, nil
+}
+
+func TestLock(t *testing.T) {
+	now := time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC)
+	locker := &fakeLocker{keys: make(map[string]bool)}
+	sender := new(fakeSender)
+
+	// 两个副本各自的存储中都有同一个到期任务。
+	for i := 0; i < 2; i++ {
+		store := NewMemStore()
+		s := New(sender, store, &Options{Locker: locker, Now: func() time.Time { return now }})
+		_, err := s.AddAt("once", now, outbox.Target{Groups: []string{"g1"}}, message.NewText("release"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Tick()
+		s.Close()
+		if jobs, _ := store.List(); len(jobs) != 0 {
+			t.Fatalf("one-off job not deleted: %+v", jobs)
+		}
+	}
+	if got := sender.count(); got != 1 {
+		t.Fatalf("sent %v times", got)
+	}
+}
@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/eachain/360-tuitui-robot/internal/fileutil"
+)
+
+// Store为任务持久化接口。多副本部署时，各副本应使用同一共享存储（如数据库、redis）。
+type Store interface {
+	// List返回所有任务。
+	List() ([]*Job, error)
+	// Put新增或更新任务。
+	Put(job *Job) error
+	// Delete删除任务，任务不存在时不报错。
+	Delete(id string) error
+}
+
+type memStore struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewMemStore返回内存Store，进程重启后任务丢失，仅用于测试或单机临时任务。
+func NewMemStore() Store {
+	return &memStore{jobs: make(map[string]*Job)}
+}
+
+func (s *memStore) List() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		cp := *job
+		jobs = append(jobs, &cp)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Id < jobs[j].Id })
+	return jobs, nil
+}
+
+func (s *memStore) Put(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *job
+	s.jobs[job.Id] = &cp
+	return nil
+}
+
+func (s *memStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+// FileStore将任务以json格式保存在本地文件中，适用于单副本部署。
+type FileStore struct {
+	path string
+	mem  *memStore
+}
+
+// NewFileStore打开（或新建）path文件作为Store。
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, mem: NewMemStore().(*memStore)}
+	p, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scheduler: read store: %w", err)
+	}
+	var jobs []*Job
+	if err = json.Unmarshal(p, &jobs); err != nil {
+		return nil, fmt.Errorf("scheduler: parse store %v: %w", path, err)
+	}
+	for _, job := range jobs {
+		s.mem.jobs[job.Id] = job
+	}
+	return s, nil
+}
+
+func (s *FileStore) List() ([]*Job, error) {
+	return s.mem.List()
+}
+
+func (s *FileStore) Put(job *Job) error {
+	s.mem.Put(job)
+	return s.save()
+}
+
+func (s *FileStore) Delete(id string) error {
+	s.mem.Delete(id)
+	return s.save()
+}
+
+// save将所有任务写回文件。
+func (s *FileStore) save() error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(s.mem.jobs))
+	for _, job := range s.mem.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Id < jobs[j].Id })
+	p, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("scheduler: json encode jobs: %w", err)
+	}
+
+	if err = fileutil.WriteFile(s.path, append(p, '\n')); err != nil {
+		return fmt.Errorf("scheduler: save store: %w", err)
+	}
+	return nil
+}
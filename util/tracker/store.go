@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+)
+
+// 事件记录，即某个报警从第一次发送起，对应的所有消息id。
+type Incident struct {
+	Fingerprint string                  `json:"fingerprint"`
+	Status      string                  `json:"status"` // 最近一次状态，如"firing"
+	AtUsers     []string                `json:"at_users,omitempty"`
+	Users       []client.UserMsgIdPair  `json:"users,omitempty"`
+	Groups      []client.GroupMsgIdPair `json:"groups,omitempty"`
+	PageIds     map[string]string       `json:"page_ids,omitempty"` // 页面消息：消息id→页面id
+	Created     time.Time               `json:"created"`
+	Updated     time.Time               `json:"updated"`
+}
+
+// clone复制incident引用的切片及map，使其与原记录互不影响。
+func (incident *Incident) clone() {
+	incident.AtUsers = slices.Clone(incident.AtUsers)
+	incident.Users = slices.Clone(incident.Users)
+	incident.Groups = slices.Clone(incident.Groups)
+	incident.PageIds = maps.Clone(incident.PageIds)
+}
+
+func (incident *Incident) setPage(msgid, pageId string) {
+	if pageId == "" {
+		delete(incident.PageIds, msgid)
+		return
+	}
+	if incident.PageIds == nil {
+		incident.PageIds = make(map[string]string)
+	}
+	incident.PageIds[msgid] = pageId
+}
+
+// Store保存报警指纹对应的事件记录。
+//
+// 内存模式可以用NewMemStore()实现；分布式环境可由业务基于redis/mysql等实现，*Incident可直接json编码存储。
+type Store interface {
+	// Get读取事件记录，不存在时返回nil, nil。
+	Get(fingerprint string) (*Incident, error)
+	// Put写入（覆盖）事件记录。
+	Put(incident *Incident) error
+	// Delete删除事件记录，不存在时不报错。
+	Delete(fingerprint string) error
+}
+
+type memStore struct {
+	mu        sync.Mutex
+	incidents map[string]Incident
+}
+
+// NewMemStore用内存实现Store，进程重启后记录丢失，可用于单实例应用。
+func NewMemStore() Store {
+	return &memStore{incidents: make(map[string]Incident)}
+}
+
+func (ms *memStore) Get(fingerprint string) (*Incident, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	incident, ok := ms.incidents[fingerprint]
+	if !ok {
+		return nil, nil
+	}
+	incident.clone()
+	return &incident, nil
+}
+
+func (ms *memStore) Put(incident *Incident) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	stored := *incident
+	stored.clone()
+	ms.incidents[incident.Fingerprint] = stored
+	return nil
+}
+
+func (ms *memStore) Delete(fingerprint string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.incidents, fingerprint)
+	return nil
+}
@@ -0,0 +1,325 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+// Client为Tracker发送、修改消息所用的接口，*client.Client实现了该接口。
+type Client interface {
+	SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error)
+	SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error)
+	SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error)
+	SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error)
+	ModifyUserMessages(msgids []client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) ([]client.UserMsgIdPair, *client.Warning[client.UserMsgIdPair], error)
+	ModifyGroupMessages(msgids []client.GroupMsgIdPair, atUsers []string, msg client.Message, opt *client.ModifyOptions) ([]client.GroupMsgIdPair, *client.Warning[client.GroupMsgIdPair], error)
+}
+
+// 报警消息接收方。
+type Target struct {
+	Users   []string // 单聊域账号列表
+	Groups  []string // 群id列表
+	AtUsers []string // 群消息@列表，如果需要@所有人，传["@all"]。页面消息不支持@
+}
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+type Options struct {
+	// 后续更新时，修改消息是否不推送厂商推送，避免手机反复响铃。
+	WithoutPush bool
+
+	// 报警恢复时撤回原消息，而不是将原消息修改为恢复状态。
+	RecallOnResolve bool
+
+	// 默认为time.Now，可自定义。
+	Now func() time.Time
+
+	// Errorf用于输出错误日志，由调用方提供，可以为log.Printf。
+	// 默认为nil，表示不输出日志。
+	Errorf func(string, ...any)
+}
+
+// Tracker按报警指纹跟踪报警消息，使每个报警在聊天中只有一条不断更新的消息。
+//
+// 报警第一次触发时发送消息并记录消息id；之后的每次更新都修改原消息；恢复时修改或撤回原消息，并删除记录。
+// 页面消息（message.Page）同时记录页面id，修改及撤回时带上原页面id。
+type Tracker struct {
+	cli   Client
+	store Store
+	opts  Options
+
+	mu sync.Mutex // 串行处理，避免同一报警并发首次发送产生多条消息
+}
+
+// New新建Tracker，*Options可以为空（详见Options定义/默认值）。
+func New(cli Client, store Store, opts *Options) *Tracker {
+	t := &Tracker{cli: cli, store: store}
+	if opts != nil {
+		t.opts = *opts
+	}
+	if t.opts.Now == nil {
+		t.opts.Now = time.Now
+	}
+	return t
+}
+
+// Fire报警触发或更新。如果该报警已有消息，修改原消息为msg；否则发送msg给target，并记录消息id。
+func (t *Tracker) Fire(fingerprint string, target Target, msg client.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	incident, err := t.store.Get(fingerprint)
+	if err != nil {
+		return fmt.Errorf("tracker: get incident %v: %w", fingerprint, err)
+	}
+
+	now := t.opts.Now()
+	if incident == nil {
+		incident = &Incident{
+			Fingerprint: fingerprint,
+			AtUsers:     target.AtUsers,
+			Created:     now,
+		}
+		err = t.send(incident, target, msg)
+	} else {
+		err = t.modify(incident, msg)
+	}
+	incident.Status = StatusFiring
+	incident.Updated = now
+
+	if len(incident.Users) == 0 && len(incident.Groups) == 0 {
+		return err
+	}
+	if perr := t.store.Put(incident); perr != nil {
+		return errors.Join(err, fmt.Errorf("tracker: put incident %v: %w", fingerprint, perr))
+	}
+	return err
+}
+
+// Resolve报警恢复。如果该报警已有消息，修改原消息为msg（或撤回，见Options.RecallOnResolve），并删除记录；
+// 否则直接发送msg给target。
+func (t *Tracker) Resolve(fingerprint string, target Target, msg client.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	incident, err := t.store.Get(fingerprint)
+	if err != nil {
+		return fmt.Errorf("tracker: get incident %v: %w", fingerprint, err)
+	}
+
+	if incident == nil {
+		return t.send(&Incident{Fingerprint: fingerprint}, target, msg)
+	}
+
+	if t.opts.RecallOnResolve {
+		err = t.recall(incident)
+	} else {
+		err = t.modify(incident, msg)
+	}
+	if derr := t.store.Delete(fingerprint); derr != nil {
+		return errors.Join(err, fmt.Errorf("tracker: delete incident %v: %w", fingerprint, derr))
+	}
+	return err
+}
+
+// Incident查询报警对应的事件记录，不存在时返回nil, nil。
+func (t *Tracker) Incident(fingerprint string) (*Incident, error) {
+	return t.store.Get(fingerprint)
+}
+
+func (t *Tracker) send(incident *Incident, target Target, msg client.Message) error {
+	_, isPage := asPage(msg)
+	var errs []error
+	if len(target.Users) > 0 {
+		var pageId string
+		var pairs []client.UserMsgIdPair
+		var warn *client.Warning[string]
+		var err error
+		if isPage {
+			pageId, pairs, warn, err = t.cli.SendPageToUsers(target.Users, msg)
+		} else {
+			pairs, warn, err = t.cli.SendMessageToUsers(target.Users, msg)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			errs = append(errs, warn.Explains)
+		}
+		for _, pair := range pairs {
+			incident.setPage(pair.MsgId, pageId)
+		}
+		incident.Users = append(incident.Users, pairs...)
+	}
+	if len(target.Groups) > 0 {
+		var pageId string
+		var pairs []client.GroupMsgIdPair
+		var warn *client.Warning[string]
+		var err error
+		if isPage {
+			pageId, pairs, warn, err = t.cli.SendPageToGroups(target.Groups, msg)
+		} else {
+			pairs, warn, err = t.cli.SendMessageToGroups(target.Groups, target.AtUsers, msg)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			errs = append(errs, warn.Explains)
+		}
+		for _, pair := range pairs {
+			incident.setPage(pair.MsgId, pageId)
+		}
+		incident.Groups = append(incident.Groups, pairs...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("tracker: send incident %v: %w", incident.Fingerprint, err)
+	}
+	return nil
+}
+
+// modify修改原消息。修改失败的消息（如超过可修改时限）将重新发送，并用新消息id替换原消息id。
+func (t *Tracker) modify(incident *Incident, msg client.Message) error {
+	opt := &client.ModifyOptions{WithoutPush: t.opts.WithoutPush}
+	var resend Target
+	var errs []error
+
+	var failedUsers []client.UserMsgIdPair
+	for _, batch := range byPage(incident, incident.Users, func(p client.UserMsgIdPair) string { return p.MsgId }) {
+		_, warn, err := t.cli.ModifyUserMessages(batch.pairs, withPageId(msg, batch.pageId), opt)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			failedUsers = append(failedUsers, warn.Fails...)
+			t.errorf("tracker: modify incident %v user messages: %v, resend", incident.Fingerprint, warn.Explains)
+		}
+	}
+	if len(failedUsers) > 0 {
+		failed := make(map[client.UserMsgIdPair]bool, len(failedUsers))
+		for _, pair := range failedUsers {
+			failed[pair] = true
+			resend.Users = append(resend.Users, pair.User)
+		}
+		kept := incident.Users[:0]
+		for _, pair := range incident.Users {
+			if failed[pair] {
+				incident.setPage(pair.MsgId, "")
+			} else {
+				kept = append(kept, pair)
+			}
+		}
+		incident.Users = kept
+	}
+
+	var failedGroups []client.GroupMsgIdPair
+	for _, batch := range byPage(incident, incident.Groups, func(p client.GroupMsgIdPair) string { return p.MsgId }) {
+		_, warn, err := t.cli.ModifyGroupMessages(batch.pairs, incident.AtUsers, withPageId(msg, batch.pageId), opt)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			failedGroups = append(failedGroups, warn.Fails...)
+			t.errorf("tracker: modify incident %v group messages: %v, resend", incident.Fingerprint, warn.Explains)
+		}
+	}
+	if len(failedGroups) > 0 {
+		failed := make(map[client.GroupMsgIdPair]bool, len(failedGroups))
+		for _, pair := range failedGroups {
+			failed[pair] = true
+			resend.Groups = append(resend.Groups, pair.Group)
+		}
+		kept := incident.Groups[:0]
+		for _, pair := range incident.Groups {
+			if failed[pair] {
+				incident.setPage(pair.MsgId, "")
+			} else {
+				kept = append(kept, pair)
+			}
+		}
+		incident.Groups = kept
+	}
+
+	if len(resend.Users) > 0 || len(resend.Groups) > 0 {
+		resend.AtUsers = incident.AtUsers
+		errs = append(errs, t.send(incident, resend, msg))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("tracker: modify incident %v: %w", incident.Fingerprint, err)
+	}
+	return nil
+}
+
+func (t *Tracker) recall(incident *Incident) error {
+	var errs []error
+	for _, batch := range byPage(incident, incident.Users, func(p client.UserMsgIdPair) string { return p.MsgId }) {
+		_, warn, err := t.cli.ModifyUserMessages(batch.pairs, message.NewRecall().WithPageId(batch.pageId), nil)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			errs = append(errs, warn.Explains)
+		}
+	}
+	for _, batch := range byPage(incident, incident.Groups, func(p client.GroupMsgIdPair) string { return p.MsgId }) {
+		_, warn, err := t.cli.ModifyGroupMessages(batch.pairs, nil, message.NewRecall().WithPageId(batch.pageId), nil)
+		if err != nil {
+			errs = append(errs, err)
+		} else if warn != nil {
+			errs = append(errs, warn.Explains)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("tracker: recall incident %v: %w", incident.Fingerprint, err)
+	}
+	return nil
+}
+
+func asPage(msg client.Message) (message.Page, bool) {
+	switch m := msg.(type) {
+	case message.Page:
+		return m, true
+	case *message.Page:
+		return *m, true
+	}
+	return message.Page{}, false
+}
+
+// withPageId为页面消息设置原页面id，其它消息原样返回。
+func withPageId(msg client.Message, pageId string) client.Message {
+	if page, ok := asPage(msg); ok && pageId != "" {
+		return page.WithPageId(pageId)
+	}
+	return msg
+}
+
+type pageBatch[P any] struct {
+	pageId string
+	pairs  []P
+}
+
+// byPage按页面id将消息分批，非页面消息的页面id为空，按首次出现顺序返回。
+func byPage[P any](incident *Incident, pairs []P, msgid func(P) string) []pageBatch[P] {
+	var batches []pageBatch[P]
+	index := make(map[string]int)
+	for _, pair := range pairs {
+		pageId := incident.PageIds[msgid(pair)]
+		i, ok := index[pageId]
+		if !ok {
+			i = len(batches)
+			index[pageId] = i
+			batches = append(batches, pageBatch[P]{pageId: pageId})
+		}
+		batches[i].pairs = append(batches[i].pairs, pair)
+	}
+	return batches
+}
+
+func (t *Tracker) errorf(format string, args ...any) {
+	if t.opts.Errorf != nil {
+		t.opts.Errorf(format, args...)
+	}
+}
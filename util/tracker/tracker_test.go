@@ -0,0 +1,155 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/eachain/360-tuitui-robot/client"
+	"github.com/eachain/360-tuitui-robot/message"
+)
+
+type fakeClient struct {
+	sends   int
+	modify  []string // message types
+	without []bool
+	msgs    []client.Message // modified messages
+}
+
+func (c *fakeClient) SendPageToUsers(users []string, msg client.Message) (string, []client.UserMsgIdPair, *client.Warning[string], error) {
+	pairs, warn, err := c.SendMessageToUsers(users, msg)
+	return "page-u", pairs, warn, err
+}
+
+func (c *fakeClient) SendPageToGroups(groupIds []string, msg client.Message) (string, []client.GroupMsgIdPair, *client.Warning[string], error) {
+	pairs, warn, err := c.SendMessageToGroups(groupIds, nil, msg)
+	return "page-g", pairs, warn, err
+}
+
+func (c *fakeClient) SendMessageToUsers(users []string, msg client.Message) ([]client.UserMsgIdPair, *client.Warning[string], error) {
+	c.sends++
+	var pairs []client.UserMsgIdPair
+	for _, user := range users {
+		pairs = append(pairs, client.UserMsgIdPair{User: user, MsgId: "u1"})
+	}
+	return pairs, nil, nil
+}
+
+func (c *fakeClient) SendMessageToGroups(groupIds, atUsers []string, msg client.Message) ([]client.GroupMsgIdPair, *client.Warning[string], error) {
+	c.sends++
+	var pairs []client.GroupMsgIdPair
+	for _, group := range groupIds {
+		pairs = append(pairs, client.GroupMsgIdPair{Group: group, MsgId: "g1"})
+	}
+	return pairs, nil, nil
+}
+
+func (c *fakeClient) ModifyUserMessages(msgids []client.UserMsgIdPair, msg client.Message, opt *client.ModifyOptions) ([]client.UserMsgIdPair, *client.Warning[client.UserMsgIdPair], error) {
+	c.modify = append(c.modify, msg.Type())
+	c.without = append(c.without, opt != nil && opt.WithoutPush)
+	c.msgs = append(c.msgs, msg)
+	return msgids, nil, nil
+}
+
+func (c *fakeClient) ModifyGroupMessages(msgids []client.GroupMsgIdPair, atUsers []string, msg client.Message, opt *client.ModifyOptions) ([]client.GroupMsgIdPair, *client.Warning[client.GroupMsgIdPair], error) {
+	c.modify = append(c.modify, msg.Type())
+	c.without = append(c.without, opt != nil && opt.WithoutPush)
+	c.msgs = append(c.msgs, msg)
+	return msgids, nil, nil
+}
+
+func TestTrackerLifecycle(t *testing.T) {
+	cli := new(fakeClient)
+	tr := New(cli, NewMemStore(), &Options{WithoutPush: true})
+	target := Target{Users: []string{"zhangsan"}}
+
+	if err := tr.Fire("abc", target, message.NewText("firing")); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if err := tr.Fire("abc", target, message.NewText("still firing")); err != nil {
+		t.Fatalf("fire again: %v", err)
+	}
+	if cli.sends != 1 || len(cli.modify) != 1 || !cli.without[0] {
+		t.Fatalf("sends %v, modify %v, without push %v", cli.sends, cli.modify, cli.without)
+	}
+
+	incident, _ := tr.Incident("abc")
+	if incident == nil || len(incident.Users) != 1 || incident.Users[0].MsgId != "u1" {
+		t.Fatalf("incident: %+v", incident)
+	}
+
+	if err := tr.Resolve("abc", target, message.NewText("resolved")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if cli.sends != 1 || len(cli.modify) != 2 {
+		t.Fatalf("sends %v, modify %v", cli.sends, cli.modify)
+	}
+	if incident, _ = tr.Incident("abc"); incident != nil {
+		t.Fatalf("incident not deleted: %+v", incident)
+	}
+}
+
+func TestTrackerRecall(t *testing.T) {
+	cli := new(fakeClient)
+	tr := New(cli, NewMemStore(), &Options{RecallOnResolve: true})
+	target := Target{Groups: []string{"g"}}
+
+	tr.Fire("abc", target, message.NewText("firing"))
+	tr.Resolve("abc", target, message.NewText("resolved"))
+	if len(cli.modify) != 1 || cli.modify[0] != "recall" {
+		t.Fatalf("modify: %v", cli.modify)
+	}
+}
+
+func TestTrackerPage(t *testing.T) {
+	cli := new(fakeClient)
+	tr := New(cli, NewMemStore(), &Options{RecallOnResolve: true})
+	target := Target{Users: []string{"zhangsan"}, Groups: []string{"g"}}
+
+	if err := tr.Fire("abc", target, message.NewPage().WithTitle("firing")); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if err := tr.Fire("abc", target, message.NewPage().WithTitle("still firing")); err != nil {
+		t.Fatalf("fire again: %v", err)
+	}
+	if err := tr.Resolve("abc", target, message.NewPage().WithTitle("resolved")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	expected := []string{"page-u", "page-g", "page-u", "page-g"}
+	if len(cli.msgs) != len(expected) {
+		t.Fatalf("modified messages: %v", cli.msgs)
+	}
+	for i, msg := range cli.msgs {
+		var pageId string
+		switch m := msg.(type) {
+		case message.Page:
+			pageId = m.PageId
+		case message.Recall:
+			pageId = m.PageId
+		}
+		if pageId != expected[i] {
+			t.Fatalf("message %v: %#v, expected page id %v", i, msg, expected[i])
+		}
+	}
+}
+
+func TestMemStoreCopy(t *testing.T) {
+	ms := NewMemStore()
+	incident := &Incident{
+		Fingerprint: "fp",
+		Users:       []client.UserMsgIdPair{{User: "a", MsgId: "m1"}, {User: "b", MsgId: "m2"}},
+		Groups:      []client.GroupMsgIdPair{{Group: "g1", MsgId: "m3"}},
+	}
+	ms.Put(incident)
+
+	// 修改Put的参数及Get的结果，均不影响已保存的记录。
+	incident.Users[0].MsgId = "changed"
+	got, _ := ms.Get("fp")
+	got.Users = got.Users[:0]
+	got.Users = append(got.Users, client.UserMsgIdPair{User: "c", MsgId: "m4"})
+	got.Groups[0].MsgId = "changed"
+
+	got, _ = ms.Get("fp")
+	if len(got.Users) != 2 || got.Users[0].MsgId != "m1" || got.Groups[0].MsgId != "m3" {
+		t.Fatalf("stored incident changed: %+v", got)
+	}
+}
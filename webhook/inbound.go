@@ -0,0 +1,170 @@
+package webhook
+
+// 会话类型。
+const (
+	ConversationSingle = "single" // 单聊
+	ConversationGroup  = "group"  // 群聊
+	ConversationTeam   = "team"   // 团队帖子
+)
+
+// Conversation为消息所在会话，同时也是回复目标。
+type Conversation struct {
+	Type string `json:"type"` // ConversationSingle, ConversationGroup, ConversationTeam
+
+	User string `json:"user,omitempty"` // 单聊对方域账号，仅单聊有效
+
+	GroupId   string `json:"group_id,omitempty"`   // 群id，仅群聊有效
+	GroupName string `json:"group_name,omitempty"` // 群名称，仅群聊有效
+
+	TeamId      string `json:"team_id,omitempty"`      // 团队id，仅团队帖子有效
+	TeamName    string `json:"team_name,omitempty"`    // 团队名称，仅团队帖子有效
+	ChannelId   string `json:"channel_id,omitempty"`   // 频道id，仅团队帖子有效
+	ChannelName string `json:"channel_name,omitempty"` // 频道名称，仅团队帖子有效
+	ThreadId    string `json:"thread_id,omitempty"`    // 主帖id，仅团队帖子有效，回帖应发在该主帖下
+}
+
+// Key返回会话唯一标识，团队帖子精确到主帖，可用于按会话保存上下文。
+func (c Conversation) Key() string {
+	switch c.Type {
+	case ConversationSingle:
+		return "single:" + c.User
+	case ConversationGroup:
+		return "group:" + c.GroupId
+	default:
+		return "team:" + c.TeamId + ":" + c.ChannelId + ":" + c.ThreadId
+	}
+}
+
+// Mention为消息中的一个@对象。群聊只有AtAll和AtUser两种。
+type Mention struct {
+	Type PostAtType `json:"type"`           // AtAll, AtTag, AtUser
+	User *User      `json:"user,omitempty"` // Type为AtUser时有效
+	Tag  *TeamsTag  `json:"tag,omitempty"`  // Type为AtTag时有效，仅团队帖子
+}
+
+// InboundMessage为单聊消息、群聊消息及团队帖子的统一表示。
+type InboundMessage struct {
+	Conversation Conversation `json:"conversation"`
+	Sender       User         `json:"sender"`    // 消息发送者
+	Timestamp    int64        `json:"timestamp"` // 秒级时间戳
+	MsgId        string       `json:"msgid"`     // 消息id，团队帖子为帖子id
+	MsgType      string       `json:"msgtype"`   // 消息类型，团队帖子为"richtext"
+
+	Text         string `json:"text,omitempty"`           // 文本内容，团队帖子为Content
+	RichTextType string `json:"rich_text_type,omitempty"` // 富文本类型，仅团队帖子有效，可选值有：json/v1, html/v1
+	RichText     string `json:"rich_text,omitempty"`      // 富文本，仅团队帖子有效
+
+	Mentions []Mention `json:"mentions,omitempty"` // @列表（含机器人）
+	AtMe     bool      `json:"at_me"`              // 是否明确@机器人，单聊始终为true
+
+	Ref    *RefMsg  `json:"ref,omitempty"` // 引用的消息，仅单/群聊有效
+	Images []*Image `json:"images,omitempty"`
+	Files  []*File  `json:"files,omitempty"`
+	Voice  *Voice   `json:"voice,omitempty"` // 仅单/群聊有效
+
+	// 原始事件：SingleMessageEvent, GroupMessageEvent, TeamsPostEvent。
+	Event any `json:"-"`
+}
+
+// FromSingleMessage将单聊消息转为InboundMessage。
+func FromSingleMessage(event SingleMessageEvent) *InboundMessage {
+	m := &InboundMessage{
+		Conversation: Conversation{Type: ConversationSingle, User: event.User.Account},
+		Sender:       event.User,
+		Timestamp:    event.Timestamp,
+		MsgId:        event.MsgId,
+		MsgType:      event.MsgType,
+		Text:         event.Text,
+		AtMe:         true,
+		Ref:          event.Ref,
+		Images:       event.Images,
+		Voice:        event.Voice,
+		Event:        event,
+	}
+	if event.File != nil {
+		m.Files = []*File{event.File}
+	}
+	return m
+}
+
+// FromGroupMessage将群聊消息转为InboundMessage。
+func FromGroupMessage(event GroupMessageEvent) *InboundMessage {
+	m := &InboundMessage{
+		Conversation: Conversation{
+			Type:      ConversationGroup,
+			GroupId:   event.GroupId,
+			GroupName: event.GroupName,
+		},
+		Sender:    event.User,
+		Timestamp: event.Timestamp,
+		MsgId:     event.MsgId,
+		MsgType:   event.MsgType,
+		Text:      event.Text,
+		AtMe:      event.AtMe,
+		Ref:       event.Ref,
+		Images:    event.Images,
+		Voice:     event.Voice,
+		Event:     event,
+	}
+	if event.File != nil {
+		m.Files = []*File{event.File}
+	}
+	for _, at := range event.At {
+		if at.IsAtAll {
+			m.Mentions = append(m.Mentions, Mention{Type: AtAll})
+			continue
+		}
+		user := at.User
+		m.Mentions = append(m.Mentions, Mention{Type: AtUser, User: &user})
+	}
+	return m
+}
+
+// FromTeamsPost将团队帖子转为InboundMessage。
+func FromTeamsPost(event TeamsPostEvent) *InboundMessage {
+	thread := event.PostId
+	if event.IsReply {
+		thread = event.ParentId
+	}
+	m := &InboundMessage{
+		Conversation: Conversation{
+			Type:        ConversationTeam,
+			TeamId:      event.TeamId,
+			TeamName:    event.TeamName,
+			ChannelId:   event.ChannelId,
+			ChannelName: event.ChannelName,
+			ThreadId:    thread,
+		},
+		Sender:       event.User,
+		Timestamp:    event.Timestamp,
+		MsgId:        event.PostId,
+		MsgType:      "richtext",
+		Text:         event.Content,
+		RichTextType: event.RichTextType,
+		RichText:     event.RichText,
+		AtMe:         event.AtMe,
+		Images:       event.Images,
+		Files:        event.Files,
+		Event:        event,
+	}
+	for _, at := range event.At {
+		m.Mentions = append(m.Mentions, Mention{Type: at.Type, User: at.User, Tag: at.Tag})
+	}
+	return m
+}
+
+// OnMessage返回一个Callback，将单聊消息、群聊消息及新建团队帖子统一转为InboundMessage后调用h。
+// 可与其它Callback通过util/chain.Callbacks组合。
+func OnMessage(h func(*InboundMessage)) Callback {
+	return Callback{
+		OnReceiveSingleMessage: func(event SingleMessageEvent) {
+			h(FromSingleMessage(event))
+		},
+		OnReceiveGroupMessage: func(event GroupMessageEvent) {
+			h(FromGroupMessage(event))
+		},
+		OnCreateTeamsPost: func(event TeamsPostEvent) {
+			h(FromTeamsPost(event))
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import "testing"
+
+func TestOnMessage(t *testing.T) {
+	var got []*InboundMessage
+	cb := OnMessage(func(m *InboundMessage) { got = append(got, m) })
+
+	cb.OnReceiveSingleMessage(SingleMessageEvent{
+		User:    User{Account: "a"},
+		Message: Message{MsgId: "1", Text: "hi", File: &File{Name: "f.txt"}},
+	})
+	cb.OnReceiveGroupMessage(GroupMessageEvent{
+		User:    User{Account: "b"},
+		GroupId: "g",
+		At:      []GroupAtUser{{IsAtAll: true}, {User: User{Account: "c"}}},
+		Message: Message{MsgId: "2"},
+	})
+	cb.OnCreateTeamsPost(TeamsPostEvent{
+		User:     User{Account: "d"},
+		TeamId:   "t",
+		IsReply:  true,
+		ParentId: "p0",
+		PostId:   "p1",
+		Content:  "post",
+		At:       []TeamsPostAt{{Type: AtTag, Tag: &TeamsTag{Name: "dev"}}},
+	})
+
+	if len(got) != 3 {
+		t.Fatalf("got %v messages", len(got))
+	}
+	if m := got[0]; m.Conversation.Key() != "single:a" || !m.AtMe || len(m.Files) != 1 {
+		t.Fatalf("single: %+v", m)
+	}
+	if m := got[1]; m.Conversation.Key() != "group:g" || len(m.Mentions) != 2 ||
+		m.Mentions[0].Type != AtAll || m.Mentions[1].User.Account != "c" {
+		t.Fatalf("group: %+v", m)
+	}
+	if m := got[2]; m.Conversation.ThreadId != "p0" || m.MsgId != "p1" || m.Text != "post" ||
+		m.Mentions[0].Tag.Name != "dev" {
+		t.Fatalf("team: %+v", m)
+	}
+	if _, ok := got[2].Event.(TeamsPostEvent); !ok {
+		t.Fatalf("team event: %T", got[2].Event)
+	}
+}
@@ -27,7 +27,8 @@ type TeamsPostAt struct {
 }
 
 type TeamsPostEvent struct {
-	User User `json:"user"` // post sender or modifier
+	User      User  `json:"user"`      // post sender or modifier
+	Timestamp int64 `json:"timestamp"` // 秒级时间戳
 
 	TeamId       string        `json:"team_id"`             // 团队id
 	TeamName     string        `json:"team_name"`           // 团队名称
@@ -40,7 +41,7 @@ type TeamsPostEvent struct {
 	PostId       string        `json:"post_id"`             // 帖子id
 	Content      string        `json:"content"`             // 帖子文本内容
 	RichTextType string        `json:"rich_text_type"`      // 富文本类型，可选值有：json/v1, html/v1
-	RichText     string        `json:"rich_text"`           // 富文本，不建议业务解析该字段
+	RichText     string        `json:"rich_text"`           // 富文本，可用github.com/eachain/360-tuitui-robot/richtext解析
 	At           []TeamsPostAt `json:"at,omitempty"`        // 帖子@列表
 	AtMe         bool          `json:"at_me"`               // 是否@机器人，当且仅当At中有明确@机器人时为true。当@所有人和@标签时为false
 	Files        []*File       `json:"files,omitempty"`     // 帖子内容中的文件
@@ -80,6 +81,7 @@ func onTeamsPostEvent(req *eventRequest, cb func(TeamsPostEvent)) {
 
 	post := TeamsPostEvent{
 		User:         req.raiser.toUser(),
+		Timestamp:    req.Timestamp,
 		TeamId:       tp.TeamId,
 		TeamName:     tp.TeamName,
 		TeamDesc:     tp.TeamDesc,